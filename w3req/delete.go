@@ -14,35 +14,57 @@ type DeleteConfig struct {
 	SQLDialect   string
 	DumpRequests bool
 	OnPanic      func()
+
+	StmtCacheSize int //размер кеша подготовленных запросов, 0 - без кеша
+
+	SoftDeleteCol string            //если задана, то строки помечаются временем удаления вместо delete, для всех Tables без своей SoftDeleteCol
+	FieldMap      map[string]string //для DeleteWhere: поля Search, удаление по условию только из Tables[0]
+	MaxRows       int64             //для DeleteWhere: если под условие попадает больше строк, удаление откатывается, нужен Begin
 }
 
 type DeleteOptions struct {
 	Logger    Logger
 	DB        func() DB
 	Transform w3sql.DeleteTransform
+	Begin     func() (Tx, error) //транзакция, обязательна для MaxRows
 }
 
 type DeleteRequester interface {
 	InitOnce(f func() *DeleteOptions)
 	Handle(q *w3sql.Query) error
+	// выполняет DeleteWhere запроса, возвращает число удаленных строк
+	HandleWhere(q *w3sql.Query) (int64, error)
 	SetDumpRequests(v bool)
+	StmtCacheStats() StmtCacheStats
 }
 
 type deleteRequester struct {
 	cfg      *DeleteConfig
+	tables   []*w3sql.DeletePair
 	opt      *DeleteOptions
 	mut      sync.Mutex
 	initOnce sync.Once
 	conn     DB
+	stmts    *stmtCache
 }
 
 func NewDeleteRequester(cfg *DeleteConfig) (DeleteRequester, error) {
 	if cfg.OnPanic == nil {
 		return nil, errors.New("[w3req.DeleteRequester.NewDeleteRequester] OnPanic is mandatory")
 	}
+	tables := make([]*w3sql.DeletePair, len(cfg.Tables))
+	for i, t := range cfg.Tables {
+		tt := *t
+		if tt.SoftDeleteCol == "" {
+			tt.SoftDeleteCol = cfg.SoftDeleteCol
+		}
+		tables[i] = &tt
+	}
 	return &deleteRequester{
-		cfg: cfg,
-		mut: sync.Mutex{},
+		cfg:    cfg,
+		tables: tables,
+		mut:    sync.Mutex{},
+		stmts:  newStmtCache(cfg.StmtCacheSize),
 	}, nil
 }
 
@@ -67,7 +89,7 @@ func (r *deleteRequester) Handle(q *w3sql.Query) error {
 	if r.opt.Transform != nil {
 		tr = []w3sql.DeleteTransform{r.opt.Transform}
 	}
-	sq, err := q.CompileDelete(r.cfg.SQLDialect, r.cfg.Tables, tr...)
+	sq, err := q.CompileDelete(r.cfg.SQLDialect, r.tables, tr...)
 	if err != nil {
 		return err
 	}
@@ -76,17 +98,7 @@ func (r *deleteRequester) Handle(q *w3sql.Query) error {
 		panic("[w3req.DeleteRequester.Handle]: no query")
 	}
 
-	func() {
-		r.mut.Lock()
-		defer r.mut.Unlock()
-		if r.conn == nil {
-			r.conn = r.opt.DB()
-		}
-	}()
-
-	if r.conn == nil {
-		panic("[w3req.DeleteRequester.Handle]: DB is nil")
-	}
+	r.connect()
 
 	t, err := sq.SQL(r.cfg.AllSQL)
 	if err != nil {
@@ -97,8 +109,8 @@ func (r *deleteRequester) Handle(q *w3sql.Query) error {
 		r.opt.Logger.LogSQL("Delete SQL:", t[0].Code, t[0].Params)
 	}
 
-	for _, tt := range t {
-		_, err = r.conn.Exec(tt.Code, tt.Params)
+	for i, tt := range t {
+		_, err = r.stmts.Exec(r.conn, r.cfg.SQLDialect, tt.Code, tt.Params)
 		if err != nil {
 			err = fmt.Errorf(
 				"Delete error: %s\nSQL: %s\nParams:%+v\n",
@@ -107,11 +119,106 @@ func (r *deleteRequester) Handle(q *w3sql.Query) error {
 			)
 			return err
 		}
+		InvalidateTables(r.cfg.Tables[i].TableName)
 	}
 
 	return nil
 }
 
+func (r *deleteRequester) HandleWhere(q *w3sql.Query) (int64, error) {
+	defer r.cfg.OnPanic()
+
+	if len(r.tables) == 0 {
+		panic("[w3req.DeleteRequester.HandleWhere]: no tables")
+	}
+	table := r.tables[0]
+
+	sq, err := q.CompileDeleteWhere(r.cfg.SQLDialect, r.cfg.FieldMap, table)
+	if err != nil {
+		return 0, err
+	}
+	if sq == nil {
+		panic("[w3req.DeleteRequester.HandleWhere]: no query")
+	}
+
+	r.connect()
+
+	t, err := sq.SQL()
+	if err != nil {
+		return 0, err
+	}
+
+	if r.cfg.DumpRequests && r.opt.Logger != nil {
+		r.opt.Logger.LogSQL("Delete SQL:", t[0].Code, t[0].Params)
+	}
+
+	tooMany := &w3sql.ErrLimitExceeded{
+		ErrorInfo: w3sql.ErrorInfo{Path: "DeleteWhere"},
+		Limit:     w3sql.LimitMaxRows,
+		Max:       int(r.cfg.MaxRows),
+	}
+	var affected int64
+	del := func(conn DB) error {
+		// подсчет заранее отвергает большое удаление, не выполняя его
+		if r.cfg.MaxRows > 0 {
+			countSQL := "select count(*) from " + table.TableName + "\n" + t[0].Conditions
+			n, err := r.stmts.SelectInt(conn, r.cfg.SQLDialect, countSQL, t[0].Params)
+			if err != nil {
+				return fmt.Errorf("Delete count error: %s\nSQL: %s\nParams:%+v\n", err.Error(), countSQL, t[0].Params)
+			}
+			if n > r.cfg.MaxRows {
+				return tooMany
+			}
+		}
+
+		res, err := r.stmts.Exec(conn, r.cfg.SQLDialect, t[0].Code, t[0].Params)
+		if err != nil {
+			return fmt.Errorf(
+				"Delete error: %s\nSQL: %s\nParams:%+v\n",
+				err.Error(),
+				t[0].Code, t[0].Params,
+			)
+		}
+		if affected, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		// строки могли добавиться после подсчета, поэтому проверяется и число удаленных
+		if r.cfg.MaxRows > 0 && affected > r.cfg.MaxRows {
+			return tooMany
+		}
+		return nil
+	}
+
+	if r.cfg.MaxRows > 0 {
+		if r.opt.Begin == nil {
+			return 0, errors.New("[w3req.DeleteRequester.HandleWhere] Begin is mandatory for MaxRows")
+		}
+		err = inTx(r.opt.Begin, del)
+	} else {
+		err = del(r.conn)
+	}
+	InvalidateTables(table.TableName)
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+func (r *deleteRequester) connect() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.conn == nil {
+		r.conn = r.opt.DB()
+	}
+	if r.conn == nil {
+		panic("[w3req.DeleteRequester.connect]: DB is nil")
+	}
+}
+
 func (r *deleteRequester) SetDumpRequests(v bool) {
 	r.cfg.DumpRequests = v
 }
+
+func (r *deleteRequester) StmtCacheStats() StmtCacheStats {
+	return r.stmts.Stats()
+}
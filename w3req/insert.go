@@ -14,18 +14,33 @@ type InsertConfig struct {
 	SQLDialect   string
 	DumpRequests bool
 	OnPanic      func()
+
+	StmtCacheSize int      //размер кеша подготовленных запросов, 0 - без кеша
+	Table         string   //после успешной записи сбрасывается кеш результатов для этой таблицы
+	Returning     []string //колонки SQL для returning, только postgres и sqlite 3.35+
+
+	Rules         w3sql.WriteRules     //проверки значений до компиляции, все ошибки возвращаются в ErrRowValidation
+	ServerColumns []w3sql.ServerColumn //колонки, которые задает сервер, например created_by из ServerParams запроса
+
+	ChunkRows int //строк в одном insert, 0 - по лимиту параметров диалекта w3sql.MaxParams
 }
 
 type InsertOptions struct {
 	Logger    Logger
 	DB        func() DB
 	Transform w3sql.ValueTransform
+	Begin     func() (Tx, error) //транзакция для вставки частями, без нее вставка больше одной части не выполняется
 }
 
 type InsertRequester interface {
 	InitOnce(f func() *InsertOptions)
 	Handle(q *w3sql.Query) error
+	// выполняет вставку частями по ChunkRows строк, возвращает число вставленных строк
+	HandleBatch(q *w3sql.Query) (int64, error)
+	// выполняет запрос с returning и записывает затронутые строки в holder (*[]T)
+	HandleReturning(q *w3sql.Query, holder any) error
 	SetDumpRequests(v bool)
+	StmtCacheStats() StmtCacheStats
 }
 
 type insertRequester struct {
@@ -34,6 +49,7 @@ type insertRequester struct {
 	mut      sync.Mutex
 	initOnce sync.Once
 	conn     DB
+	stmts    *stmtCache
 }
 
 func NewInsertRequester(cfg *InsertConfig) (InsertRequester, error) {
@@ -41,8 +57,9 @@ func NewInsertRequester(cfg *InsertConfig) (InsertRequester, error) {
 		return nil, errors.New("[w3req.InsertRequester.NewInsertRequester] OnPanic is mandatory")
 	}
 	return &insertRequester{
-		cfg: cfg,
-		mut: sync.Mutex{},
+		cfg:   cfg,
+		mut:   sync.Mutex{},
+		stmts: newStmtCache(cfg.StmtCacheSize),
 	}, nil
 }
 
@@ -60,8 +77,10 @@ func (r *insertRequester) InitOnce(f func() *InsertOptions) {
 	})
 }
 
-func (r *insertRequester) Handle(q *w3sql.Query) error {
-	defer r.cfg.OnPanic()
+func (r *insertRequester) compile(q *w3sql.Query) (*w3sql.InsertQuery, error) {
+	if err := q.ValidateInsert(r.cfg.Rules); err != nil {
+		return nil, err
+	}
 
 	var tr []w3sql.ValueTransform
 	if r.opt.Transform != nil {
@@ -69,47 +88,153 @@ func (r *insertRequester) Handle(q *w3sql.Query) error {
 	}
 	sq, err := q.CompileInsert(r.cfg.SQLDialect, r.cfg.FieldMap, tr...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if sq == nil {
-		panic("[w3req.InsertRequester.Handle]: no query")
+		panic("[w3req.InsertRequester.compile]: no query")
 	}
 
-	func() {
-		r.mut.Lock()
-		defer r.mut.Unlock()
-		if r.conn == nil {
-			r.conn = r.opt.DB()
-		}
-	}()
+	r.connect()
 
-	if r.conn == nil {
-		panic("[w3req.InsertRequester.Handle]: DB is nil")
+	if err := sq.SetServerColumns(r.cfg.ServerColumns, q.ServerParams); err != nil {
+		return nil, err
+	}
+	sq.Returning = r.cfg.Returning
+	return sq, nil
+}
+
+func (r *insertRequester) prepare(q *w3sql.Query) (*w3sql.SQLQuery, error) {
+	sq, err := r.compile(q)
+	if err != nil {
+		return nil, err
 	}
 
 	t, err := sq.SQL(r.cfg.AllSQL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if r.cfg.DumpRequests && r.opt.Logger != nil {
 		r.opt.Logger.LogSQL("Insert SQL:", t[0].Code, t[0].Params)
 	}
+	return &t[0], nil
+}
+
+func (r *insertRequester) connect() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.conn == nil {
+		r.conn = r.opt.DB()
+	}
+	if r.conn == nil {
+		panic("[w3req.InsertRequester.connect]: DB is nil")
+	}
+}
+
+func (r *insertRequester) chunkRows(cols int) int {
+	if r.cfg.ChunkRows > 0 {
+		return r.cfg.ChunkRows
+	}
+	return w3sql.MaxParams(r.cfg.SQLDialect) / max(cols, 1)
+}
+
+func (r *insertRequester) Handle(q *w3sql.Query) error {
+	_, err := r.HandleBatch(q)
+	return err
+}
+
+func (r *insertRequester) HandleBatch(q *w3sql.Query) (int64, error) {
+	defer r.cfg.OnPanic()
+
+	sq, err := r.compile(q)
+	if err != nil {
+		return 0, err
+	}
+	chunks := sq.Chunks(r.chunkRows(len(sq.Cols)))
+
+	var total int64
+	insert := func(conn DB) error {
+		for i, c := range chunks {
+			t, err := c.SQL(r.cfg.AllSQL)
+			if err != nil {
+				return err
+			}
+			if r.cfg.DumpRequests && r.opt.Logger != nil {
+				r.opt.Logger.LogSQL("Insert SQL:", t[0].Code, t[0].Params)
+			}
+			res, err := r.stmts.Exec(conn, r.cfg.SQLDialect, t[0].Code, t[0].Params)
+			if err != nil {
+				return fmt.Errorf(
+					"Insert error: %s\nSQL: %s\nParams:%+v\n",
+					err.Error(),
+					t[0].Code, t[0].Params,
+				)
+			}
+			if n, err := res.RowsAffected(); err == nil {
+				total += n
+			} else {
+				total += int64(len(c.Values))
+			}
+
+			if len(chunks) > 1 && r.opt.Logger != nil {
+				r.opt.Logger.Logf("Insert chunk %s of %d: %d rows inserted\n", fmt.Sprint(i+1), len(chunks), total)
+			}
+		}
+		return nil
+	}
 
-	_, err = r.conn.Exec(t[0].Code, t[0].Params)
+	if len(chunks) > 1 {
+		// без транзакции ошибка в части N оставила бы части 1..N-1 вставленными
+		if r.opt.Begin == nil {
+			return 0, fmt.Errorf("[w3req.InsertRequester.HandleBatch] Begin is mandatory to insert %d chunks", len(chunks))
+		}
+		err = inTx(r.opt.Begin, insert)
+	} else {
+		err = insert(r.conn)
+	}
+	r.invalidate()
 	if err != nil {
-		err = fmt.Errorf(
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *insertRequester) HandleReturning(q *w3sql.Query, holder any) error {
+	defer r.cfg.OnPanic()
+
+	if len(r.cfg.Returning) == 0 {
+		return errors.New("[w3req.InsertRequester.HandleReturning] Returning is not set")
+	}
+
+	t, err := r.prepare(q)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.stmts.Select(r.conn, r.cfg.SQLDialect, holder, t.Code, t.Params)
+	if err != nil {
+		return fmt.Errorf(
 			"Insert error: %s\nSQL: %s\nParams:%+v\n",
 			err.Error(),
-			t[0].Code, t[0].Params,
+			t.Code, t.Params,
 		)
-		return err
 	}
 
+	r.invalidate()
 	return nil
 }
 
+func (r *insertRequester) invalidate() {
+	if r.cfg.Table != "" {
+		InvalidateTables(r.cfg.Table)
+	}
+}
+
 func (r *insertRequester) SetDumpRequests(v bool) {
 	r.cfg.DumpRequests = v
 }
+
+func (r *insertRequester) StmtCacheStats() StmtCacheStats {
+	return r.stmts.Stats()
+}
@@ -0,0 +1,78 @@
+package w3req
+
+import (
+	"strconv"
+	"strings"
+)
+
+func isParamChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// positionalSQL заменяет :name из params на плейсхолдеры диалекта, как gorp перед вызовом database/sql:
+// $1, $2... в postgres (повторное имя - тот же номер), иначе ?; имена не из params, строки в кавычках
+// и приведения типов ::type остаются как есть
+func positionalSQL(dialect string, query string, params map[string]any) (string, []any) {
+	code, names := positionalNames(dialect, query, params)
+	return code, positionalArgs(names, params)
+}
+
+// positionalArgs аргументы в порядке плейсхолдеров
+func positionalArgs(names []string, params map[string]any) []any {
+	args := make([]any, len(names))
+	for i, name := range names {
+		args[i] = params[name]
+	}
+	return args
+}
+
+// positionalNames как positionalSQL, но вместо аргументов возвращает имена параметров
+// в порядке плейсхолдеров, чтобы подготовленный запрос можно было выполнить с другими значениями
+func positionalNames(dialect string, query string, params map[string]any) (string, []string) {
+	var (
+		b     strings.Builder
+		args  []string
+		index = map[string]int{}
+	)
+	for i := 0; i < len(query); {
+		c := query[i]
+		if c == '\'' {
+			j := i + 1
+			for j < len(query) && query[j] != '\'' {
+				j++
+			}
+			if j < len(query) {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+			continue
+		}
+		if c == ':' && (i == 0 || query[i-1] != ':') {
+			j := i + 1
+			for j < len(query) && isParamChar(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+			if _, ok := params[name]; ok && name != "" {
+				if dialect == "postgres" {
+					n, seen := index[name]
+					if !seen {
+						args = append(args, name)
+						n = len(args)
+						index[name] = n
+					}
+					b.WriteString("$" + strconv.Itoa(n))
+				} else {
+					args = append(args, name)
+					b.WriteByte('?')
+				}
+				i = j
+				continue
+			}
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String(), args
+}
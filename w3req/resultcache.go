@@ -0,0 +1,150 @@
+package w3req
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/algebrain/w3/w3sql"
+)
+
+// версии таблиц, запись в таблицу увеличивает ее версию и тем самым сбрасывает кеш результатов
+var tableVersions = struct {
+	sync.Mutex
+	m map[string]uint64
+}{m: map[string]uint64{}}
+
+// InvalidateTables сбрасывает кеш результатов всех SelectRequester, читающих эти таблицы,
+// Insert/Update/DeleteRequester вызывают ее сами после успешной записи
+func InvalidateTables(tables ...string) {
+	tableVersions.Lock()
+	defer tableVersions.Unlock()
+	for _, t := range tables {
+		tableVersions.m[strings.ToLower(t)]++
+	}
+}
+
+// версии только растут, поэтому сумма меняется при любой записи в любую из таблиц
+func tablesVersion(tables []string) uint64 {
+	tableVersions.Lock()
+	defer tableVersions.Unlock()
+	var v uint64
+	for _, t := range tables {
+		v += tableVersions.m[strings.ToLower(t)]
+	}
+	return v
+}
+
+type ResultCacheConfig struct {
+	Size   int           //максимальное количество запросов в кеше
+	TTL    time.Duration //0 - без ограничения по времени
+	Tables []string      //таблицы, запись в которые сбрасывает кеш
+}
+
+type resultCacheEntry[T any] struct {
+	key     string
+	records []T
+	total   int64
+	version uint64
+	expires time.Time
+}
+
+type resultCache[T any] struct {
+	cfg   ResultCacheConfig
+	mut   sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newResultCache[T any](cfg *ResultCacheConfig) *resultCache[T] {
+	if cfg == nil || cfg.Size <= 0 {
+		return nil
+	}
+	return &resultCache[T]{
+		cfg:   *cfg,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+type resultCacheKey struct {
+	Search w3sql.RawCondition
+	Sort   []w3sql.SortQuery
+	Limit  *int
+	Offset *int
+	Params map[string]any
+}
+
+// ключ - нормализованный запрос, map в JSON сортируются, направления сортировки в верхнем регистре
+func (c *resultCache[T]) key(q *w3sql.Query) (string, bool) {
+	sort := make([]w3sql.SortQuery, len(q.Sort))
+	for i, s := range q.Sort {
+		sort[i] = w3sql.SortQuery{Col: s.Col, Dir: strings.ToUpper(s.Dir)}
+	}
+	b, err := json.Marshal(&resultCacheKey{
+		Search: q.Search,
+		Sort:   sort,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+		Params: q.Params,
+	})
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+func (c *resultCache[T]) get(key string) ([]T, int64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	version := tablesVersion(c.cfg.Tables)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, 0, false
+	}
+	e := el.Value.(*resultCacheEntry[T])
+	if e.version != version || (c.cfg.TTL > 0 && time.Now().After(e.expires)) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, 0, false
+	}
+	c.order.MoveToFront(el)
+	// копия, т.к. вызывающий может менять записи, например в FormatFields
+	return append([]T{}, e.records...), e.total, true
+}
+
+// version берется до запроса к базе, чтобы запись во время запроса не оставила в кеше старые данные
+func (c *resultCache[T]) put(key string, version uint64, records []T, total int64) {
+	if c == nil {
+		return
+	}
+	e := &resultCacheEntry[T]{
+		key:     key,
+		records: append([]T{}, records...),
+		total:   total,
+		version: version,
+		expires: time.Now().Add(c.cfg.TTL),
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(e)
+	for c.order.Len() > c.cfg.Size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*resultCacheEntry[T]).key)
+	}
+}
@@ -0,0 +1,58 @@
+package w3req
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// индексы полей структуры по именам колонок, как у gorp: db тег, иначе имя поля,
+// "-" пропускается, вложенные анонимные структуры раскрываются
+func columnFields(t reflect.Type, index []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			columnFields(f.Type, idx, fields)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := fields[strings.ToLower(name)]; !ok {
+			fields[strings.ToLower(name)] = idx
+		}
+	}
+}
+
+// scanDest указатели для rows.Scan в поля *rec, колонки без поля отбрасываются;
+// если T не структура, результат должен состоять из одной колонки
+func scanDest(rec any, cols []string) ([]any, error) {
+	v := reflect.ValueOf(rec).Elem()
+	if v.Kind() != reflect.Struct {
+		if len(cols) != 1 {
+			return nil, errors.New("w3req: non-struct record needs exactly one column")
+		}
+		return []any{rec}, nil
+	}
+
+	fields := map[string][]int{}
+	columnFields(v.Type(), nil, fields)
+
+	dest := make([]any, len(cols))
+	for i, c := range cols {
+		if idx, ok := fields[strings.ToLower(c)]; ok {
+			dest[i] = v.FieldByIndex(idx).Addr().Interface()
+		} else {
+			dest[i] = new(any)
+		}
+	}
+	return dest, nil
+}
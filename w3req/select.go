@@ -1,6 +1,7 @@
 package w3req
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -20,6 +21,20 @@ type DB interface {
 	Exec(string, ...any) (sql.Result, error)
 }
 
+// RowsDB расширение DB для построчного чтения результата без загрузки всех записей в память;
+// как и у *sql.DB, Query получает SQL с плейсхолдерами диалекта (? или $1) и позиционные аргументы,
+// именованные параметры запроса раскрываются заранее, см. SelectOptions.BindDialect
+type RowsDB interface {
+	DB
+	Query(string, ...any) (*sql.Rows, error)
+}
+
+// RowsContextDB если DB реализует, то Iterate отменяет запрос вместе с контекстом
+type RowsContextDB interface {
+	RowsDB
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}
+
 type TotalGetter[T any] interface {
 	Total(T) (int64, error)
 }
@@ -35,18 +50,57 @@ type SelectConfig[T any] struct {
 	AutoTotal    bool
 
 	TotalGetter TotalGetter[T]
+	Limits      *w3sql.Limits //ограничения сложности запроса, nil - без ограничений
 	OnPanic     func()
+
+	StmtCacheSize int                //размер кеша подготовленных запросов, 0 - без кеша
+	ResultCache   *ResultCacheConfig //кеш результатов запросов, nil - без кеша
+	SoftDeleteCol string             //если задана, к условию добавляется <col> is null, чтобы скрыть мягко удаленные строки
 }
 
 type SelectOptions[T any] struct {
-	Logger Logger
-	DB     func() DB
+	Logger      Logger
+	DB          func() DB
+	BindDialect string //диалект плейсхолдеров для RowsDB.Query, если не задан - SQLDialect
 }
 
 type SelectRequester[T any] interface {
 	InitOnce(f func() *SelectOptions[T])
 	Handle(q *w3sql.Query) ([]T, int64, error)
+	Validate(q *w3sql.Query) []error
+	Compile(q *w3sql.Query) ([]w3sql.SQLQuery, error)
 	SetDumpRequests(v bool)
+	StmtCacheStats() StmtCacheStats
+	SetStmtCacheSize(size int)
+	SetResultCache(cfg *ResultCacheConfig)
+	SetLimits(l *w3sql.Limits)
+	SetSoftDeleteCol(col string)
+}
+
+// SelectRowsRequester построчные запросы и подсчет записей по настройкам SelectRequester,
+// реализуется requester из NewSelectRequester, см. NewSelectRowsRequester
+type SelectRowsRequester[T any] interface {
+	// выполняет запрос без limit и offset, но не больше maxRows строк (0 - без ограничения),
+	// и передает строки в f по одной, cols - имена колонок результата; до строк f один раз вызывается
+	// с row == nil, чтобы колонки были известны и для пустого результата; DB должна реализовать RowsDB
+	Export(q *w3sql.Query, maxRows int, f func(cols []string, row []any) error) error
+	// передает записи результата в f по одной, не загружая весь результат в память,
+	// limit и offset запроса учитываются; DB должна реализовать RowsDB, колонки сопоставляются с полями T по db тегам
+	Iterate(ctx context.Context, q *w3sql.Query, f func(T) error) error
+	// выполняет запрос строк-групп по полям cols с агрегатами aggs (см. w3sql.SelectQuery.GroupSQL)
+	// и передает строки в f по одной, cols ответа - поля фронта; DB должна реализовать RowsDB
+	Group(q *w3sql.Query, cols []string, aggs []w3sql.Aggregate, f func(cols []string, row []any) error) error
+	// число записей результата без limit и offset: по TotalSQL, если задан, иначе count(*) по AllSQL
+	Count(q *w3sql.Query) (int64, error)
+}
+
+// NewSelectRowsRequester построчные запросы для requester из NewSelectRequester
+func NewSelectRowsRequester[T any](r SelectRequester[T]) (SelectRowsRequester[T], error) {
+	rr, ok := r.(SelectRowsRequester[T])
+	if !ok {
+		return nil, errors.New("[w3req.SelectRowsRequester.NewSelectRowsRequester] requester does not support row queries")
+	}
+	return rr, nil
 }
 
 type selectRequester[T any] struct {
@@ -56,6 +110,8 @@ type selectRequester[T any] struct {
 	mut       sync.Mutex
 	initOnce  sync.Once
 	conn      DB
+	stmts     *stmtCache
+	results   *resultCache[T]
 }
 
 func NewSelectRequester[T any](cfg *SelectConfig[T]) (SelectRequester[T], error) {
@@ -70,6 +126,8 @@ func NewSelectRequester[T any](cfg *SelectConfig[T]) (SelectRequester[T], error)
 		cfg:       cfg,
 		lowerCols: lowerCols,
 		mut:       sync.Mutex{},
+		stmts:     newStmtCache(cfg.StmtCacheSize),
+		results:   newResultCache[T](cfg.ResultCache),
 	}, nil
 }
 
@@ -87,12 +145,63 @@ func (r *selectRequester[T]) InitOnce(f func() *SelectOptions[T]) {
 	})
 }
 
-func (r *selectRequester[T]) Handle(q *w3sql.Query) ([]T, int64, error) {
-	defer r.cfg.OnPanic()
+func (r *selectRequester[T]) compile(q *w3sql.Query) (*w3sql.SelectQuery, error) {
+	if err := q.CheckLimits(r.cfg.Limits); err != nil {
+		return nil, err
+	}
 
-	q.LowerSearchValues(r.lowerCols)
+	if q.Search != nil {
+		q.LowerSearchValues(r.lowerCols)
+	}
 
 	sq, err := q.CompileSelect(r.cfg.SQLDialect, r.cfg.FieldMap)
+	if err != nil {
+		return nil, err
+	}
+	if r.cfg.SoftDeleteCol != "" {
+		sq = sq.AndCondition(r.cfg.SoftDeleteCol + " is null")
+	}
+	return sq, nil
+}
+
+// Validate возвращает все ошибки запроса, не обращаясь к базе
+func (r *selectRequester[T]) Validate(q *w3sql.Query) []error {
+	defer r.cfg.OnPanic()
+
+	if err := q.CheckLimits(r.cfg.Limits); err != nil {
+		return []error{err}
+	}
+	return q.Validate(r.cfg.FieldMap, r.cfg.SQLDialect)
+}
+
+// Compile возвращает SQL, который выполнил бы Handle: запрос записей и, если задан TotalSQL, запрос total
+func (r *selectRequester[T]) Compile(q *w3sql.Query) ([]w3sql.SQLQuery, error) {
+	defer r.cfg.OnPanic()
+
+	sq, err := r.compile(q)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := sq.SQL(r.cfg.AllSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cfg.TotalSQL != nil {
+		t, err := sq.NoLimitOffset().SQL(r.cfg.TotalSQL)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t...)
+	}
+	return result, nil
+}
+
+func (r *selectRequester[T]) Handle(q *w3sql.Query) ([]T, int64, error) {
+	defer r.cfg.OnPanic()
+
+	sq, err := r.compile(q)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -101,17 +210,40 @@ func (r *selectRequester[T]) Handle(q *w3sql.Query) ([]T, int64, error) {
 		panic("[w3req.SelectRequester.Handle]: no query")
 	}
 
-	func() {
-		r.mut.Lock()
-		defer r.mut.Unlock()
-		if r.conn == nil {
-			r.conn = r.opt.DB()
+	var (
+		key       string
+		cacheable bool
+		version   uint64
+	)
+	if r.results != nil {
+		if key, cacheable = r.results.key(q); cacheable {
+			if ret, total, ok := r.results.get(key); ok {
+				return ret, total, nil
+			}
+			version = tablesVersion(r.results.cfg.Tables)
 		}
-	}()
+	}
 
+	ret, total, err := r.selectDB(q, sq)
+	if err == nil && cacheable {
+		r.results.put(key, version, ret, total)
+	}
+	return ret, total, err
+}
+
+func (r *selectRequester[T]) connect() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
 	if r.conn == nil {
-		panic("[w3req.SelectRequester.Handle]: DB is nil")
+		r.conn = r.opt.DB()
 	}
+	if r.conn == nil {
+		panic("[w3req.SelectRequester.connect]: DB is nil")
+	}
+}
+
+func (r *selectRequester[T]) selectDB(q *w3sql.Query, sq *w3sql.SelectQuery) ([]T, int64, error) {
+	r.connect()
 
 	var total int64
 
@@ -125,7 +257,7 @@ func (r *selectRequester[T]) Handle(q *w3sql.Query) ([]T, int64, error) {
 			r.opt.Logger.LogSQL("Total SQL:", t[0].Code, t[0].Params)
 		}
 
-		total, err = r.conn.SelectInt(t[0].Code, t[0].Params)
+		total, err = r.stmts.SelectInt(r.conn, r.cfg.SQLDialect, t[0].Code, t[0].Params)
 		if err != nil {
 			err = fmt.Errorf(
 				"SelectOne error: %s\nSQL: %s\nParams:%+v\n",
@@ -150,7 +282,7 @@ func (r *selectRequester[T]) Handle(q *w3sql.Query) ([]T, int64, error) {
 	}
 
 	var ret []T
-	_, err = r.conn.Select(&ret, t[0].Code, t[0].Params)
+	_, err = r.stmts.Select(r.conn, r.cfg.SQLDialect, &ret, t[0].Code, t[0].Params)
 	if err != nil {
 		err = fmt.Errorf(
 			"Select error: %s\nSQL: %s\nParams:%+v\n",
@@ -185,6 +317,213 @@ func (r *selectRequester[T]) Handle(q *w3sql.Query) ([]T, int64, error) {
 	return ret, total, nil
 }
 
+// queryRows выполняет уже скомпилированный запрос через RowsDB
+func (r *selectRequester[T]) queryRows(ctx context.Context, prefix string, sq *w3sql.SelectQuery) (*sql.Rows, error) {
+	t, err := sq.SQL(r.cfg.AllSQL)
+	if err != nil {
+		return nil, err
+	}
+	return r.querySQL(ctx, prefix, t)
+}
+
+func (r *selectRequester[T]) querySQL(ctx context.Context, prefix string, t []w3sql.SQLQuery) (*sql.Rows, error) {
+	r.connect()
+	rdb, ok := r.conn.(RowsDB)
+	if !ok {
+		return nil, errors.New("w3req: DB does not implement RowsDB")
+	}
+
+	var err error
+	if r.cfg.DumpRequests && r.opt.Logger != nil {
+		r.opt.Logger.LogSQL(prefix, t[0].Code, t[0].Params)
+	}
+
+	dialect := r.cfg.SQLDialect
+	if r.opt.BindDialect != "" {
+		dialect = r.opt.BindDialect
+	}
+	code, args := positionalSQL(dialect, t[0].Code, t[0].Params)
+	var rows *sql.Rows
+	if cdb, ok := rdb.(RowsContextDB); ok {
+		rows, err = cdb.QueryContext(ctx, code, args...)
+	} else {
+		rows, err = rdb.Query(code, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Query error: %s\nSQL: %s\nParams:%+v\n",
+			err.Error(),
+			t[0].Code, t[0].Params,
+		)
+	}
+	return rows, nil
+}
+
+func (r *selectRequester[T]) Export(q *w3sql.Query, maxRows int, f func(cols []string, row []any) error) error {
+	defer r.cfg.OnPanic()
+
+	sq, err := r.compile(q)
+	if err != nil {
+		return err
+	}
+	sq = sq.NoLimitOffset()
+	if maxRows > 0 {
+		sq.Limit = &maxRows
+	}
+
+	rows, err := r.queryRows(context.Background(), "Export SQL:", sq)
+	if err != nil {
+		return err
+	}
+	return scanRows(rows, true, f)
+}
+
+func (r *selectRequester[T]) Group(q *w3sql.Query, cols []string, aggs []w3sql.Aggregate, f func(cols []string, row []any) error) error {
+	defer r.cfg.OnPanic()
+
+	sq, err := r.compile(q)
+	if err != nil {
+		return err
+	}
+	t, err := sq.GroupSQL(r.cfg.AllSQL, r.cfg.FieldMap, cols, aggs, q.Sort)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.querySQL(context.Background(), "Group SQL:", t)
+	if err != nil {
+		return err
+	}
+	return scanRows(rows, false, f)
+}
+
+func (r *selectRequester[T]) Count(q *w3sql.Query) (int64, error) {
+	defer r.cfg.OnPanic()
+
+	sq, err := r.compile(q)
+	if err != nil {
+		return 0, err
+	}
+
+	var t []w3sql.SQLQuery
+	if r.cfg.TotalSQL != nil {
+		t, err = sq.NoLimitOffset().SQL(r.cfg.TotalSQL)
+	} else {
+		t, err = sq.CountSQL(r.cfg.AllSQL)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	r.connect()
+	if r.cfg.DumpRequests && r.opt.Logger != nil {
+		r.opt.Logger.LogSQL("Count SQL:", t[0].Code, t[0].Params)
+	}
+
+	n, err := r.stmts.SelectInt(r.conn, r.cfg.SQLDialect, t[0].Code, t[0].Params)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"SelectInt error: %s\nSQL: %s\nParams:%+v\n",
+			err.Error(),
+			t[0].Code, t[0].Params,
+		)
+	}
+	return n, nil
+}
+
+// scanRows передает строки результата в f по одной и закрывает rows,
+// при header сначала передает колонки с row == nil
+func scanRows(rows *sql.Rows, header bool, f func(cols []string, row []any) error) error {
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if header {
+		if err := f(cols, nil); err != nil {
+			return err
+		}
+	}
+	row := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range row {
+		ptrs[i] = &row[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if err := f(cols, row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *selectRequester[T]) Iterate(ctx context.Context, q *w3sql.Query, f func(T) error) error {
+	defer r.cfg.OnPanic()
+
+	sq, err := r.compile(q)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.queryRows(ctx, "Iterate SQL:", sq)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var rec T
+	dest, err := scanDest(&rec, cols)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var zero T
+		rec = zero
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if err := f(rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (r *selectRequester[T]) SetDumpRequests(v bool) {
 	r.cfg.DumpRequests = v
 }
+
+func (r *selectRequester[T]) SetLimits(l *w3sql.Limits) {
+	r.cfg.Limits = l
+}
+
+func (r *selectRequester[T]) SetSoftDeleteCol(col string) {
+	r.cfg.SoftDeleteCol = col
+}
+
+func (r *selectRequester[T]) StmtCacheStats() StmtCacheStats {
+	return r.stmts.Stats()
+}
+
+// вызывать до первого запроса
+func (r *selectRequester[T]) SetStmtCacheSize(size int) {
+	r.cfg.StmtCacheSize = size
+	r.stmts = newStmtCache(size)
+}
+
+// вызывать до первого запроса
+func (r *selectRequester[T]) SetResultCache(cfg *ResultCacheConfig) {
+	r.cfg.ResultCache = cfg
+	r.results = newResultCache[T](cfg)
+}
@@ -0,0 +1,281 @@
+package w3req
+
+import (
+	"container/list"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Stmt подготовленный запрос; аргументы позиционные, в порядке плейсхолдеров SQL, переданного в Prepare
+type Stmt interface {
+	Select(any, ...any) ([]any, error)
+	SelectInt(...any) (int64, error)
+	Exec(...any) (sql.Result, error)
+	Close() error
+}
+
+// PreparingDB расширение DB, если DB его реализует и задан размер кеша,
+// запросы выполняются через кеш подготовленных запросов. Как и *sql.DB.Prepare, Prepare получает SQL
+// с плейсхолдерами диалекта (? или $1), именованные параметры кеш раскрывает сам, см. SQLPreparingDB
+type PreparingDB interface {
+	DB
+	Prepare(query string) (Stmt, error)
+}
+
+type StmtCacheStats struct {
+	Size      int
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+func (s StmtCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type stmtCacheEntry struct {
+	key     string
+	stmt    Stmt
+	names   []string //имена параметров в порядке плейсхолдеров
+	refs    int
+	evicted bool
+}
+
+// LRU кеш подготовленных запросов по тексту SQL и диалекту,
+// nil означает, что кеш выключен
+type stmtCache struct {
+	mut   sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+	stats StmtCacheStats
+}
+
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+	return &stmtCache{
+		size:  size,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (c *stmtCache) Stats() StmtCacheStats {
+	if c == nil {
+		return StmtCacheStats{}
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	s := c.stats
+	s.Size = c.order.Len()
+	return s
+}
+
+// acquire ищет запрос по именованному SQL; имена параметров берутся из params,
+// для одного текста SQL они одни и те же
+func (c *stmtCache) acquire(db PreparingDB, dialect string, query string, params map[string]any) (*stmtCacheEntry, error) {
+	key := dialect + "\x00" + query
+
+	c.mut.Lock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		e := el.Value.(*stmtCacheEntry)
+		e.refs++
+		c.stats.Hits++
+		c.mut.Unlock()
+		return e, nil
+	}
+	c.stats.Misses++
+	c.mut.Unlock()
+
+	code, names := positionalNames(dialect, query, params)
+	stmt, err := db.Prepare(code)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// тот же запрос мог быть подготовлен параллельно
+	if el, ok := c.items[key]; ok {
+		stmt.Close()
+		c.order.MoveToFront(el)
+		e := el.Value.(*stmtCacheEntry)
+		e.refs++
+		return e, nil
+	}
+
+	e := &stmtCacheEntry{key: key, stmt: stmt, names: names, refs: 1}
+	c.items[key] = c.order.PushFront(e)
+
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		old := el.Value.(*stmtCacheEntry)
+		c.order.Remove(el)
+		delete(c.items, old.key)
+		c.stats.Evictions++
+		old.evicted = true
+		if old.refs == 0 {
+			old.stmt.Close()
+		}
+	}
+	return e, nil
+}
+
+func (c *stmtCache) release(e *stmtCacheEntry) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	e.refs--
+	if e.evicted && e.refs == 0 {
+		e.stmt.Close()
+	}
+}
+
+// prepared кеш используется вне транзакции и только для именованных параметров одной картой
+func (c *stmtCache) prepared(db DB, args []any) (PreparingDB, map[string]any, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	if _, ok := db.(Tx); ok {
+		return nil, nil, false
+	}
+	pdb, ok := db.(PreparingDB)
+	if !ok || len(args) != 1 {
+		return nil, nil, false
+	}
+	params, ok := args[0].(map[string]any)
+	return pdb, params, ok
+}
+
+func (c *stmtCache) Select(db DB, dialect string, holder any, query string, args ...any) ([]any, error) {
+	pdb, params, ok := c.prepared(db, args)
+	if !ok {
+		return db.Select(holder, query, args...)
+	}
+	e, err := c.acquire(pdb, dialect, query, params)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(e)
+	return e.stmt.Select(holder, positionalArgs(e.names, params)...)
+}
+
+func (c *stmtCache) SelectInt(db DB, dialect string, query string, args ...any) (int64, error) {
+	pdb, params, ok := c.prepared(db, args)
+	if !ok {
+		return db.SelectInt(query, args...)
+	}
+	e, err := c.acquire(pdb, dialect, query, params)
+	if err != nil {
+		return 0, err
+	}
+	defer c.release(e)
+	return e.stmt.SelectInt(positionalArgs(e.names, params)...)
+}
+
+func (c *stmtCache) Exec(db DB, dialect string, query string, args ...any) (sql.Result, error) {
+	pdb, params, ok := c.prepared(db, args)
+	if !ok {
+		return db.Exec(query, args...)
+	}
+	e, err := c.acquire(pdb, dialect, query, params)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(e)
+	return e.stmt.Exec(positionalArgs(e.names, params)...)
+}
+
+// SQLPreparingDB PreparingDB и RowsDB поверх DB (например *gorp.DbMap) и его *sql.DB:
+// обычные запросы выполняет DB, подготовленные и построчные - SQL
+type SQLPreparingDB struct {
+	DB
+	SQL *sql.DB
+}
+
+func (db *SQLPreparingDB) Prepare(query string) (Stmt, error) {
+	stmt, err := db.SQL.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{stmt: stmt}, nil
+}
+
+func (db *SQLPreparingDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.SQL.Query(query, args...)
+}
+
+type sqlStmt struct {
+	stmt *sql.Stmt
+}
+
+// Select заполняет holder - указатель на срез структур или указателей на них, колонки сопоставляются
+// с полями по db тегам, как в gorp
+func (s *sqlStmt) Select(holder any, args ...any) ([]any, error) {
+	v := reflect.ValueOf(holder)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Slice {
+		return nil, errors.New("w3req: holder should be a pointer to slice")
+	}
+	rows, err := s.stmt.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := v.Elem()
+	elemType := result.Type().Elem()
+	for rows.Next() {
+		rec := reflect.New(elemType)
+		if elemType.Kind() == reflect.Pointer {
+			rec.Elem().Set(reflect.New(elemType.Elem()))
+			rec = rec.Elem()
+		}
+		dest, err := scanDest(rec.Interface(), cols)
+		if err != nil {
+			return nil, err
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if elemType.Kind() == reflect.Pointer {
+			result = reflect.Append(result, rec)
+		} else {
+			result = reflect.Append(result, rec.Elem())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	v.Elem().Set(result)
+	return nil, nil
+}
+
+// SelectInt как в gorp, NULL и пустой результат - 0
+func (s *sqlStmt) SelectInt(args ...any) (int64, error) {
+	var n sql.NullInt64
+	err := s.stmt.QueryRow(args...).Scan(&n)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return n.Int64, nil
+}
+
+func (s *sqlStmt) Exec(args ...any) (sql.Result, error) {
+	return s.stmt.Exec(args...)
+}
+
+func (s *sqlStmt) Close() error {
+	return s.stmt.Close()
+}
@@ -0,0 +1,22 @@
+package w3req
+
+// Tx транзакция, например *gorp.Transaction; запросы в ней выполняются мимо кеша
+// подготовленных запросов, так как подготовленные в транзакции запросы закрываются вместе с ней
+type Tx interface {
+	DB
+	Commit() error
+	Rollback() error
+}
+
+// inTx выполняет f в транзакции от begin, ошибка f откатывает транзакцию
+func inTx(begin func() (Tx, error), f func(db DB) error) error {
+	tx, err := begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -3,6 +3,7 @@ package w3req
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/algebrain/w3/w3sql"
@@ -15,18 +16,55 @@ type UpdateConfig struct {
 	SQLDialect   string
 	DumpRequests bool
 	OnPanic      func()
+
+	StmtCacheSize int      //размер кеша подготовленных запросов, 0 - без кеша
+	Table         string   //после успешной записи сбрасывается кеш результатов для этой таблицы
+	Returning     []string //колонки SQL для returning, только postgres и sqlite 3.35+
+
+	Rules         w3sql.WriteRules     //проверки значений до компиляции, все ошибки возвращаются в ErrRowValidation
+	ServerColumns []w3sql.ServerColumn //колонки, которые задает сервер, например created_by из ServerParams запроса
+	MaxRows       int64                //для UpdateWhere: если под условие попадает больше строк, обновление откатывается, нужен Begin; с Table строки сначала считаются
+
+	// колонка SQL версии строки: проверяется в where и увеличивается на 1 (или current_timestamp при VersionTimestamp),
+	// если обновлено меньше строк, чем прислано, Handle откатывает обновление и возвращает ErrUpdateConflict;
+	// для нескольких строк нужен Begin
+	VersionCol       string
+	VersionTimestamp bool
+}
+
+const KindUpdateConflict = "update_conflict"
+
+// ErrUpdateConflict строка изменена другим пользователем или удалена
+type ErrUpdateConflict struct {
+	w3sql.ErrorInfo
+	Expected int64
+	Affected int64
+}
+
+func (e *ErrUpdateConflict) Error() string {
+	return fmt.Sprintf("w3req: update conflict, %d of %d rows updated", e.Affected, e.Expected)
+}
+
+func (e *ErrUpdateConflict) Kind() string {
+	return KindUpdateConflict
 }
 
 type UpdateOptions struct {
 	Logger    Logger
 	DB        func() DB
 	Transform w3sql.ValueTransform
+	Begin     func() (Tx, error) //транзакция, обязательна для MaxRows, VersionCol при нескольких строках и нескольких групп Update.Rows
 }
 
 type UpdateRequester interface {
 	InitOnce(f func() *UpdateOptions)
 	Handle(q *w3sql.Query) error
+	// выполняет запрос с returning и записывает затронутые строки в holder (*[]T)
+	HandleReturning(q *w3sql.Query, holder any) error
+	// выполняет UpdateWhere запроса, возвращает число обновленных строк
+	HandleWhere(q *w3sql.Query) (int64, error)
 	SetDumpRequests(v bool)
+	StmtCacheStats() StmtCacheStats
 }
 
 type updateRequester struct {
@@ -35,6 +73,7 @@ type updateRequester struct {
 	mut      sync.Mutex
 	initOnce sync.Once
 	conn     DB
+	stmts    *stmtCache
 }
 
 func NewUpdateRequester(cfg *UpdateConfig) (UpdateRequester, error) {
@@ -42,8 +81,9 @@ func NewUpdateRequester(cfg *UpdateConfig) (UpdateRequester, error) {
 		return nil, errors.New("[w3req.UpdateRequester.NewUpdateRequester] OnPanic is mandatory")
 	}
 	return &updateRequester{
-		cfg: cfg,
-		mut: sync.Mutex{},
+		cfg:   cfg,
+		mut:   sync.Mutex{},
+		stmts: newStmtCache(cfg.StmtCacheSize),
 	}, nil
 }
 
@@ -61,8 +101,11 @@ func (r *updateRequester) InitOnce(f func() *UpdateOptions) {
 	})
 }
 
-func (r *updateRequester) Handle(q *w3sql.Query) error {
-	defer r.cfg.OnPanic()
+// возвращает SQL по группам строк и число обновляемых строк
+func (r *updateRequester) prepare(q *w3sql.Query) ([]w3sql.SQLQuery, int64, error) {
+	if err := q.ValidateUpdate(r.cfg.Rules); err != nil {
+		return nil, 0, err
+	}
 
 	var tr []w3sql.ValueTransform
 	if r.opt.Transform != nil {
@@ -70,47 +113,249 @@ func (r *updateRequester) Handle(q *w3sql.Query) error {
 	}
 	sq, err := q.CompileUpdate(r.cfg.SQLDialect, r.cfg.FieldMap, r.cfg.IDFieldName, tr...)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	if sq == nil {
-		panic("[w3req.UpdateRequester.Handle]: no query")
+		panic("[w3req.UpdateRequester.prepare]: no query")
+	}
+
+	r.connect()
+
+	if err := sq.SetServerColumns(r.cfg.ServerColumns, q.ServerParams); err != nil {
+		return nil, 0, err
+	}
+	sq.Returning = r.cfg.Returning
+	sq.VersionCol = r.cfg.VersionCol
+	sq.VersionTimestamp = r.cfg.VersionTimestamp
+	t, err := sq.SQL(r.cfg.AllSQL)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	func() {
-		r.mut.Lock()
-		defer r.mut.Unlock()
-		if r.conn == nil {
-			r.conn = r.opt.DB()
+	if r.cfg.DumpRequests && r.opt.Logger != nil {
+		for _, tt := range t {
+			r.opt.Logger.LogSQL("Update SQL:", tt.Code, tt.Params)
 		}
-	}()
+	}
+	return t, int64(sq.RowCount()), nil
+}
 
-	if r.conn == nil {
-		panic("[w3req.UpdateRequester.Handle]: DB is nil")
+func (r *updateRequester) Handle(q *w3sql.Query) error {
+	defer r.cfg.OnPanic()
+
+	t, expected, err := r.prepare(q)
+	if err != nil {
+		return err
 	}
 
-	t, err := sq.SQL(r.cfg.AllSQL)
+	update := func(conn DB) error {
+		var affected int64
+		for _, tt := range t {
+			res, err := r.stmts.Exec(conn, r.cfg.SQLDialect, tt.Code, tt.Params)
+			if err != nil {
+				return fmt.Errorf(
+					"Update error: %s\nSQL: %s\nParams:%+v\n",
+					err.Error(),
+					tt.Code, tt.Params,
+				)
+			}
+			if r.cfg.VersionCol != "" {
+				n, err := res.RowsAffected()
+				if err != nil {
+					return err
+				}
+				affected += n
+			}
+		}
+		if r.cfg.VersionCol != "" {
+			return r.checkConflict(expected, affected)
+		}
+		return nil
+	}
+
+	err = r.run("Handle", len(t), expected, update)
+	r.invalidate()
+	return err
+}
+
+func (r *updateRequester) HandleReturning(q *w3sql.Query, holder any) error {
+	defer r.cfg.OnPanic()
+
+	if len(r.cfg.Returning) == 0 {
+		return errors.New("[w3req.UpdateRequester.HandleReturning] Returning is not set")
+	}
+
+	t, expected, err := r.prepare(q)
 	if err != nil {
 		return err
 	}
 
-	if r.cfg.DumpRequests && r.opt.Logger != nil {
-		r.opt.Logger.LogSQL("Update SQL:", t[0].Code, t[0].Params)
+	// holder - указатель на срез, строки всех групп добавляются в него
+	v := reflect.ValueOf(holder)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Slice {
+		panic("[w3req.UpdateRequester.HandleReturning]: holder should be a pointer to slice")
+	}
+	all := reflect.MakeSlice(v.Elem().Type(), 0, int(expected))
+
+	update := func(conn DB) error {
+		for _, tt := range t {
+			part := reflect.New(v.Elem().Type())
+			_, err := r.stmts.Select(conn, r.cfg.SQLDialect, part.Interface(), tt.Code, tt.Params)
+			if err != nil {
+				return fmt.Errorf(
+					"Update error: %s\nSQL: %s\nParams:%+v\n",
+					err.Error(),
+					tt.Code, tt.Params,
+				)
+			}
+			all = reflect.AppendSlice(all, part.Elem())
+		}
+		if r.cfg.VersionCol != "" {
+			return r.checkConflict(expected, int64(all.Len()))
+		}
+		return nil
 	}
 
-	_, err = r.conn.Exec(t[0].Code, t[0].Params)
+	err = r.run("HandleReturning", len(t), expected, update)
+	r.invalidate()
 	if err != nil {
-		err = fmt.Errorf(
-			"Update error: %s\nSQL: %s\nParams:%+v\n",
-			err.Error(),
-			t[0].Code, t[0].Params,
-		)
 		return err
 	}
+	// после отката строки в holder не попадают
+	v.Elem().Set(all)
+	return nil
+}
+
+// run выполняет обновление в транзакции, если запросов по группам несколько
+// или при конфликте версий уже обновленные строки нужно откатить
+func (r *updateRequester) run(method string, groups int, expected int64, update func(conn DB) error) error {
+	if groups < 2 && (r.cfg.VersionCol == "" || expected < 2) {
+		return update(r.conn)
+	}
+	if r.opt.Begin == nil {
+		return fmt.Errorf("[w3req.UpdateRequester.%s] Begin is mandatory to update %d rows in %d queries", method, expected, groups)
+	}
+	return inTx(r.opt.Begin, update)
+}
 
+func (r *updateRequester) checkConflict(expected int64, affected int64) error {
+	if affected < expected {
+		return &ErrUpdateConflict{
+			ErrorInfo: w3sql.ErrorInfo{Field: r.cfg.VersionCol, Path: "Update"},
+			Expected:  expected,
+			Affected:  affected,
+		}
+	}
 	return nil
 }
 
+func (r *updateRequester) HandleWhere(q *w3sql.Query) (int64, error) {
+	defer r.cfg.OnPanic()
+
+	if err := q.ValidateUpdateWhere(r.cfg.Rules); err != nil {
+		return 0, err
+	}
+
+	var tr []w3sql.ValueTransform
+	if r.opt.Transform != nil {
+		tr = []w3sql.ValueTransform{r.opt.Transform}
+	}
+	sq, err := q.CompileUpdateWhere(r.cfg.SQLDialect, r.cfg.FieldMap, tr...)
+	if err != nil {
+		return 0, err
+	}
+	if sq == nil {
+		panic("[w3req.UpdateRequester.HandleWhere]: no query")
+	}
+
+	r.connect()
+
+	if err := sq.SetServerColumns(r.cfg.ServerColumns, q.ServerParams); err != nil {
+		return 0, err
+	}
+	t, err := sq.SQL(r.cfg.AllSQL)
+	if err != nil {
+		return 0, err
+	}
+
+	if r.cfg.DumpRequests && r.opt.Logger != nil {
+		r.opt.Logger.LogSQL("Update SQL:", t[0].Code, t[0].Params)
+	}
+
+	tooMany := &w3sql.ErrLimitExceeded{
+		ErrorInfo: w3sql.ErrorInfo{Path: "UpdateWhere"},
+		Limit:     w3sql.LimitMaxRows,
+		Max:       int(r.cfg.MaxRows),
+	}
+	var affected int64
+	update := func(conn DB) error {
+		// подсчет заранее отвергает большое обновление, не выполняя его
+		if r.cfg.MaxRows > 0 && r.cfg.Table != "" {
+			countSQL := "select count(*) from " + r.cfg.Table + "\n" + t[0].Conditions
+			n, err := r.stmts.SelectInt(conn, r.cfg.SQLDialect, countSQL, t[0].Params)
+			if err != nil {
+				return fmt.Errorf("Update count error: %s\nSQL: %s\nParams:%+v\n", err.Error(), countSQL, t[0].Params)
+			}
+			if n > r.cfg.MaxRows {
+				return tooMany
+			}
+		}
+
+		res, err := r.stmts.Exec(conn, r.cfg.SQLDialect, t[0].Code, t[0].Params)
+		if err != nil {
+			return fmt.Errorf(
+				"Update error: %s\nSQL: %s\nParams:%+v\n",
+				err.Error(),
+				t[0].Code, t[0].Params,
+			)
+		}
+		if affected, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		// строки могли добавиться после подсчета, поэтому проверяется и число обновленных
+		if r.cfg.MaxRows > 0 && affected > r.cfg.MaxRows {
+			return tooMany
+		}
+		return nil
+	}
+
+	if r.cfg.MaxRows > 0 {
+		if r.opt.Begin == nil {
+			return 0, errors.New("[w3req.UpdateRequester.HandleWhere] Begin is mandatory for MaxRows")
+		}
+		err = inTx(r.opt.Begin, update)
+	} else {
+		err = update(r.conn)
+	}
+	r.invalidate()
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+func (r *updateRequester) connect() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.conn == nil {
+		r.conn = r.opt.DB()
+	}
+	if r.conn == nil {
+		panic("[w3req.UpdateRequester.connect]: DB is nil")
+	}
+}
+
+func (r *updateRequester) invalidate() {
+	if r.cfg.Table != "" {
+		InvalidateTables(r.cfg.Table)
+	}
+}
+
 func (r *updateRequester) SetDumpRequests(v bool) {
 	r.cfg.DumpRequests = v
 }
+
+func (r *updateRequester) StmtCacheStats() StmtCacheStats {
+	return r.stmts.Stats()
+}
@@ -0,0 +1,186 @@
+package w3req
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/algebrain/w3/w3sql"
+)
+
+type UpsertConfig struct {
+	AllSQL       *w3sql.SQLString
+	FieldMap     map[string]string
+	ConflictCols []string //колонки SQL уникального ключа, для mysql не нужны
+	KeepCols     []string //колонки SQL, которые не перезаписываются при конфликте
+	SQLDialect   string
+	DumpRequests bool
+	OnPanic      func()
+
+	StmtCacheSize int      //размер кеша подготовленных запросов, 0 - без кеша
+	Table         string   //после успешной записи сбрасывается кеш результатов для этой таблицы
+	Returning     []string //колонки SQL для returning, только postgres и sqlite 3.35+
+
+	Rules         w3sql.WriteRules     //проверки значений до компиляции, все ошибки возвращаются в ErrRowValidation
+	ServerColumns []w3sql.ServerColumn //колонки, которые задает сервер, при конфликте перезаписываются, если их нет в KeepCols
+}
+
+type UpsertOptions struct {
+	Logger    Logger
+	DB        func() DB
+	Transform w3sql.ValueTransform
+}
+
+type UpsertRequester interface {
+	InitOnce(f func() *UpsertOptions)
+	Handle(q *w3sql.Query) error
+	// выполняет запрос с returning и записывает затронутые строки в holder (*[]T)
+	HandleReturning(q *w3sql.Query, holder any) error
+	SetDumpRequests(v bool)
+	StmtCacheStats() StmtCacheStats
+}
+
+type upsertRequester struct {
+	cfg      *UpsertConfig
+	opt      *UpsertOptions
+	mut      sync.Mutex
+	initOnce sync.Once
+	conn     DB
+	stmts    *stmtCache
+}
+
+func NewUpsertRequester(cfg *UpsertConfig) (UpsertRequester, error) {
+	if cfg.OnPanic == nil {
+		return nil, errors.New("[w3req.UpsertRequester.NewUpsertRequester] OnPanic is mandatory")
+	}
+	return &upsertRequester{
+		cfg:   cfg,
+		mut:   sync.Mutex{},
+		stmts: newStmtCache(cfg.StmtCacheSize),
+	}, nil
+}
+
+func (r *upsertRequester) InitOnce(f func() *UpsertOptions) {
+	defer r.cfg.OnPanic()
+	r.initOnce.Do(func() {
+		if r.opt != nil {
+			return
+		}
+		opt := f()
+		if opt.DB == nil {
+			panic("[w3req.UpsertRequester.NewUpsertRequester] DB is mandatory")
+		}
+		r.opt = opt
+	})
+}
+
+func (r *upsertRequester) prepare(q *w3sql.Query) (*w3sql.SQLQuery, error) {
+	if err := q.ValidateInsert(r.cfg.Rules); err != nil {
+		return nil, err
+	}
+
+	var tr []w3sql.ValueTransform
+	if r.opt.Transform != nil {
+		tr = []w3sql.ValueTransform{r.opt.Transform}
+	}
+	sq, err := q.CompileUpsert(
+		r.cfg.SQLDialect,
+		r.cfg.FieldMap,
+		r.cfg.ConflictCols,
+		r.cfg.KeepCols,
+		tr...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if sq == nil {
+		panic("[w3req.UpsertRequester.prepare]: no query")
+	}
+
+	func() {
+		r.mut.Lock()
+		defer r.mut.Unlock()
+		if r.conn == nil {
+			r.conn = r.opt.DB()
+		}
+	}()
+
+	if r.conn == nil {
+		panic("[w3req.UpsertRequester.prepare]: DB is nil")
+	}
+
+	if err := sq.SetServerColumns(r.cfg.ServerColumns, q.ServerParams); err != nil {
+		return nil, err
+	}
+	sq.Returning = r.cfg.Returning
+	t, err := sq.SQL(r.cfg.AllSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cfg.DumpRequests && r.opt.Logger != nil {
+		r.opt.Logger.LogSQL("Upsert SQL:", t[0].Code, t[0].Params)
+	}
+	return &t[0], nil
+}
+
+func (r *upsertRequester) Handle(q *w3sql.Query) error {
+	defer r.cfg.OnPanic()
+
+	t, err := r.prepare(q)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.stmts.Exec(r.conn, r.cfg.SQLDialect, t.Code, t.Params)
+	if err != nil {
+		return fmt.Errorf(
+			"Upsert error: %s\nSQL: %s\nParams:%+v\n",
+			err.Error(),
+			t.Code, t.Params,
+		)
+	}
+
+	r.invalidate()
+	return nil
+}
+
+func (r *upsertRequester) HandleReturning(q *w3sql.Query, holder any) error {
+	defer r.cfg.OnPanic()
+
+	if len(r.cfg.Returning) == 0 {
+		return errors.New("[w3req.UpsertRequester.HandleReturning] Returning is not set")
+	}
+
+	t, err := r.prepare(q)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.stmts.Select(r.conn, r.cfg.SQLDialect, holder, t.Code, t.Params)
+	if err != nil {
+		return fmt.Errorf(
+			"Upsert error: %s\nSQL: %s\nParams:%+v\n",
+			err.Error(),
+			t.Code, t.Params,
+		)
+	}
+
+	r.invalidate()
+	return nil
+}
+
+func (r *upsertRequester) invalidate() {
+	if r.cfg.Table != "" {
+		InvalidateTables(r.cfg.Table)
+	}
+}
+
+func (r *upsertRequester) SetDumpRequests(v bool) {
+	r.cfg.DumpRequests = v
+}
+
+func (r *upsertRequester) StmtCacheStats() StmtCacheStats {
+	return r.stmts.Stats()
+}
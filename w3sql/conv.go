@@ -1,24 +1,24 @@
 package w3sql
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
-var AllowNaN = false
+var errNaN = errors.New("w3sql: NaN and Inf values are not allowed")
 
-// иногда база и внутренняя логика портится, если f = NaN
-func fixNaN(f float64) float64 {
-	if AllowNaN {
-		return f
-	}
-	if math.IsNaN(f) {
-		return 0
+// база и внутренняя логика портятся, если f = NaN или Inf, поэтому такие значения отвергаются
+func checkNaN(f float64) (float64, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errNaN
 	}
-	return f
+	return f, nil
 }
 
 func getString(v any) string {
@@ -32,32 +32,189 @@ func getString(v any) string {
 func getFloat(v any) (float64, error) {
 	switch vt := v.(type) {
 	case float64:
-		return vt, nil
+		return checkNaN(vt)
 	case float32:
-		return float64(vt), nil
+		return checkNaN(float64(vt))
 	case int64:
 		return float64(vt), nil
 	case int:
 		return float64(vt), nil
+	case int32:
+		return float64(vt), nil
+	case json.Number:
+		f, err := strconv.ParseFloat(string(vt), 64)
+		if err != nil {
+			return 0, err
+		}
+		return checkNaN(f)
 	case string:
-		return strconv.ParseFloat(vt, 64)
+		f, err := strconv.ParseFloat(vt, 64)
+		if err != nil {
+			return 0, err
+		}
+		return checkNaN(f)
 	}
 	return 0, errors.New("unknown type of floating point parameter")
 }
 
-func convNumber(t any) (float64, error) {
-	v, err := getFloat(t)
-	if err != nil {
+func getInt(v any) (int64, error) {
+	switch vt := v.(type) {
+	case int64:
+		return vt, nil
+	case int:
+		return int64(vt), nil
+	case int32:
+		return int64(vt), nil
+	case float64:
+		// float64 еще без потери точности, если целое и не больше 2^53
+		if _, err := checkNaN(vt); err != nil {
+			return 0, err
+		}
+		if vt != math.Trunc(vt) || math.Abs(vt) > 1<<53 {
+			return 0, errors.New("w3sql: not an integer value " + fmt.Sprint(vt))
+		}
+		return int64(vt), nil
+	case float32:
+		return getInt(float64(vt))
+	case json.Number:
+		return parseInt(string(vt))
+	case string:
+		return parseInt(vt)
+	}
+	return 0, errors.New("unknown type of integer parameter")
+}
+
+func parseInt(s string) (int64, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return i, nil
+	}
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil {
 		return 0, err
 	}
-	return fixNaN(v), nil
+	return getInt(f)
+}
+
+var decimalRe = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// десятичное число передается в SQL строкой как есть, без округления через float64
+func getDecimal(v any) (string, error) {
+	var s string
+	switch vt := v.(type) {
+	case json.Number:
+		s = string(vt)
+	case string:
+		s = vt
+	case int64, int, int32:
+		s = fmt.Sprint(vt)
+	case float64:
+		if _, err := checkNaN(vt); err != nil {
+			return "", err
+		}
+		s = strconv.FormatFloat(vt, 'f', -1, 64)
+	default:
+		return "", errors.New("unknown type of decimal parameter")
+	}
+	if !decimalRe.MatchString(s) {
+		return "", errors.New("w3sql: not a decimal value " + s)
+	}
+	return s, nil
+}
+
+// convNumber целый литерал из JSON или строки остается точным (int64 или строка цифр),
+// чтобы ID и суммы больше 2^53 с типом number и float не округлялись через float64
+func convNumber(t any) (any, error) {
+	var s string
+	switch vt := t.(type) {
+	case json.Number:
+		s = string(vt)
+	case string:
+		s = vt
+	default:
+		return getFloat(t)
+	}
+	if integerRe.MatchString(s) {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		return strings.TrimPrefix(s, "+"), nil
+	}
+	return getFloat(t)
+}
+
+var integerRe = regexp.MustCompile(`^[+-]?[0-9]+$`)
+
+// json.Number из JSON запроса превращается в int64, если это целое число, в float64, если
+// float64 хранит все его значащие цифры, иначе остается точной строкой
+func normalizeNumber(v any) any {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil && exactFloat(string(n), f) {
+		return f
+	}
+	return string(n)
+}
+
+// exactFloat true, если десятичная запись s из не более чем 15 значащих цифр,
+// такая запись переживает float64 без изменений
+func exactFloat(s string, f float64) bool {
+	mantissa := s
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa = s[:i]
+	}
+	digits := strings.Trim(strings.NewReplacer("-", "", "+", "", ".", "").Replace(mantissa), "0")
+	if f == 0 {
+		return digits == ""
+	}
+	return len(digits) <= 15 && math.Abs(f) >= 0x1p-1022
+}
+
+// exactNumber число без потери точности: json.Number - как в normalizeNumber, остальное - через float64
+func exactNumber(v any) (any, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return getFloat(v)
+	}
+	if _, err := getFloat(n); err != nil {
+		return nil, err
+	}
+	return normalizeNumber(n), nil
+}
+
+// transformArg число из JSON передается в ValueTransform и DeleteTransform как float64, как и до json.Number
+func transformArg(v any) any {
+	if n, ok := v.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// exactIfUnchanged если transform вернул число orig тем же float64, в SQL идет точное значение из JSON
+func exactIfUnchanged(orig any, v any) any {
+	n, ok := orig.(json.Number)
+	if !ok {
+		return v
+	}
+	f, ok := v.(float64)
+	if nf, err := n.Float64(); ok && err == nil && f == nf {
+		return n
+	}
+	return v
 }
 
 func dateFmt(d any) (string, error) {
 	date := getString(d)
 	var r time.Time
 	var err error
-	for _, fmt := range []string{"2006/1/2", "2/1/2006", "02.01.2006", "2.1.2006", "2006-Jan-02"} {
+	for _, fmt := range []string{"2006/1/2", "2/1/2006", "02.01.2006", "2.1.2006", "2006-Jan-02", "2006-01-02"} {
 		if r, err = time.Parse(fmt, date); err == nil {
 			return r.Format("2006-01-02"), nil
 		}
@@ -69,7 +226,7 @@ func dateTimeFmt(d any) (string, error) {
 	date := getString(d)
 	var r time.Time
 	var err error
-	for _, fmt := range []string{"2006/1/2 15:04:05", "2/1/2006 15:04:05", "02.01.2006 15:04:05", "2.1.2006 15:04:05", "2006-Jan-02 15:04:05"} {
+	for _, fmt := range []string{"2006/1/2 15:04:05", "2/1/2006 15:04:05", "02.01.2006 15:04:05", "2.1.2006 15:04:05", "2006-Jan-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
 		if r, err = time.Parse(fmt, date); err == nil {
 			return r.Format("2006-01-02 15:04:05"), nil
 		}
@@ -81,16 +238,21 @@ func convRange(t []any, tp string) (rng struct {
 	from any
 	to   any
 }, err error) {
+	if len(t) != 2 {
+		err = errors.New("w3sql: range of two values expected")
+		return
+	}
 	switch tp {
-	case "date":
-		rng.from, err = dateFmt(t[0])
-		rng.to, err = dateFmt(t[1])
-	case "datetime":
-		rng.from, err = dateTimeFmt(t[0])
-		rng.to, err = dateTimeFmt(t[1])
 	case "numeric":
-		rng.from, err = getFloat(t[0])
-		rng.to, err = getFloat(t[1])
+		if rng.from, err = exactNumber(t[0]); err != nil {
+			return
+		}
+		rng.to, err = exactNumber(t[1])
+	case "date", "datetime", "number", "int", "float", "decimal":
+		if rng.from, err = convValueElem(t[0], tp); err != nil {
+			return
+		}
+		rng.to, err = convValueElem(t[1], tp)
 	default:
 		rng.from = t[0]
 		rng.to = t[1]
@@ -109,14 +271,18 @@ func convValueElem(t any, tp string) (any, error) {
 	switch tp {
 	case "text", "textis", "list", "string":
 		return fmt.Sprint(t), nil
-	case "number", "int", "float":
+	case "number", "float":
 		return convNumber(t)
+	case "int":
+		return getInt(t)
+	case "decimal":
+		return getDecimal(t)
 	case "date":
 		return dateFmt(t)
 	case "datetime":
 		return dateTimeFmt(t)
 	default:
-		return nil, errors.New("w3sql: '" + tp + "' is not supported")
+		return nil, &ErrUnsupportedType{Type: tp}
 	}
 }
 
@@ -125,14 +291,14 @@ func convValue_(ts []any, tp string) (any, error) {
 		return nil, errors.New("no value")
 	}
 	switch tp {
-	case "text", "string", "number", "int", "float", "date", "datetime", "textis":
+	case "text", "string", "number", "int", "float", "decimal", "date", "datetime", "textis":
 		return convValueElem(ts[0], tp)
 	case "list", "bool":
 		return convList(ts)
 	case "enum":
-		return nil, errors.New("w3sql: 'enum' is not supported yet")
+		return nil, &ErrUnsupportedType{Type: tp}
 	default:
-		return nil, errors.New("w3sql: '" + tp + "' is not supported")
+		return nil, &ErrUnsupportedType{Type: tp}
 	}
 }
 
@@ -142,3 +308,8 @@ func convValue(ts any, tp string) (any, error) {
 	}
 	return convValue_([]any{ts}, tp)
 }
+
+// ConvValue приводит значение к типу tp по тем же правилам, что и значения условий Search
+func ConvValue(v any, tp string) (any, error) {
+	return convValueElem(v, tp)
+}
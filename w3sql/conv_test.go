@@ -0,0 +1,131 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestBigIntAndDecimal(t *testing.T) {
+	s := `{
+		"Search": {
+			"Op": "and",
+			"Query": [
+				{"Col": "id", "Type": "int", "Val": 9007199254740993, "Op": "=="},
+				{"Col": "price", "Type": "decimal", "Val": 12345678901234.0000001, "Op": ">="}
+			]
+		}
+	}`
+	var q Query
+	err := json.Unmarshal([]byte(s), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cq, err := q.CompileSelect("sqlite", map[string]string{"id": "", "price": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x, ok := cq.SQLParams["sqv0"].(int64); !ok || x != 9007199254740993 {
+		t.Fatalf("exact int64 expected for id, got <%v> <%T>", cq.SQLParams["sqv0"], cq.SQLParams["sqv0"])
+	}
+	if x, ok := cq.SQLParams["sqv1"].(string); !ok || x != "12345678901234.0000001" {
+		t.Fatalf("exact decimal string expected for price, got <%v> <%T>", cq.SQLParams["sqv1"], cq.SQLParams["sqv1"])
+	}
+
+	// целые литералы типов number и float тоже точные, дробные - float64
+	for _, c := range []struct {
+		tp       string
+		val      any
+		expected any
+	}{
+		{"number", json.Number("9007199254740993"), int64(9007199254740993)},
+		{"float", "9007199254740993", int64(9007199254740993)},
+		{"number", json.Number("123456789012345678901234"), "123456789012345678901234"},
+		{"number", json.Number("1.5"), 1.5},
+		{"float", 2.0, 2.0},
+	} {
+		if v, err := ConvValue(c.val, c.tp); err != nil || v != c.expected {
+			t.Fatalf("%s %v: expected <%v> <%T>, got <%v> <%T> %v", c.tp, c.val, c.expected, c.expected, v, v, err)
+		}
+	}
+}
+
+func TestConvValueRejects(t *testing.T) {
+	for _, c := range []struct {
+		val any
+		tp  string
+	}{
+		{math.NaN(), "number"},
+		{math.Inf(1), "float"},
+		{"NaN", "number"},
+		{"-Inf", "float"},
+		{math.NaN(), "int"},
+		{1.5, "int"},
+		{"12a", "decimal"},
+		{math.Inf(-1), "decimal"},
+	} {
+		if v, err := convValue(c.val, c.tp); err == nil {
+			t.Fatalf("error expected for %v of type %s, got %v", c.val, c.tp, v)
+		}
+	}
+}
+
+func TestWriteNumbers(t *testing.T) {
+	s := `{
+		"Insert": {
+			"Cols": ["id", "price", "amount", "score"],
+			"Values": [[9007199254740993, 0.1, 12345678901234.0000001, 5]]
+		}
+	}`
+	var q Query
+	err := json.Unmarshal([]byte(s), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// transform получает числа как float64, неизмененные числа остаются точными
+	var args []any
+	transform := func(field string, value any) (any, error) {
+		args = append(args, value)
+		if field == "score" {
+			return value.(float64) * 2, nil
+		}
+		return value, nil
+	}
+
+	iq, err := q.CompileInsert("sqlite", map[string]string{"id": "", "price": "", "amount": "", "score": ""}, transform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range args {
+		if _, ok := a.(float64); !ok {
+			t.Fatalf("float64 expected in transform, got <%v> <%T>", a, a)
+		}
+	}
+	for name, expected := range map[string]any{
+		"uiid0":     int64(9007199254740993),
+		"uiprice1":  0.1,
+		"uiamount2": "12345678901234.0000001",
+		"uiscore3":  10.0,
+	} {
+		if v := iq.SQLParams[name]; v != expected {
+			t.Fatalf("%v <%T> expected for %s, got <%v> <%T>", expected, expected, name, v, v)
+		}
+	}
+}
+
+func TestNumericRange(t *testing.T) {
+	rng := []any{json.Number("9007199254740993"), json.Number("12345678901234.0000001")}
+	r, err := convRange(rng, "numeric")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.from != int64(9007199254740993) || r.to != "12345678901234.0000001" {
+		t.Fatalf("exact range expected, got <%v> <%T>, <%v> <%T>", r.from, r.from, r.to, r.to)
+	}
+	if _, err := convRange([]any{json.Number("1"), math.NaN()}, "numeric"); err == nil {
+		t.Fatal("NaN expected to be rejected")
+	}
+}
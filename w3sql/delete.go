@@ -5,15 +5,17 @@ import (
 )
 
 type DeletePair struct {
-	TableName string
-	IDName    string
+	TableName     string
+	IDName        string
+	SoftDeleteCol string //если задана, то вместо delete выполняется update <table> set <col> = current_timestamp
 }
 
 type TableDelete struct {
-	TableName string
-	IDName    string
-	ToDelete  []string
-	SQLParams map[string]any
+	TableName     string
+	IDName        string
+	SoftDeleteCol string
+	ToDelete      []string
+	SQLParams     map[string]any
 }
 
 type DeleteQuery struct {
@@ -21,6 +23,7 @@ type DeleteQuery struct {
 	Tables []*TableDelete
 }
 
+// DeleteTransform id из JSON получает как float64, как и ValueTransform
 type DeleteTransform func(tableName string, idName string, id any) (any, error)
 
 func (cs *compilerSession) compileDeletePair(
@@ -30,6 +33,9 @@ func (cs *compilerSession) compileDeletePair(
 	transform ...DeleteTransform,
 ) (string, bool, error) {
 	v := id
+	if len(transform) > 0 {
+		v = transformArg(id)
+	}
 	var err error
 
 	for _, fn := range transform {
@@ -42,9 +48,9 @@ func (cs *compilerSession) compileDeletePair(
 		}
 	}
 
-	alias := fmt.Sprintf("ui%d", cs.varCounter)
+	v = exactIfUnchanged(id, v)
+	alias := cs.param(fmt.Sprintf("ui%d", cs.varCounter), normalizeNumber(v))
 	cs.varCounter++
-	cs.params[alias] = v
 	return alias, true, nil
 }
 
@@ -65,8 +71,9 @@ func (q *Query) CompileDelete(
 
 	for i, tab := range tables {
 		result.Tables[i] = &TableDelete{
-			IDName:    tab.IDName,
-			TableName: tab.TableName,
+			IDName:        tab.IDName,
+			TableName:     tab.TableName,
+			SoftDeleteCol: tab.SoftDeleteCol,
 		}
 
 		cs := &compilerSession{
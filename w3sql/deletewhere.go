@@ -0,0 +1,72 @@
+package w3sql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DeleteWhere удаляет все строки, подходящие под Search запроса
+type DeleteWhere struct {
+	Confirm bool //должен быть true, иначе запрос не компилируется
+}
+
+type DeleteWhereQuery struct {
+	CompiledQueryParams
+	Table      *DeletePair
+	Conditions string
+}
+
+// CompileDeleteWhere компилирует DeleteWhere в delete from <table> where,
+// условие берется из Search и обязательно, при мягком удалении уже удаленные строки не затрагиваются
+func (q *Query) CompileDeleteWhere(
+	sqlSyntax string,
+	fieldmap map[string]string,
+	table *DeletePair,
+) (*DeleteWhereQuery, error) {
+	if q.DeleteWhere == nil {
+		return nil, nil
+	}
+
+	if !q.DeleteWhere.Confirm {
+		return nil, &ErrBadValue{
+			ErrorInfo: ErrorInfo{Path: "DeleteWhere.Confirm"},
+			Err:       errors.New("w3sql: delete by condition is not confirmed"),
+		}
+	}
+	if emptyCondition(q.Search) {
+		return nil, &ErrBadValue{
+			ErrorInfo: ErrorInfo{Path: "Search"},
+			Err:       errors.New("w3sql: delete by condition requires Search"),
+		}
+	}
+
+	cs := &compilerSession{
+		sqlSyntax: sqlSyntax,
+		fieldmap:  fieldmap,
+		params:    map[string]any{},
+		path:      "Search",
+	}
+	cond, err := q.Search.compile(cs)
+	if err != nil {
+		return nil, err
+	}
+	if table.SoftDeleteCol != "" {
+		cond = fmt.Sprintf("(%s) and %s is null", cond, table.SoftDeleteCol)
+	}
+
+	return &DeleteWhereQuery{
+		Table:      table,
+		Conditions: cond,
+		CompiledQueryParams: CompiledQueryParams{
+			Params:    q.Params,
+			SQLParams: cs.params,
+		},
+	}, nil
+}
+
+func (q *DeleteWhereQuery) SQL() ([]SQLQuery, error) {
+	result := SQLQuery{Params: q.SQLParams}
+	result.Conditions = "where " + q.Conditions
+	result.Code = deleteFrom(q.Table.TableName, q.Table.SoftDeleteCol) + "\n" + result.Conditions
+	return []SQLQuery{result}, nil
+}
@@ -0,0 +1,75 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompileSoftDelete(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{"Delete": [1, 2]}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dq, err := q.CompileDelete("sqlite", []*DeletePair{{
+		TableName:     "students",
+		IDName:        "studentID",
+		SoftDeleteCol: "deleted_at",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, err := dq.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQS := `update students set deleted_at = current_timestamp where studentID in (:ui0,:ui1) and deleted_at is null`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+}
+
+func TestCompileDeleteWhere(t *testing.T) {
+	fieldmap := map[string]string{"age": ""}
+
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"DeleteWhere": {"Confirm": true},
+		"Search": {"Col": "age", "Val": 30, "Op": ">", "Type": "int"}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dq, err := q.CompileDeleteWhere("sqlite", fieldmap, &DeletePair{TableName: "students"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, _ := dq.SQL()
+	if !EqualSQLStrings(`delete from students where (age>:sqv0)`, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code)
+	}
+
+	dq, err = q.CompileDeleteWhere("sqlite", fieldmap, &DeletePair{TableName: "students", SoftDeleteCol: "deleted_at"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, _ = dq.SQL()
+	expectedQS := `update students set deleted_at = current_timestamp
+where ((age>:sqv0)) and deleted_at is null`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+
+	q.DeleteWhere.Confirm = false
+	if _, err := q.CompileDeleteWhere("sqlite", fieldmap, &DeletePair{TableName: "students"}); err == nil {
+		t.Fatal("expected error for unconfirmed delete")
+	}
+	q.DeleteWhere.Confirm = true
+	q.Search = nil
+	if _, err := q.CompileDeleteWhere("sqlite", fieldmap, &DeletePair{TableName: "students"}); err == nil {
+		t.Fatal("expected error for delete without Search")
+	}
+}
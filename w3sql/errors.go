@@ -0,0 +1,121 @@
+package w3sql
+
+import (
+	"fmt"
+)
+
+// ErrorInfo указывает на условие запроса, из-за которого не удалась компиляция
+type ErrorInfo struct {
+	Field string
+	Op    string
+	Path  string // путь в JSON запроса, например Search.Query[1].Val
+}
+
+func (e *ErrorInfo) Info() *ErrorInfo {
+	return e
+}
+
+// QueryError общий интерфейс ошибок компиляции, достается через errors.As
+type QueryError interface {
+	error
+	Info() *ErrorInfo
+	Kind() string
+}
+
+type ErrUnknownField struct {
+	ErrorInfo
+}
+
+func (e *ErrUnknownField) Error() string {
+	return "w3sql: no such field name " + e.Field
+}
+
+func (e *ErrUnknownField) Kind() string {
+	return "unknown_field"
+}
+
+type ErrMissingField struct {
+	ErrorInfo
+}
+
+func (e *ErrMissingField) Error() string {
+	return "w3sql: field " + e.Field + " not found"
+}
+
+func (e *ErrMissingField) Kind() string {
+	return "missing_field"
+}
+
+// ErrForbiddenField клиент прислал колонку, которую задает сервер
+type ErrForbiddenField struct {
+	ErrorInfo
+}
+
+func (e *ErrForbiddenField) Error() string {
+	return "w3sql: field " + e.Field + " is set by server"
+}
+
+func (e *ErrForbiddenField) Kind() string {
+	return "forbidden_field"
+}
+
+type ErrUnsupportedOperator struct {
+	ErrorInfo
+}
+
+func (e *ErrUnsupportedOperator) Error() string {
+	return "w3sql: operator '" + e.Op + "' is not supported"
+}
+
+func (e *ErrUnsupportedOperator) Kind() string {
+	return "unsupported_operator"
+}
+
+type ErrUnsupportedType struct {
+	ErrorInfo
+	Type string
+}
+
+func (e *ErrUnsupportedType) Error() string {
+	return "w3sql: '" + e.Type + "' is not supported"
+}
+
+func (e *ErrUnsupportedType) Kind() string {
+	return "unsupported_type"
+}
+
+type ErrBadSortDirection struct {
+	ErrorInfo
+	Dir string
+}
+
+func (e *ErrBadSortDirection) Error() string {
+	return "w3sql: direction '" + e.Dir + "' is not supported"
+}
+
+func (e *ErrBadSortDirection) Kind() string {
+	return "bad_sort_direction"
+}
+
+type ErrBadValue struct {
+	ErrorInfo
+	Err error
+}
+
+func (e *ErrBadValue) Error() string {
+	switch {
+	case e.Err == nil:
+		return "w3sql: wrong value for field " + e.Field
+	case e.Field == "":
+		return "w3sql: " + e.Err.Error()
+	}
+	return fmt.Sprintf("w3sql: wrong value for field %s: %s", e.Field, e.Err.Error())
+}
+
+func (e *ErrBadValue) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrBadValue) Kind() string {
+	return "bad_value"
+}
@@ -0,0 +1,86 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+	fieldmap := map[string]string{"age": "", "name": ""}
+
+	for _, c := range []struct {
+		query string
+		kind  string
+		field string
+		op    string
+		path  string
+	}{
+		{
+			`{"Search": {"Op": "and", "Query": [
+				{"Col": "age", "Type": "int", "Val": 23, "Op": "<="},
+				{"Op": "or", "Query": [
+					{"Col": "name", "Type": "string", "Val": "Bob", "Op": "contains"},
+					{"Col": "surname", "Type": "string", "Val": "Alice", "Op": "starts with"}
+				]}
+			]}}`,
+			"unknown_field", "surname", "starts with", "Search.Query[1].Query[1]",
+		},
+		{
+			`{"Search": {"Col": "age", "Type": "int", "Val": 23, "Op": "~"}}`,
+			"unsupported_operator", "age", "~", "Search",
+		},
+		{
+			`{"Search": {"Op": "xor", "Query": [{"Col": "age", "Type": "int", "Val": 23, "Op": "<="}]}}`,
+			"unsupported_operator", "", "xor", "Search",
+		},
+		{
+			`{"Search": {"Op": "and", "Query": [{"Col": "age", "Type": "int", "Val": "abc", "Op": "<="}]}}`,
+			"bad_value", "age", "<=", "Search.Query[0]",
+		},
+		{
+			`{"Search": {"Col": "age", "Type": "enum", "Val": 23, "Op": "=="}}`,
+			"unsupported_type", "age", "==", "Search",
+		},
+		{
+			`{"Sort": [{"Col": "age", "Dir": "asc"}, {"Col": "name", "Dir": "up"}]}`,
+			"bad_sort_direction", "name", "", "Sort[1]",
+		},
+	} {
+		var q Query
+		err := json.Unmarshal([]byte(c.query), &q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = q.CompileSelect("sqlite", fieldmap)
+		var qe QueryError
+		if !errors.As(err, &qe) {
+			t.Fatalf("QueryError expected for %s, got %v", c.query, err)
+		}
+		info := qe.Info()
+		if qe.Kind() != c.kind || info.Field != c.field || info.Op != c.op || info.Path != c.path {
+			t.Fatalf(
+				"unexpected error %q: kind=%s field=%s op=%s path=%s",
+				qe.Error(), qe.Kind(), info.Field, info.Op, info.Path,
+			)
+		}
+	}
+}
+
+func TestCompileErrorsAs(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(updateJSON), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = q.CompileUpdate("sqlite", map[string]string{"name": "", "age": ""}, "id")
+	var uf *ErrUnknownField
+	if !errors.As(err, &uf) {
+		t.Fatal("ErrUnknownField expected, got", err)
+	}
+	if uf.Field != "score" || uf.Path != "Update.Cols[2]" {
+		t.Fatalf("unexpected field %s or path %s", uf.Field, uf.Path)
+	}
+}
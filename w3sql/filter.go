@@ -0,0 +1,586 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Текстовый язык фильтров, например
+//
+//	age > 20 and (name starts "pet" or grade between 60..80)
+//
+// условие: колонка[:тип] оператор значение, тип по умолчанию выводится из значения:
+// целое - int, дробное - number, строка в кавычках - text, 2024-01-31 - date, 2024-01-31T10:00:00 - datetime.
+// Операторы: = != <> < <= > >= starts contains ends in (a, b) not in (a, b) between a..b,
+// любой другой оператор условия записывается в обратных кавычках, например grade `>= or 0` 5.
+// name = null и name != null - проверки is null и is not null, с другими операторами null не допускается.
+// Логика: not, and, or (по убыванию приоритета) и скобки.
+
+// ErrSyntax ошибка разбора фильтра, Pos - номер символа (руны) от 0
+type ErrSyntax struct {
+	ErrorInfo
+	Pos int
+	Msg string
+}
+
+func (e *ErrSyntax) Error() string {
+	return fmt.Sprintf("w3sql: syntax error at %d: %s", e.Pos, e.Msg)
+}
+
+func (e *ErrSyntax) Kind() string {
+	return "syntax_error"
+}
+
+type filterTokenKind int
+
+const (
+	ftEOF filterTokenKind = iota
+	ftIdent
+	ftNumber
+	ftString
+	ftDate
+	ftOp    // = != <> < <= > >= и оператор в обратных кавычках
+	ftRange // ..
+	ftLParen
+	ftRParen
+	ftComma
+	ftColon
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	raw  bool //оператор в обратных кавычках
+	pos  int
+}
+
+var (
+	filterDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	filterDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(:\d{2})?$`)
+)
+
+func isFilterIdent(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && (unicode.IsDigit(r) || r == '.'))
+}
+
+func filterTokens(s string) ([]filterToken, error) {
+	rs := []rune(s)
+	var result []filterToken
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		start := i
+		tok := func(kind filterTokenKind, text string) {
+			result = append(result, filterToken{kind: kind, text: text, pos: start})
+		}
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tok(ftLParen, "(")
+			i++
+		case r == ')':
+			tok(ftRParen, ")")
+			i++
+		case r == ',':
+			tok(ftComma, ",")
+			i++
+		case r == ':':
+			tok(ftColon, ":")
+			i++
+		case r == '.' && i+1 < len(rs) && rs[i+1] == '.':
+			tok(ftRange, "..")
+			i += 2
+		case r == '=' || r == '<' || r == '>' || r == '!':
+			i++
+			if i < len(rs) && (rs[i] == '=' || (r == '<' && rs[i] == '>')) {
+				i++
+			}
+			op := string(rs[start:i])
+			if op == "!" {
+				return nil, &ErrSyntax{Pos: start, Msg: "unknown operator '!'"}
+			}
+			tok(ftOp, op)
+		case r == '`':
+			i++
+			for i < len(rs) && rs[i] != '`' {
+				i++
+			}
+			if i == len(rs) {
+				return nil, &ErrSyntax{Pos: start, Msg: "unterminated operator"}
+			}
+			i++
+			result = append(result, filterToken{kind: ftOp, text: string(rs[start+1 : i-1]), raw: true, pos: start})
+		case r == '"':
+			i++
+			for i < len(rs) && rs[i] != '"' {
+				if rs[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(rs) {
+				return nil, &ErrSyntax{Pos: start, Msg: "unterminated string"}
+			}
+			i++
+			text, err := strconv.Unquote(string(rs[start:i]))
+			if err != nil {
+				return nil, &ErrSyntax{Pos: start, Msg: "bad string"}
+			}
+			tok(ftString, text)
+		case unicode.IsDigit(r) || ((r == '-' || r == '+') && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			i++
+			for i < len(rs) {
+				c := rs[i]
+				// точка перед второй точкой - начало диапазона
+				if c == '.' && i+1 < len(rs) && rs[i+1] == '.' {
+					break
+				}
+				if !unicode.IsDigit(c) && c != '.' && c != '-' && c != ':' && c != 'T' && c != 'e' && c != 'E' {
+					break
+				}
+				// знак внутри числа допустим только после экспоненты или в дате
+				if (c == '-') && rs[i-1] != 'e' && rs[i-1] != 'E' && !unicode.IsDigit(rs[i-1]) {
+					break
+				}
+				i++
+			}
+			text := string(rs[start:i])
+			switch {
+			case filterDate.MatchString(text) || filterDateTime.MatchString(text):
+				tok(ftDate, text)
+			default:
+				if _, err := strconv.ParseFloat(text, 64); err != nil {
+					return nil, &ErrSyntax{Pos: start, Msg: "bad number '" + text + "'"}
+				}
+				tok(ftNumber, text)
+			}
+		case isFilterIdent(r, true):
+			for i < len(rs) && isFilterIdent(rs[i], false) {
+				i++
+			}
+			tok(ftIdent, string(rs[start:i]))
+		default:
+			return nil, &ErrSyntax{Pos: start, Msg: fmt.Sprintf("unexpected character '%c'", r)}
+		}
+	}
+	result = append(result, filterToken{kind: ftEOF, pos: len(rs)})
+	return result, nil
+}
+
+type filterParser struct {
+	tokens    []filterToken
+	i         int
+	condition func() (RawCondition, error) //разбор условия, по умолчанию parseCondition
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.i]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.i]
+	if t.kind != ftEOF {
+		p.i++
+	}
+	return t
+}
+
+// keyword проверяет, что текущая лексема - слово kw без учета регистра
+func (p *filterParser) keyword(kw string) bool {
+	t := p.peek()
+	return t.kind == ftIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *filterParser) errorf(t filterToken, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if t.kind == ftEOF {
+		msg += ", got end of filter"
+	} else {
+		msg += fmt.Sprintf(", got '%s'", t.text)
+	}
+	return &ErrSyntax{Pos: t.pos, Msg: msg}
+}
+
+func (p *filterParser) parseOr() (RawCondition, error) {
+	return p.parseLogic("OR", p.parseAnd)
+}
+
+func (p *filterParser) parseAnd() (RawCondition, error) {
+	return p.parseLogic("AND", p.parseNot)
+}
+
+// цепочки одной логики собираются в одно составное условие
+func (p *filterParser) parseLogic(op string, operand func() (RawCondition, error)) (RawCondition, error) {
+	c, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword(op) {
+		return c, nil
+	}
+	result := &CompoundCondition{Op: op, Query: []RawCondition{c}}
+	for p.keyword(op) {
+		p.next()
+		c, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		result.Query = append(result.Query, c)
+	}
+	return result, nil
+}
+
+func (p *filterParser) parseNot() (RawCondition, error) {
+	if p.keyword("not") {
+		p.next()
+		c, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not(c), nil
+	}
+	if p.peek().kind == ftLParen {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != ftRParen {
+			return nil, p.errorf(t, "expected ')'")
+		}
+		return c, nil
+	}
+	if p.condition != nil {
+		return p.condition()
+	}
+	return p.parseCondition()
+}
+
+var filterOps = map[string]string{
+	"=":        "==",
+	"!=":       "!=",
+	"<>":       "!=",
+	"<":        "<",
+	"<=":       "<=",
+	">":        ">",
+	">=":       ">=",
+	"starts":   "begins",
+	"contains": "contains",
+	"ends":     "ends",
+	"in":       "in",
+	"between":  "between",
+}
+
+func (p *filterParser) parseCondition() (RawCondition, error) {
+	t := p.next()
+	if t.kind != ftIdent {
+		return nil, p.errorf(t, "expected column name")
+	}
+	c := &AtomaryCondition{Col: t.text}
+
+	if p.peek().kind == ftColon {
+		p.next()
+		tp := p.next()
+		if tp.kind != ftIdent {
+			return nil, p.errorf(tp, "expected type")
+		}
+		c.Type = tp.text
+	}
+
+	t = p.next()
+	switch {
+	case t.kind == ftOp && t.raw:
+		c.Op = t.text
+	case t.kind == ftOp || t.kind == ftIdent:
+		op := strings.ToLower(t.text)
+		if op == "not" && p.keyword("in") {
+			p.next()
+			c.Op = "not in"
+		} else if c.Op = filterOps[op]; c.Op == "" {
+			return nil, &ErrSyntax{Pos: t.pos, Msg: "unknown operator '" + t.text + "'"}
+		}
+	default:
+		return nil, p.errorf(t, "expected operator")
+	}
+
+	var (
+		tp  string
+		err error
+	)
+	val := p.peek()
+	switch c.Op {
+	case "in", "not in":
+		c.Val, tp, err = p.parseList()
+	case "between":
+		c.Val, tp, err = p.parseRange(true)
+	default:
+		if p.peek().kind == ftLParen {
+			c.Val, tp, err = p.parseList()
+		} else {
+			c.Val, tp, err = p.parseRange(false)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNull(c, val); err != nil {
+		return nil, err
+	}
+	if c.Type == "" {
+		c.Type = tp
+	}
+	return c, nil
+}
+
+// checkNull null сравнивается только операторами = и != (is null и is not null)
+func checkNull(c *AtomaryCondition, val filterToken) error {
+	if c.Val == nil && c.Op != "==" && c.Op != "!=" {
+		return &ErrSyntax{Pos: val.pos, Msg: "null is allowed only with '=' and '!='"}
+	}
+	return nil
+}
+
+// значение и тип, который из него следует
+func (p *filterParser) parseValue() (any, string, error) {
+	t := p.next()
+	switch t.kind {
+	case ftString:
+		return t.text, "text", nil
+	case ftNumber:
+		if strings.ContainsAny(t.text, ".eE") {
+			return json.Number(t.text), "number", nil
+		}
+		return json.Number(t.text), "int", nil
+	case ftDate:
+		if strings.Contains(t.text, "T") {
+			return t.text, "datetime", nil
+		}
+		return t.text, "date", nil
+	case ftIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, "bool", nil
+		case "false":
+			return false, "bool", nil
+		case "null":
+			return nil, "", nil
+		}
+	}
+	return nil, "", p.errorf(t, "expected value")
+}
+
+func (p *filterParser) parseList() (any, string, error) {
+	if t := p.next(); t.kind != ftLParen {
+		return nil, "", p.errorf(t, "expected '('")
+	}
+	var (
+		list []any
+		tp   string
+	)
+	for {
+		vtok := p.peek()
+		v, vt, err := p.parseValue()
+		if err != nil {
+			return nil, "", err
+		}
+		if v == nil {
+			return nil, "", &ErrSyntax{Pos: vtok.pos, Msg: "null is not allowed in list"}
+		}
+		if tp == "" {
+			tp = vt
+		}
+		list = append(list, v)
+		t := p.next()
+		if t.kind == ftRParen {
+			return list, tp, nil
+		}
+		if t.kind != ftComma {
+			return nil, "", p.errorf(t, "expected ',' or ')'")
+		}
+	}
+}
+
+// одно значение или диапазон a..b, при required диапазон обязателен
+func (p *filterParser) parseRange(required bool) (any, string, error) {
+	start := p.peek()
+	from, tp, err := p.parseValue()
+	if err != nil {
+		return nil, "", err
+	}
+	if p.peek().kind != ftRange {
+		if required {
+			return nil, "", p.errorf(p.peek(), "expected '..'")
+		}
+		return from, tp, nil
+	}
+	p.next()
+	t := p.peek()
+	to, _, err := p.parseValue()
+	if err != nil {
+		return nil, "", err
+	}
+	if from == nil {
+		t = start
+	}
+	if from == nil || to == nil {
+		return nil, "", &ErrSyntax{Pos: t.pos, Msg: "null is not allowed in range"}
+	}
+	return []any{from, to}, tp, nil
+}
+
+// ParseFilter разбирает текстовый фильтр в дерево условий, пустой фильтр - nil
+func ParseFilter(s string) (RawCondition, error) {
+	tokens, err := filterTokens(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	if p.peek().kind == ftEOF {
+		return nil, nil
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != ftEOF {
+		return nil, p.errorf(t, "expected 'and', 'or' or end of filter")
+	}
+	return c, nil
+}
+
+// операторы условий в запись фильтра, остальные пишутся в обратных кавычках
+var filterOpNames = map[string]string{
+	"равен":            "=",
+	"is":               "=",
+	"==":               "=",
+	"не равен":         "!=",
+	"not is":           "!=",
+	"is not":           "!=",
+	"!=":               "!=",
+	"<":                "<",
+	"<=":               "<=",
+	">":                ">",
+	">=":               ">=",
+	"начинается с":     "starts",
+	"begins":           "starts",
+	"starts with":      "starts",
+	"содержит":         "contains",
+	"contains":         "contains",
+	"заканчивается на": "ends",
+	"ends":             "ends",
+	"ends with":        "ends",
+	"между":            "between",
+	"between":          "between",
+	"в списке":         "in",
+	"in":               "in",
+	"не в списке":      "not in",
+	"not in":           "not in",
+}
+
+// formatFilterValue запись значения и тип, который из нее выведет ParseFilter
+func formatFilterValue(v any) (string, string) {
+	switch t := v.(type) {
+	case nil:
+		return "null", ""
+	case bool:
+		return strconv.FormatBool(t), "bool"
+	case string:
+		if filterDate.MatchString(t) {
+			return t, "date"
+		}
+		if filterDateTime.MatchString(t) {
+			return t, "datetime"
+		}
+		return strconv.Quote(t), "text"
+	case json.Number:
+		return formatFilterNumber(t.String())
+	case float32:
+		return formatFilterNumber(strconv.FormatFloat(float64(t), 'f', -1, 32))
+	case float64:
+		return formatFilterNumber(strconv.FormatFloat(t, 'f', -1, 64))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(t), "int"
+	}
+	return strconv.Quote(fmt.Sprint(v)), "text"
+}
+
+func formatFilterNumber(s string) (string, string) {
+	if strings.ContainsAny(s, ".eE") {
+		return s, "number"
+	}
+	return s, "int"
+}
+
+func formatFilterAtom(c *AtomaryCondition) string {
+	op, ok := filterOpNames[strings.ToLower(c.Op)]
+	if !ok {
+		op = "`" + c.Op + "`"
+	}
+
+	var val, tp string
+	switch v := c.Val.(type) {
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			var et string
+			parts[i], et = formatFilterValue(e)
+			if i == 0 {
+				tp = et
+			}
+		}
+		if op == "between" && len(parts) == 2 {
+			val = parts[0] + ".." + parts[1]
+		} else {
+			val = "(" + strings.Join(parts, ", ") + ")"
+		}
+	default:
+		val, tp = formatFilterValue(v)
+	}
+
+	col := c.Col
+	if c.Type != tp && c.Type != "" {
+		col += ":" + c.Type
+	}
+	return col + " " + op + " " + val
+}
+
+func formatFilter(c RawCondition, parent string) string {
+	switch t := c.(type) {
+	case *AtomaryCondition:
+		return formatFilterAtom(t)
+	case *CompoundCondition:
+		op := strings.ToUpper(t.Op)
+		parts := make([]string, len(t.Query))
+		for i, q := range t.Query {
+			parts[i] = formatFilter(q, op)
+		}
+		var s string
+		if op == "NOT" {
+			inner := strings.Join(parts, " and ")
+			if len(parts) > 1 {
+				inner = "(" + inner + ")"
+			}
+			return "not " + inner
+		}
+		s = strings.Join(parts, " "+strings.ToLower(op)+" ")
+		// and внутри or скобок не требует, остальные вложенные составные условия - в скобках
+		if parent != "" && !(parent == "OR" && op == "AND") {
+			s = "(" + s + ")"
+		}
+		return s
+	}
+	return ""
+}
+
+// FormatFilter записывает дерево условий языком фильтров, ParseFilter разбирает результат
+// в то же дерево, только not с несколькими условиями становится not над and
+func FormatFilter(c RawCondition) string {
+	if c == nil {
+		return ""
+	}
+	return formatFilter(c, "")
+}
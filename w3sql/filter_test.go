@@ -0,0 +1,160 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func filterJSON(c RawCondition) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(c)
+	return strings.TrimSpace(b.String())
+}
+
+func TestParseFilter(t *testing.T) {
+	c, err := ParseFilter(`age > 20 and (name starts "pet" or grade between 60..80)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Op":"AND","Query":[` +
+		`{"Col":"age","Type":"int","Val":20,"Op":">"},` +
+		`{"Op":"OR","Query":[` +
+		`{"Col":"name","Type":"text","Val":"pet","Op":"begins"},` +
+		`{"Col":"grade","Type":"int","Val":[60,80],"Op":"between"}]}]}`
+	if s := filterJSON(c); s != expected {
+		t.Fatal("unexpected tree:\n", s, "\n", expected)
+	}
+
+	q := Query{Search: c}
+	cq, err := q.CompileSelect("sqlite", map[string]string{"age": "", "name": "", "grade": "score"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cq.Conditions != "((age>:sqv0) AND ((name LIKE :sqv1 || '%') OR (score>=:sqv2_1 AND score<=:sqv2_2)))" {
+		t.Fatal("unexpected SQL:", cq.Conditions)
+	}
+}
+
+func TestParseFilterForms(t *testing.T) {
+	cases := []struct {
+		filter string
+		json   string
+	}{
+		{`a = 1.5`, `{"Col":"a","Type":"number","Val":1.5,"Op":"=="}`},
+		{`a:decimal <> -2`, `{"Col":"a","Type":"decimal","Val":-2,"Op":"!="}`},
+		{`born >= 2001-02-03`, `{"Col":"born","Type":"date","Val":"2001-02-03","Op":">="}`},
+		{`at < 2001-02-03T10:11:12`, `{"Col":"at","Type":"datetime","Val":"2001-02-03T10:11:12","Op":"<"}`},
+		{`id NOT IN (1, 2, 3)`, `{"Col":"id","Type":"int","Val":[1,2,3],"Op":"not in"}`},
+		{`имя contains "ван \"x\""`, `{"Col":"имя","Type":"text","Val":"ван \"x\"","Op":"contains"}`},
+		{`grade ` + "`>= or 0`" + ` 5`, `{"Col":"grade","Type":"int","Val":5,"Op":">= or 0"}`},
+		{`not not a = 1`, `{"Op":"NOT","Query":[{"Op":"NOT","Query":[{"Col":"a","Type":"int","Val":1,"Op":"=="}]}]}`},
+		{`a = 1 or b = 2 and c = 3`, `{"Op":"OR","Query":[{"Col":"a","Type":"int","Val":1,"Op":"=="},` +
+			`{"Op":"AND","Query":[{"Col":"b","Type":"int","Val":2,"Op":"=="},{"Col":"c","Type":"int","Val":3,"Op":"=="}]}]}`},
+		{`name = null`, `{"Col":"name","Type":"","Val":null,"Op":"=="}`},
+		{`name:text != null`, `{"Col":"name","Type":"text","Val":null,"Op":"!="}`},
+	}
+	for _, c := range cases {
+		cond, err := ParseFilter(c.filter)
+		if err != nil {
+			t.Fatal(c.filter, err)
+		}
+		if s := filterJSON(cond); s != c.json {
+			t.Fatal("unexpected tree for", c.filter, "\n", s)
+		}
+	}
+
+	if c, err := ParseFilter("  "); c != nil || err != nil {
+		t.Fatal("empty filter expected, got", c, err)
+	}
+
+	// сравнение с null компилируется в is null и is not null
+	cond, err := ParseFilter(`name = null and age != null`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Query{Search: cond}
+	cq, err := q.CompileSelect("postgres", map[string]string{"name": "", "age": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualSQLStrings("((name is null) AND (age is not null))", cq.Conditions) || len(cq.SQLParams) != 0 {
+		t.Fatal("unexpected conditions", cq.Conditions, cq.SQLParams)
+	}
+	if s := FormatFilter(cond); s != `name = null and age != null` {
+		t.Fatal("unexpected format", s)
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	cases := []struct {
+		filter string
+		pos    int
+	}{
+		{`age >`, 5},
+		{`age ~ 5`, 4},
+		{`(age > 5`, 8},
+		{`age > 5 age < 3`, 8},
+		{`grade between 1`, 15},
+		{`name = "abc`, 7},
+		{`id in (1, 2`, 11},
+		{`= 5`, 0},
+		{`имя ! 5`, 4},
+		{`age > null`, 6},
+		{`id in (1, null)`, 10},
+		{`grade between null..5`, 14},
+	}
+	for _, c := range cases {
+		_, err := ParseFilter(c.filter)
+		var se *ErrSyntax
+		if !errors.As(err, &se) {
+			t.Fatal("syntax error expected for", c.filter, "got", err)
+		}
+		if se.Pos != c.pos || se.Kind() != "syntax_error" {
+			t.Fatal("wrong position for", c.filter, ":", se.Pos, se.Msg)
+		}
+	}
+}
+
+func TestFormatFilter(t *testing.T) {
+	filters := []string{
+		`age > 20 and (name starts "pet" or grade between 60..80)`,
+		`a = 1 or b = 2 and c = 3`,
+		`(a = 1 or b = 2) and c != 3`,
+		`not (a = 1 or b = 2) and not c in ("x", "y")`,
+		`a:text = 5 and b:date = "2024/1/2" and c:int = null`,
+		`grade ` + "`>= or 0`" + ` 5 and id not in (1, 2)`,
+		`(a = 1 and b = 2) and c = 3`,
+	}
+	for _, f := range filters {
+		c, err := ParseFilter(f)
+		if err != nil {
+			t.Fatal(f, err)
+		}
+		if s := FormatFilter(c); s != f {
+			t.Fatal("round trip failed:\n", f, "\n", s)
+		}
+	}
+
+	// дерево из JSON запроса с операторами фронта
+	var q Query
+	err := json.Unmarshal([]byte(`{"Search": {"Op": "or", "Query": [
+		{"Col": "age", "Type": "int", "Val": 23, "Op": "равен"},
+		{"Col": "name", "Type": "text", "Val": "iv", "Op": "начинается с"},
+		{"Col": "age", "Type": "int", "Val": [1, 5], "Op": "в списке"},
+		{"Op": "NOT", "Query": [
+			{"Col": "a", "Type": "int", "Val": 1, "Op": ">"},
+			{"Col": "b", "Type": "int", "Val": 2, "Op": "<"}
+		]}
+	]}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `age = 23 or name starts "iv" or age in (1, 5) or not (a > 1 and b < 2)`
+	if s := FormatFilter(q.Search); s != expected {
+		t.Fatal("unexpected filter:\n", s)
+	}
+}
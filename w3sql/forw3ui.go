@@ -11,6 +11,7 @@ type compilerSession struct {
 	params     map[string]any
 	fieldmap   map[string]string
 	varCounter int
+	path       string //путь к текущему условию в JSON запроса, для ошибок
 }
 
 type RawCondition interface {
@@ -52,9 +53,16 @@ type Query struct {
 	Update *struct {
 		Cols   []string
 		Values [][]any
+		Rows   []map[string]any //частичное обновление: в каждой строке только измененные поля и ID
 	}
-	Delete []any
-	Params map[string]any //дополнительные параметры запроса, вне логики SQL
+	UpdateWhere *UpdateWhere
+	Delete      []any
+	DeleteWhere *DeleteWhere
+	Params      map[string]any //дополнительные параметры запроса, вне логики SQL
+
+	// параметры от сервера для ServerColumn.Value, например пользователь из сессии;
+	// из запроса клиента не читаются и с Params не смешиваются
+	ServerParams map[string]any `json:"-"`
 }
 
 type CompiledQueryParams struct {
@@ -102,6 +110,33 @@ func (cs *compilerSession) getSearchField(fname string, ftype string) (string, b
 	return field, ok
 }
 
+func (cs *compilerSession) info(q *AtomaryCondition) ErrorInfo {
+	return ErrorInfo{
+		Field: q.Col,
+		Op:    q.Op,
+		Path:  cs.path,
+	}
+}
+
+func (cs *compilerSession) unknownField(q *AtomaryCondition) error {
+	return &ErrUnknownField{ErrorInfo: cs.info(q)}
+}
+
+func (cs *compilerSession) valueError(q *AtomaryCondition, err error) error {
+	if err == nil {
+		return nil
+	}
+	var te *ErrUnsupportedType
+	if errors.As(err, &te) {
+		te.ErrorInfo = cs.info(q)
+		return te
+	}
+	return &ErrBadValue{
+		ErrorInfo: cs.info(q),
+		Err:       err,
+	}
+}
+
 func (q *AtomaryCondition) compile(cs *compilerSession) (string, error) {
 	switch q.Op {
 	case "равен", "is", "==":
@@ -135,13 +170,35 @@ func (q *AtomaryCondition) compile(cs *compilerSession) (string, error) {
 	case "заканчивается на", "ends", "ends with":
 		return cs.compileOperatorBEGINS(q, false, true)
 	default:
-		return "", errors.New("w3sql: operator '" + q.Op + "' is not supported")
+		return "", &ErrUnsupportedOperator{ErrorInfo: cs.info(q)}
 	}
 }
 
+func (cs *compilerSession) compoundLogics(q *CompoundCondition) (logics string, not bool, err error) {
+	logics = strings.ToUpper(q.Op)
+	switch logics {
+	case "AND", "OR":
+	case "NOT":
+		logics = "AND"
+		not = true
+	default:
+		err = &ErrUnsupportedOperator{ErrorInfo: ErrorInfo{Op: q.Op, Path: cs.path}}
+	}
+	return
+}
+
 func (q *CompoundCondition) compile(cs *compilerSession) (string, error) {
+	logics, not, err := cs.compoundLogics(q)
+	if err != nil {
+		return "", err
+	}
+
+	path := cs.path
+	defer func() { cs.path = path }()
+
 	parts := make([]string, len(q.Query))
 	for i, qp := range q.Query {
+		cs.path = fmt.Sprintf("%s.Query[%d]", path, i)
 		s, err := qp.compile(cs)
 		if err != nil {
 			return "", err
@@ -149,13 +206,6 @@ func (q *CompoundCondition) compile(cs *compilerSession) (string, error) {
 		parts[i] = s
 	}
 
-	logics := strings.ToUpper(q.Op)
-	not := false
-	if q.Op == "NOT" {
-		logics = "AND"
-		not = true
-	}
-
 	result := strings.Join(parts, fmt.Sprintf(" %s ", logics))
 	if not {
 		result = "NOT (" + result + ")"
@@ -167,14 +217,17 @@ func (q *CompoundCondition) compile(cs *compilerSession) (string, error) {
 func (q *SortQuery) compile(cs *compilerSession) (string, error) {
 	q.Dir = strings.ToUpper(q.Dir)
 	if q.Dir != "ASC" && q.Dir != "DESC" {
-		return "", errors.New("w3sql: direction '" + q.Dir + "' is not supported")
+		return "", &ErrBadSortDirection{
+			ErrorInfo: ErrorInfo{Field: q.Col, Path: cs.path},
+			Dir:       q.Dir,
+		}
 	}
 	var (
 		field string
 		ok    bool
 	)
 	if field, ok = cs.fieldmap[q.Col]; !ok {
-		return "", errors.New("w3sql: no such field name " + q.Col)
+		return "", &ErrUnknownField{ErrorInfo: ErrorInfo{Field: q.Col, Path: cs.path}}
 	}
 	if field == "" {
 		field = q.Col
@@ -1,6 +1,7 @@
 package w3sql
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -17,6 +18,7 @@ type SQLQuery struct {
 	Order      string
 	Cols       string
 	Values     string
+	Returning  string
 }
 
 func removeRoundBracketsContents(s string) string {
@@ -141,7 +143,40 @@ func (cq *SelectQuery) NoConditions() *SelectQuery {
 	return &result
 }
 
-func (q *InsertQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
+// AndCondition добавляет к скомпилированным условиям еще одно через and
+func (cq *SelectQuery) AndCondition(cond string) *SelectQuery {
+	result := *cq
+	if result.Conditions == "" {
+		result.Conditions = cond
+	} else {
+		result.Conditions = "(" + result.Conditions + ") and " + cond
+	}
+	return &result
+}
+
+// CountSQL запрос числа строк результата baseSQL с условиями cq, без сортировки, limit и offset
+func (cq *SelectQuery) CountSQL(baseSQL *SQLString) ([]SQLQuery, error) {
+	inner, err := cq.NoLimitOffset().NoOrder().SQL(baseSQL)
+	if err != nil {
+		return nil, err
+	}
+	result := inner[0]
+	result.Code = "select count(*) from (\n" + result.Code + "\n) w3c"
+	return []SQLQuery{result}, nil
+}
+
+func returningSQL(sqlSyntax string, cols []string) (string, error) {
+	if len(cols) == 0 {
+		return "", nil
+	}
+	switch sqlSyntax {
+	case "postgres", "sqlite":
+		return "returning " + strings.Join(cols, ", "), nil
+	}
+	return "", errors.New("w3sql: returning is not supported for '" + sqlSyntax + "'")
+}
+
+func (q *InsertQuery) insertSQL(baseSQL ...*SQLString) SQLQuery {
 	result := SQLQuery{Params: q.SQLParams}
 	if baseSQL != nil && len(baseSQL) > 0 {
 		result.Base = baseSQL[0].String()
@@ -155,22 +190,84 @@ func (q *InsertQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
 	}
 	result.Values = "values\n" + strings.Join(vals, ",\n")
 	result.Code += "\n" + result.Values
+	return result
+}
+
+func (q *InsertQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
+	result := q.insertSQL(baseSQL...)
+	returning, err := returningSQL(q.SQLSyntax, q.Returning)
+	if err != nil {
+		return nil, err
+	}
+	if returning != "" {
+		result.Returning = returning
+		result.Code += "\n" + returning
+	}
 	return []SQLQuery{result}, nil
 }
 
+// SQL возвращает по запросу на группу строк; если обновлять нечего (все строки отброшены
+// или в них только ID), результат пустой
 func (q *UpdateQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
+	result := make([]SQLQuery, 0, len(q.Groups)+1)
+	if len(q.Values) > 0 && q.hasSetCols() {
+		r, err := q.groupSQL(baseSQL...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	for _, g := range q.Groups {
+		g.Returning = q.Returning
+		g.VersionCol = q.VersionCol
+		g.VersionTimestamp = q.VersionTimestamp
+		r, err := g.groupSQL(baseSQL...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (q *UpdateQuery) groupSQL(baseSQL ...*SQLString) (SQLQuery, error) {
 	result := SQLQuery{Params: q.SQLParams}
 	if baseSQL != nil && len(baseSQL) > 0 {
 		result.Base = baseSQL[0].String()
 		result.Code += result.Base
 	}
 
-	flds := make([]string, len(q.Cols))
+	// в sqlite у values нельзя задать имена колонок, они называются column1, column2...
+	sqlite := q.SQLSyntax == "sqlite"
+	ref := func(i int) string {
+		if sqlite {
+			return fmt.Sprintf("c.column%d", i+1)
+		}
+		return "c." + q.Cols[i]
+	}
+
+	idRef, versionRef := "", ""
+	flds := make([]string, 0, len(q.Cols))
 	for i, f := range q.Cols {
-		if f == q.IDField {
-			continue
+		switch f {
+		case q.IDField:
+			idRef = ref(i)
+		case q.VersionCol:
+			versionRef = ref(i)
+			if q.VersionTimestamp {
+				flds = append(flds, fmt.Sprintf("%s = current_timestamp", f))
+			} else {
+				flds = append(flds, fmt.Sprintf("%s = %s + 1", f, ref(i)))
+			}
+		default:
+			flds = append(flds, fmt.Sprintf("%s = %s", f, ref(i)))
 		}
-		flds[i] = fmt.Sprintf("%s = c.%s", f, f)
+	}
+	if q.VersionCol != "" && versionRef == "" {
+		return result, &ErrMissingField{ErrorInfo: ErrorInfo{
+			Field: q.VersionCol,
+			Path:  "Update.Cols",
+		}}
 	}
 	result.Cols = " set\n" + strings.Join(flds, ",\n")
 	result.Code += result.Cols
@@ -180,17 +277,39 @@ func (q *UpdateQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
 		vals[i] = "(" + strings.Join(v, ",") + ")"
 	}
 	result.Values = "from (values \n" + strings.Join(vals, ",\n") + "\n) as c"
-	result.Values += "(" + strings.Join(q.Cols, ",") + ")"
-	result.Code += result.Values
+	if !sqlite {
+		result.Values += "(" + strings.Join(q.Cols, ",") + ")"
+	}
+	result.Code += "\n" + result.Values
 
-	result.Conditions = fmt.Sprintf("where %s = c.%s", q.IDField, q.IDField)
+	result.Conditions = fmt.Sprintf("where %s = %s", q.IDField, idRef)
+	if versionRef != "" {
+		result.Conditions += fmt.Sprintf(" and %s = %s", q.VersionCol, versionRef)
+	}
 	result.Code += "\n" + result.Conditions
 
-	return []SQLQuery{result}, nil
+	returning, err := returningSQL(q.SQLSyntax, q.Returning)
+	if err != nil {
+		return result, err
+	}
+	if returning != "" {
+		result.Returning = returning
+		result.Code += "\n" + returning
+	}
+
+	return result, nil
 }
 
 type IsDelAllowedFunc = func(any) error
 
+// при мягком удалении строки не удаляются, а помечаются временем удаления
+func deleteFrom(table string, softDeleteCol string) string {
+	if softDeleteCol != "" {
+		return fmt.Sprintf("update %s set %s = current_timestamp", table, softDeleteCol)
+	}
+	return "delete from " + table
+}
+
 func (q *DeleteQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
 	result := make([]SQLQuery, len(q.Tables))
 	if baseSQL != nil {
@@ -205,19 +324,23 @@ func (q *DeleteQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
 	for i, tab := range q.Tables {
 		if len(tab.ToDelete) == 1 {
 			result[i].Code = fmt.Sprintf(
-				"delete from %s where %s = %s",
-				tab.TableName,
+				"%s where %s = %s",
+				deleteFrom(tab.TableName, tab.SoftDeleteCol),
 				tab.IDName,
 				tab.ToDelete[0],
 			)
 		} else {
 			result[i].Code = fmt.Sprintf(
-				"delete from %s where %s in (%s)",
-				tab.TableName,
+				"%s where %s in (%s)",
+				deleteFrom(tab.TableName, tab.SoftDeleteCol),
 				tab.IDName,
 				strings.Join(tab.ToDelete, ","),
 			)
 		}
+		// уже удаленные строки не помечаются повторно, как и в DeleteWhere
+		if tab.SoftDeleteCol != "" {
+			result[i].Code += fmt.Sprintf(" and %s is null", tab.SoftDeleteCol)
+		}
 		result[i].Params = tab.SQLParams
 	}
 
@@ -0,0 +1,139 @@
+package w3sql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Aggregate агрегат поля для строк-групп
+type Aggregate struct {
+	Col  string //поле фронта
+	Func string //sum, min, max, avg или count
+}
+
+var aggregateFuncs = map[string]bool{"sum": true, "min": true, "max": true, "avg": true, "count": true}
+
+var groupIdent = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_]*$`)
+
+func groupAlias(field string) string {
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+// groupCol колонка результата baseSQL для поля фронта, выражения в карте полей не группируются
+func groupCol(fieldmap map[string]string, field string, path string) (string, error) {
+	col, ok := fieldmap[field]
+	if !ok {
+		return "", &ErrUnknownField{ErrorInfo: ErrorInfo{Field: field, Path: path}}
+	}
+	if col == "" {
+		col = field
+	}
+	if i := strings.LastIndex(col, "."); i >= 0 {
+		col = col[i+1:]
+	}
+	if !groupIdent.MatchString(col) {
+		return "", fmt.Errorf("w3sql: field '%s' is not a result column and can't be grouped", field)
+	}
+	return "w3g." + col, nil
+}
+
+// GroupSQL запрос строк-групп: результат baseSQL с условиями cq группируется по полям cols,
+// по каждой группе считаются агрегаты aggs, не больше одного на поле и не по полям группировки;
+// поля должны быть колонками результата baseSQL.
+// Колонки ответа называются полями фронта, сортировка sort - только по ним, остальные поля пропускаются,
+// в конце всегда добавляются поля группировки, не упомянутые в sort, по возрастанию;
+// limit и offset берутся из cq
+func (cq *SelectQuery) GroupSQL(
+	baseSQL *SQLString,
+	fieldmap map[string]string,
+	cols []string,
+	aggs []Aggregate,
+	sort []SortQuery,
+) ([]SQLQuery, error) {
+	if len(cols) == 0 {
+		return nil, errors.New("w3sql: no group columns")
+	}
+
+	selected := make([]string, 0, len(cols)+len(aggs))
+	groups := make([]string, len(cols))
+	aliases := map[string]string{}
+	for i, field := range cols {
+		col, err := groupCol(fieldmap, field, fmt.Sprintf("Group[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		groups[i] = col
+		aliases[field] = groupAlias(field)
+		selected = append(selected, col+" as "+aliases[field])
+	}
+	for i, a := range aggs {
+		path := fmt.Sprintf("Aggregates[%d]", i)
+		f := strings.ToLower(a.Func)
+		if !aggregateFuncs[f] {
+			return nil, &ErrUnsupportedOperator{ErrorInfo: ErrorInfo{Field: a.Col, Op: a.Func, Path: path}}
+		}
+		col, err := groupCol(fieldmap, a.Col, path)
+		if err != nil {
+			return nil, err
+		}
+		// колонка ответа называется полем фронта, второй агрегат того же поля ее бы перезаписал
+		if _, ok := aliases[a.Col]; ok {
+			return nil, fmt.Errorf("w3sql: field '%s' is already in the group result, only one aggregate per field", a.Col)
+		}
+		aliases[a.Col] = groupAlias(a.Col)
+		selected = append(selected, f+"("+col+") as "+aliases[a.Col])
+	}
+
+	inner, err := cq.NoLimitOffset().NoOrder().SQL(baseSQL)
+	if err != nil {
+		return nil, err
+	}
+	result := SQLQuery{
+		Params:     cq.SQLParams,
+		Base:       inner[0].Base,
+		Conditions: inner[0].Conditions,
+	}
+	result.Code = "select " + strings.Join(selected, ", ") +
+		"\nfrom (\n" + inner[0].Code + "\n) w3g" +
+		"\ngroup by " + strings.Join(groups, ", ")
+
+	var order []string
+	sorted := map[string]bool{}
+	for i, s := range sort {
+		alias, ok := aliases[s.Col]
+		if !ok || sorted[alias] {
+			continue
+		}
+		dir := strings.ToUpper(s.Dir)
+		if dir != "ASC" && dir != "DESC" {
+			return nil, &ErrBadSortDirection{
+				ErrorInfo: ErrorInfo{Field: s.Col, Path: fmt.Sprintf("Sort[%d]", i)},
+				Dir:       s.Dir,
+			}
+		}
+		order = append(order, alias+" "+dir)
+		sorted[alias] = true
+	}
+	// группы уникальны по полям группировки, досортировка по ним дает стабильные страницы
+	for _, field := range cols {
+		if alias := aliases[field]; !sorted[alias] {
+			order = append(order, alias+" ASC")
+			sorted[alias] = true
+		}
+	}
+	result.Order = "order by " + strings.Join(order, ", ")
+	result.Code += "\n" + result.Order
+	if cq.Limit != nil {
+		result.Limit = fmt.Sprintf("limit %d ", *cq.Limit)
+		result.Code += "\n" + result.Limit
+	}
+	if cq.Offset != nil {
+		result.Offset = fmt.Sprintf("offset %d ", *cq.Offset)
+		result.Code += "\n" + result.Offset
+	}
+	result.Code = strings.TrimSpace(result.Code)
+
+	return []SQLQuery{result}, nil
+}
@@ -0,0 +1,74 @@
+package w3sql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGroupSQL(t *testing.T) {
+	fieldmap := map[string]string{"age": "", "grade": "s.score", "name": "upper(s.name)"}
+	limit := 2
+	q := Query{
+		Search: &AtomaryCondition{Col: "age", Op: ">", Type: "int", Val: 20},
+		Limit:  &limit,
+	}
+	cq, err := q.CompileSelect("sqlite", fieldmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := NewSQLString("select * from students s")
+	sort := []SortQuery{{Col: "grade", Dir: "desc"}, {Col: "name", Dir: "asc"}}
+	sq, err := cq.GroupSQL(base, fieldmap, []string{"age"}, []Aggregate{{Col: "grade", Func: "SUM"}}, sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `select w3g.age as "age", sum(w3g.score) as "grade"
+		from ( select * from students s where (age>:sqv0) ) w3g
+		group by w3g.age
+		order by "grade" DESC, "age" ASC
+		limit 2`
+	if !EqualSQLStrings(sq[0].Code, expected) {
+		t.Fatal("unexpected SQL:\n", sq[0].Code)
+	}
+	if sq[0].Params["sqv0"] != int64(20) {
+		t.Fatal("unexpected params:", sq[0].Params)
+	}
+
+	// без сортировки страницы упорядочены по полям группировки
+	sq, err = cq.GroupSQL(base, fieldmap, []string{"age"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sq[0].Order != `order by "age" ASC` {
+		t.Fatal("default group order expected:", sq[0].Order)
+	}
+
+	cases := []struct {
+		cols []string
+		aggs []Aggregate
+		kind string
+	}{
+		{[]string{"nick"}, nil, "unknown_field"},
+		{[]string{"age"}, []Aggregate{{Col: "grade", Func: "median"}}, "unsupported_operator"},
+	}
+	for _, c := range cases {
+		_, err := cq.GroupSQL(base, fieldmap, c.cols, c.aggs, nil)
+		var qe QueryError
+		if !errors.As(err, &qe) || qe.Kind() != c.kind {
+			t.Fatal(c.kind, "expected, got", err)
+		}
+	}
+	if _, err := cq.GroupSQL(base, fieldmap, []string{"name"}, nil, nil); err == nil {
+		t.Fatal("expression fields should not be grouped")
+	}
+
+	// второй агрегат поля не пропускается молча
+	twoAggs := []Aggregate{{Col: "grade", Func: "sum"}, {Col: "grade", Func: "avg"}}
+	if _, err := cq.GroupSQL(base, fieldmap, []string{"age"}, twoAggs, nil); err == nil {
+		t.Fatal("second aggregate of the field should fail")
+	}
+	if _, err := cq.GroupSQL(base, fieldmap, []string{"age"}, []Aggregate{{Col: "age", Func: "count"}}, nil); err == nil {
+		t.Fatal("aggregate of the group field should fail")
+	}
+}
@@ -1,6 +1,7 @@
 package w3sql
 
 import (
+	"bytes"
 	"encoding/json"
 )
 
@@ -24,9 +25,12 @@ type jsonQuery struct {
 	Update *struct {
 		Cols   []string
 		Values [][]any
+		Rows   []map[string]any //частичное обновление: в каждой строке только измененные поля и ID
 	}
-	Delete []any
-	Params map[string]any //дополнительные параметры запроса, вне логики SQL
+	UpdateWhere *UpdateWhere
+	Delete      []any
+	DeleteWhere *DeleteWhere
+	Params      map[string]any //дополнительные параметры запроса, вне логики SQL
 }
 
 func (c *jsonCondition) read() RawCondition {
@@ -50,7 +54,10 @@ func (c *jsonCondition) read() RawCondition {
 
 func (q *Query) UnmarshalJSON(data []byte) error {
 	var raw jsonQuery
-	err := json.Unmarshal(data, &raw)
+	// числа читаются как json.Number, чтобы не терять точность на float64
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&raw)
 	if err != nil {
 		return err
 	}
@@ -62,7 +69,9 @@ func (q *Query) UnmarshalJSON(data []byte) error {
 	q.Sort = raw.Sort
 	q.Insert = raw.Insert
 	q.Update = raw.Update
+	q.UpdateWhere = raw.UpdateWhere
 	q.Delete = raw.Delete
+	q.DeleteWhere = raw.DeleteWhere
 	q.Params = raw.Params
 	return nil
 }
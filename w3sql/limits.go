@@ -0,0 +1,95 @@
+package w3sql
+
+import (
+	"fmt"
+)
+
+const (
+	LimitMaxDepth      = "max_depth"
+	LimitMaxConditions = "max_conditions"
+	LimitMaxListLength = "max_list_length"
+	LimitMaxSort       = "max_sort"
+	LimitMaxLimit      = "max_limit"
+	LimitMaxRows       = "max_rows" //число строк, затрагиваемых UpdateWhere
+)
+
+// Limits ограничивает сложность запроса, нулевое значение означает "без ограничения"
+type Limits struct {
+	MaxDepth      int // глубина вложенности условий
+	MaxConditions int // количество атомарных условий
+	MaxListLength int // длина списка значений для in, or и т.п.
+	MaxSort       int // количество колонок сортировки
+	MaxLimit      int // максимальное значение Limit
+}
+
+type ErrLimitExceeded struct {
+	ErrorInfo
+	Limit string
+	Max   int
+}
+
+func (e *ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("w3sql: query exceeds %s = %d", e.Limit, e.Max)
+}
+
+func (e *ErrLimitExceeded) Kind() string {
+	return e.Limit
+}
+
+type limitsCounter struct {
+	limits     *Limits
+	conditions int
+}
+
+func exceeded(limit string, max int, info ErrorInfo) error {
+	return &ErrLimitExceeded{
+		ErrorInfo: info,
+		Limit:     limit,
+		Max:       max,
+	}
+}
+
+func (lc *limitsCounter) check(c RawCondition, depth int, path string) error {
+	l := lc.limits
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return exceeded(LimitMaxDepth, l.MaxDepth, ErrorInfo{Path: path})
+	}
+
+	switch x := c.(type) {
+	case *AtomaryCondition:
+		info := ErrorInfo{Field: x.Col, Op: x.Op, Path: path}
+		lc.conditions++
+		if l.MaxConditions > 0 && lc.conditions > l.MaxConditions {
+			return exceeded(LimitMaxConditions, l.MaxConditions, info)
+		}
+		if list, ok := x.Val.([]any); ok && l.MaxListLength > 0 && len(list) > l.MaxListLength {
+			return exceeded(LimitMaxListLength, l.MaxListLength, info)
+		}
+	case *CompoundCondition:
+		for i, sub := range x.Query {
+			err := lc.check(sub, depth+1, fmt.Sprintf("%s.Query[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckLimits проверяет запрос до компиляции, чтобы не строить SQL для заведомо тяжелых запросов
+func (q *Query) CheckLimits(l *Limits) error {
+	if l == nil {
+		return nil
+	}
+	if l.MaxSort > 0 && len(q.Sort) > l.MaxSort {
+		return exceeded(LimitMaxSort, l.MaxSort, ErrorInfo{Path: "Sort"})
+	}
+	if l.MaxLimit > 0 && q.Limit != nil && *q.Limit > l.MaxLimit {
+		return exceeded(LimitMaxLimit, l.MaxLimit, ErrorInfo{Path: "Limit"})
+	}
+	if q.Search == nil {
+		return nil
+	}
+	lc := &limitsCounter{limits: l}
+	return lc.check(q.Search, 1, "Search")
+}
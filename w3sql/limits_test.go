@@ -0,0 +1,66 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+	s := `{
+		"Limit": 50,
+		"Sort": [{"Col": "name", "Dir": "desc"}, {"Col": "age", "Dir": "asc"}],
+		"Search": {
+			"Op": "and",
+			"Query": [
+				{"Col": "age", "Type": "int", "Val": [1, 2, 3, 4], "Op": "in"},
+				{
+					"Op": "or",
+					"Query": [
+						{"Col": "name", "Type": "string", "Val": "Bob", "Op": "contains"},
+						{"Col": "name", "Type": "string", "Val": "Alice", "Op": "starts with"}
+					]
+				}
+			]
+		}
+	}`
+	var q Query
+	err := json.Unmarshal([]byte(s), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = q.CheckLimits(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.CheckLimits(&Limits{
+		MaxDepth:      3,
+		MaxConditions: 3,
+		MaxListLength: 4,
+		MaxSort:       2,
+		MaxLimit:      50,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		limits Limits
+		limit  string
+		path   string
+	}{
+		{Limits{MaxDepth: 2}, LimitMaxDepth, "Search.Query[1].Query[0]"},
+		{Limits{MaxConditions: 2}, LimitMaxConditions, "Search.Query[1].Query[1]"},
+		{Limits{MaxListLength: 3}, LimitMaxListLength, "Search.Query[0]"},
+		{Limits{MaxSort: 1}, LimitMaxSort, "Sort"},
+		{Limits{MaxLimit: 10}, LimitMaxLimit, "Limit"},
+	} {
+		err = q.CheckLimits(&c.limits)
+		var le *ErrLimitExceeded
+		if !errors.As(err, &le) {
+			t.Fatalf("ErrLimitExceeded expected for %s, got %v", c.limit, err)
+		}
+		if le.Kind() != c.limit || le.Path != c.path {
+			t.Fatalf("unexpected error %q, kind %s, path %s", le.Error(), le.Kind(), le.Path)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package w3sql
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Параметры запроса OData: $filter, $orderby, $top, $skip и $count, например
+//
+//	$filter=Age gt 20 and (startswith(Name,'pe') or Grade in (60, 70))&$orderby=Age desc,Name&$top=50
+//
+// в $filter операторы eq ne gt ge lt le in, функции startswith, contains и endswith,
+// логика not, and, or и скобки; тип условия выводится из литерала, как в ParseFilter:
+// 'строка' - text, целое - int, дробное - number, 2024-01-31 - date,
+// 2024-01-31T10:00:00Z - datetime (приводится к UTC), true и false - bool.
+// Name eq null и Name ne null - проверки is null и is not null, с другими операторами,
+// в in и в функциях null не допускается.
+
+var odataOps = map[string]string{
+	"eq": "==",
+	"ne": "!=",
+	"gt": ">",
+	"ge": ">=",
+	"lt": "<",
+	"le": "<=",
+	"in": "in",
+}
+
+var odataFuncs = map[string]string{
+	"startswith": "begins",
+	"contains":   "contains",
+	"endswith":   "ends",
+}
+
+var odataIdent = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_]*$`)
+
+func isODataLiteral(r rune) bool {
+	return unicode.IsDigit(r) || unicode.IsLetter(r) || strings.ContainsRune(".:+-", r)
+}
+
+// odataLiteral число или дата; суффиксы типов чисел OData (L, M, D, F) отбрасываются
+func odataLiteral(text string, pos int) (filterToken, error) {
+	switch {
+	case filterDate.MatchString(text) || filterDateTime.MatchString(text):
+		return filterToken{kind: ftDate, text: text, pos: pos}, nil
+	case strings.Contains(text, "T"):
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return filterToken{}, &ErrSyntax{Pos: pos, Msg: "bad date '" + text + "'"}
+		}
+		return filterToken{kind: ftDate, text: t.UTC().Format("2006-01-02T15:04:05"), pos: pos}, nil
+	}
+	number := strings.TrimRight(text, "LlMmDdFf")
+	if len(text)-len(number) > 1 {
+		number = text
+	}
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return filterToken{}, &ErrSyntax{Pos: pos, Msg: "bad number '" + text + "'"}
+	}
+	return filterToken{kind: ftNumber, text: number, pos: pos}, nil
+}
+
+func odataTokens(s string) ([]filterToken, error) {
+	rs := []rune(s)
+	var result []filterToken
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		start := i
+		tok := func(kind filterTokenKind, text string) {
+			result = append(result, filterToken{kind: kind, text: text, pos: start})
+		}
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tok(ftLParen, "(")
+			i++
+		case r == ')':
+			tok(ftRParen, ")")
+			i++
+		case r == ',':
+			tok(ftComma, ",")
+			i++
+		case r == '\'':
+			// кавычка внутри строки удваивается
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(rs) {
+					return nil, &ErrSyntax{Pos: start, Msg: "unterminated string"}
+				}
+				if rs[i] == '\'' {
+					if i+1 < len(rs) && rs[i+1] == '\'' {
+						b.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(rs[i])
+				i++
+			}
+			tok(ftString, b.String())
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			i++
+			for i < len(rs) && isODataLiteral(rs[i]) {
+				i++
+			}
+			t, err := odataLiteral(string(rs[start:i]), start)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, t)
+		case isFilterIdent(r, true):
+			for i < len(rs) && isFilterIdent(rs[i], false) {
+				i++
+			}
+			tok(ftIdent, string(rs[start:i]))
+		default:
+			return nil, &ErrSyntax{Pos: start, Msg: fmt.Sprintf("unexpected character '%c'", r)}
+		}
+	}
+	result = append(result, filterToken{kind: ftEOF, pos: len(rs)})
+	return result, nil
+}
+
+// parseODataCondition условие Col op value или функция startswith(Col,value) [eq true|false]
+func (p *filterParser) parseODataCondition() (RawCondition, error) {
+	t := p.next()
+	if t.kind != ftIdent {
+		return nil, p.errorf(t, "expected property name")
+	}
+
+	if f, ok := odataFuncs[strings.ToLower(t.text)]; ok && p.peek().kind == ftLParen {
+		p.next()
+		col := p.next()
+		if col.kind != ftIdent {
+			return nil, p.errorf(col, "expected property name")
+		}
+		if c := p.next(); c.kind != ftComma {
+			return nil, p.errorf(c, "expected ','")
+		}
+		vtok := p.peek()
+		v, tp, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, &ErrSyntax{Pos: vtok.pos, Msg: "null is not allowed in function"}
+		}
+		if r := p.next(); r.kind != ftRParen {
+			return nil, p.errorf(r, "expected ')'")
+		}
+
+		var c RawCondition = &AtomaryCondition{Col: col.text, Op: f, Type: tp, Val: v}
+		if p.keyword("eq") || p.keyword("ne") {
+			eq := strings.EqualFold(p.next().text, "eq")
+			b, _, err := p.parseValue()
+			value, ok := b.(bool)
+			if err != nil || !ok {
+				return nil, p.errorf(p.tokens[p.i-1], "expected true or false")
+			}
+			if eq != value {
+				c = Not(c)
+			}
+		}
+		return c, nil
+	}
+
+	op := p.next()
+	if op.kind != ftIdent {
+		return nil, p.errorf(op, "expected operator")
+	}
+	c := &AtomaryCondition{Col: t.text, Op: odataOps[strings.ToLower(op.text)]}
+	if c.Op == "" {
+		return nil, &ErrSyntax{Pos: op.pos, Msg: "unknown operator '" + op.text + "'"}
+	}
+
+	var err error
+	vtok := p.peek()
+	if c.Op == "in" {
+		c.Val, c.Type, err = p.parseList()
+	} else {
+		c.Val, c.Type, err = p.parseValue()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Val == nil && c.Op != "==" && c.Op != "!=" {
+		return nil, &ErrSyntax{Pos: vtok.pos, Msg: "null is allowed only with eq and ne"}
+	}
+	return c, nil
+}
+
+// ParseODataFilter разбирает выражение $filter в дерево условий, пустое выражение - nil
+func ParseODataFilter(s string) (RawCondition, error) {
+	tokens, err := odataTokens(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	p.condition = p.parseODataCondition
+	if p.peek().kind == ftEOF {
+		return nil, nil
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != ftEOF {
+		return nil, p.errorf(t, "expected 'and', 'or' or end of filter")
+	}
+	return c, nil
+}
+
+// parseODataOrderBy разбирает $orderby: поля через запятую, за каждым может идти asc или desc
+func parseODataOrderBy(s string) ([]SortQuery, error) {
+	var result []SortQuery
+	pos := 0
+	for _, part := range strings.Split(s, ",") {
+		start := pos + len([]rune(part)) - len([]rune(strings.TrimLeft(part, " ")))
+		pos += len([]rune(part)) + 1
+
+		fields := strings.Fields(part)
+		if len(fields) == 0 || len(fields) > 2 || !odataIdent.MatchString(fields[0]) {
+			return nil, &ErrSyntax{ErrorInfo: ErrorInfo{Path: "$orderby"}, Pos: start, Msg: "expected property name"}
+		}
+		sq := SortQuery{Col: fields[0], Dir: "asc"}
+		if len(fields) == 2 {
+			sq.Dir = strings.ToLower(fields[1])
+			if sq.Dir != "asc" && sq.Dir != "desc" {
+				return nil, &ErrSyntax{ErrorInfo: ErrorInfo{Path: "$orderby"}, Pos: start, Msg: "expected asc or desc"}
+			}
+		}
+		result = append(result, sq)
+	}
+	return result, nil
+}
+
+// ParseOData читает параметры $filter, $orderby, $top, $skip и $count из строки URL (без '?'),
+// остальные параметры пропускаются; count - запрошено ли число записей ($count=true)
+func ParseOData(raw string) (q *Query, count bool, err error) {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, false, urlBadValue("", err)
+	}
+
+	q = &Query{}
+	if f := values.Get("$filter"); f != "" {
+		if q.Search, err = ParseODataFilter(f); err != nil {
+			var se *ErrSyntax
+			if errors.As(err, &se) {
+				se.Path = "$filter"
+			}
+			return nil, false, err
+		}
+	}
+	if s := values.Get("$orderby"); s != "" {
+		if q.Sort, err = parseODataOrderBy(s); err != nil {
+			return nil, false, err
+		}
+	}
+	for _, key := range []string{"$top", "$skip"} {
+		s := values.Get(key)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, false, urlBadValue(key, errors.New("w3sql: non-negative integer expected"))
+		}
+		if key == "$top" {
+			q.Limit = &n
+		} else {
+			q.Offset = &n
+		}
+	}
+	switch values.Get("$count") {
+	case "", "false":
+	case "true":
+		count = true
+	default:
+		return nil, false, urlBadValue("$count", errors.New("w3sql: true or false expected"))
+	}
+	return q, count, nil
+}
@@ -0,0 +1,87 @@
+package w3sql
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestParseOData(t *testing.T) {
+	filter := "Age gt 20 and (startswith(Name,'pe') or Grade in (60, 70.5)) and not contains(Name,'O''Brien') " +
+		"and endswith(Name,'a') eq false and Born ge 2001-02-03 and Seen lt 2024-01-31T13:00:00+03:00 and Id ne 5L"
+	q, count, err := ParseOData("$filter=" + url.QueryEscape(filter) + "&$orderby=Age%20desc,Name&$top=50&$skip=10&$count=true&$select=Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !count || *q.Limit != 50 || *q.Offset != 10 {
+		t.Fatal("unexpected count, limit and offset:", count, q.Limit, q.Offset)
+	}
+	if len(q.Sort) != 2 || q.Sort[0] != (SortQuery{Col: "Age", Dir: "desc"}) || q.Sort[1] != (SortQuery{Col: "Name", Dir: "asc"}) {
+		t.Fatal("unexpected sort:", q.Sort)
+	}
+	expected := `{"Op":"AND","Query":[` +
+		`{"Col":"Age","Type":"int","Val":20,"Op":">"},` +
+		`{"Op":"OR","Query":[{"Col":"Name","Type":"text","Val":"pe","Op":"begins"},{"Col":"Grade","Type":"int","Val":[60,70.5],"Op":"in"}]},` +
+		`{"Op":"NOT","Query":[{"Col":"Name","Type":"text","Val":"O'Brien","Op":"contains"}]},` +
+		`{"Op":"NOT","Query":[{"Col":"Name","Type":"text","Val":"a","Op":"ends"}]},` +
+		`{"Col":"Born","Type":"date","Val":"2001-02-03","Op":">="},` +
+		`{"Col":"Seen","Type":"datetime","Val":"2024-01-31T10:00:00","Op":"<"},` +
+		`{"Col":"Id","Type":"int","Val":5,"Op":"!="}]}`
+	if s := filterJSON(q.Search); s != expected {
+		t.Fatal("unexpected tree:\n", s)
+	}
+
+	// eq null и ne null компилируются в is null и is not null
+	q, _, err = ParseOData("$filter=" + url.QueryEscape("Name eq null and Age ne null"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cq, err := q.CompileSelect("postgres", map[string]string{"Name": "", "Age": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualSQLStrings("((Name is null) AND (Age is not null))", cq.Conditions) || len(cq.SQLParams) != 0 {
+		t.Fatal("unexpected null checks:", cq.Conditions, cq.SQLParams)
+	}
+	for _, c := range []struct {
+		filter string
+		pos    int
+	}{
+		{"Age gt null", 7},
+		{"Age in (1, null)", 11},
+		{"startswith(Name,null)", 16},
+	} {
+		_, err := ParseODataFilter(c.filter)
+		var se *ErrSyntax
+		if !errors.As(err, &se) || se.Pos != c.pos {
+			t.Fatal("syntax error at", c.pos, "expected for", c.filter, "got", err)
+		}
+	}
+
+	q, count, err = ParseOData("")
+	if err != nil || count || q.Search != nil || q.Limit != nil {
+		t.Fatal("empty query expected, got", q, count, err)
+	}
+
+	for _, c := range []struct {
+		raw  string
+		kind string
+		path string
+	}{
+		{"$top=-1", "bad_value", "$top"},
+		{"$skip=x", "bad_value", "$skip"},
+		{"$count=yes", "bad_value", "$count"},
+		{"$filter=Age%20like%201", "syntax_error", "$filter"},
+		{"$filter=Age%20gt", "syntax_error", "$filter"},
+		{"$filter=Name%20eq%20'x", "syntax_error", "$filter"},
+		{"$filter=startswith(Name,'x')%20eq%201", "syntax_error", "$filter"},
+		{"$orderby=Age%20up", "syntax_error", "$orderby"},
+		{"$orderby=Age,,Name", "syntax_error", "$orderby"},
+	} {
+		_, _, err := ParseOData(c.raw)
+		var qe QueryError
+		if !errors.As(err, &qe) || qe.Kind() != c.kind || qe.Info().Path != c.path {
+			t.Fatal("error", c.kind, "at", c.path, "expected for", c.raw, "got", err)
+		}
+	}
+}
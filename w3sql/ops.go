@@ -9,39 +9,45 @@ import (
 func (cs *compilerSession) compileOperatorIS(q *AtomaryCondition, not bool) (string, error) {
 	vn := "sqv" + fmt.Sprint(cs.varCounter)
 	cs.varCounter++
-	result := ""
-	var err error
-	if field, ok := cs.getSearchField(q.Col, q.Type); ok {
-		op := "="
+	field, ok := cs.getSearchField(q.Col, q.Type)
+	if !ok {
+		return "", cs.unknownField(q)
+	}
+	// сравнение с null в SQL всегда ложно, поэтому is null
+	if q.Val == nil {
 		if not {
-			op = "<>"
+			return fmt.Sprintf("(%v is not null)", field), nil
 		}
-		result = fmt.Sprintf("(%v%s:%v)", field, op, vn)
-	} else {
-		return "", errors.New("w3sql: no such field name " + q.Col)
+		return fmt.Sprintf("(%v is null)", field), nil
 	}
-	cs.params[vn], err = convValue(q.Val, q.Type)
-	return result, err
+	v, err := convValue(q.Val, q.Type)
+	if err != nil {
+		return "", cs.valueError(q, err)
+	}
+	op := "="
+	if not {
+		op = "<>"
+	}
+	return fmt.Sprintf("(%v%s:%v)", field, op, cs.param(vn, v)), nil
 }
 
 func (cs *compilerSession) compileOperatorOR(q *AtomaryCondition) (string, error) {
-	var err error
 	sq, ok := q.Val.([]any)
 	if !ok {
-		return "", errors.New("w3sql: unexpected value of parameter" + q.Col)
+		return "", cs.valueError(q, errors.New("list of values expected"))
+	}
+	field, ok := cs.getSearchField(q.Col, q.Type)
+	if !ok {
+		return "", cs.unknownField(q)
 	}
 	parts := make([]string, len(sq))
 	for j, val := range sq {
-		vn := "sqv" + fmt.Sprintf("%d_a%d", cs.varCounter, j)
-		if field, ok := cs.getSearchField(q.Col, q.Type); ok {
-			parts[j] = fmt.Sprintf("(%v=:%v)", field, vn)
-		} else {
-			return "", errors.New("w3sql: no such field name " + q.Col)
-		}
-		cs.params[vn], err = convValueElem(val, q.Type)
+		v, err := convValueElem(val, q.Type)
 		if err != nil {
-			return "", err
+			return "", cs.valueError(q, err)
 		}
+		vn := "sqv" + fmt.Sprintf("%d_a%d", cs.varCounter, j)
+		parts[j] = fmt.Sprintf("(%v=:%v)", field, cs.param(vn, v))
 	}
 	cs.varCounter++
 	if len(parts) < 1 {
@@ -53,85 +59,81 @@ func (cs *compilerSession) compileOperatorOR(q *AtomaryCondition) (string, error
 func (cs *compilerSession) compileOperatorLESS(q *AtomaryCondition, less, orEqual, orZero bool) (string, error) {
 	vn := "sqv" + fmt.Sprint(cs.varCounter)
 	cs.varCounter++
-	result := ""
-	var err error
-	if field, ok := cs.getSearchField(q.Col, q.Type); ok {
-		op := ">"
-		if less {
-			op = "<"
-		}
-		if orEqual {
-			op += "="
-		}
-		result = fmt.Sprintf("%v%s:%v", field, op, vn)
-		if orZero {
-			result += fmt.Sprintf("  or %v=0", field)
-		}
-		result = "(" + result + ")"
-	} else {
-		return "", errors.New("w3sql: no such field name " + q.Col)
+	field, ok := cs.getSearchField(q.Col, q.Type)
+	if !ok {
+		return "", cs.unknownField(q)
+	}
+	v, err := convValue(q.Val, q.Type)
+	if err != nil {
+		return "", cs.valueError(q, err)
+	}
+	op := ">"
+	if less {
+		op = "<"
 	}
-	cs.params[vn], err = convValue(q.Val, q.Type)
-	return result, err
+	if orEqual {
+		op += "="
+	}
+	result := fmt.Sprintf("%v%s:%v", field, op, cs.param(vn, v))
+	if orZero {
+		result += fmt.Sprintf("  or %v=0", field)
+	}
+	return "(" + result + ")", nil
 }
 
 func (cs *compilerSession) compileOperatorBETWEEN(q *AtomaryCondition) (string, error) {
 	rng, ok := q.Val.([]any)
 	if !ok {
-		return "", errors.New("w3sql: wrong value for field " + q.Col)
+		return "", cs.valueError(q, errors.New("range of values expected"))
 	}
 	v, err := convRange(rng, q.Type)
 	if err != nil {
-		return "", err
+		return "", cs.valueError(q, err)
 	}
 
 	vn1 := "sqv" + fmt.Sprint(cs.varCounter) + "_1"
 	vn2 := "sqv" + fmt.Sprint(cs.varCounter) + "_2"
 	cs.varCounter++
 
-	result := ""
-	if field, ok := cs.getSearchField(q.Col, q.Type); ok {
-		result = fmt.Sprintf("(%v>=:%v AND %v<=:%v)", field, vn1, field, vn2)
-	} else {
-		return "", errors.New("w3sql: no such field name " + q.Col)
-	}
-	cs.params[vn1] = v.from
-	cs.params[vn2] = v.to
-
-	return result, nil
+	field, ok := cs.getSearchField(q.Col, q.Type)
+	if !ok {
+		return "", cs.unknownField(q)
+	}
+	vn1 = cs.param(vn1, v.from)
+	vn2 = cs.param(vn2, v.to)
+	return fmt.Sprintf("(%v>=:%v AND %v<=:%v)", field, vn1, field, vn2), nil
 }
 
 func (cs *compilerSession) compileOperatorReverseIN(q *AtomaryCondition) (string, error) {
-	var (
-		err   error
-		field string
-		ok    bool
-	)
-	if field, ok = cs.getSearchField(q.Col, q.Type); !ok {
-		return "", errors.New("w3sql: no such field name " + q.Col)
+	field, ok := cs.getSearchField(q.Col, q.Type)
+	if !ok {
+		return "", cs.unknownField(q)
 	}
 	vn := fmt.Sprintf("sqv%d_1", cs.varCounter)
 	cs.varCounter++
-	cs.params[vn], err = convValueElem(q.Val, q.Type)
+	v, err := convValueElem(q.Val, q.Type)
 	if err != nil {
-		return "", err
+		return "", cs.valueError(q, err)
 	}
-	return fmt.Sprintf(":%v in (%v)", vn, field), nil
+	return fmt.Sprintf(":%v in (%v)", cs.param(vn, v), field), nil
 }
 
 func (cs *compilerSession) compileOperatorIN(q *AtomaryCondition, not bool) (string, error) {
-	var (
-		err   error
-		field string
-		ok    bool
-		rng   []any
-	)
-	if field, ok = cs.getSearchField(q.Col, q.Type); !ok {
-		return "", errors.New("w3sql: no such field name " + q.Col)
+	field, ok := cs.getSearchField(q.Col, q.Type)
+	if !ok {
+		return "", cs.unknownField(q)
 	}
 
-	if rng, ok = q.Val.([]any); !ok {
-		return "", errors.New("w3sql: wrong field value " + q.Col)
+	rng, ok := q.Val.([]any)
+	if !ok {
+		return "", cs.valueError(q, errors.New("list of values expected"))
+	}
+	// in () - синтаксическая ошибка, пустой список не совпадает ни с чем
+	if len(rng) == 0 {
+		if not {
+			return "(1=1)", nil
+		}
+		return "(1=0)", nil
 	}
 	searchStr := "("
 	if not {
@@ -139,12 +141,12 @@ func (cs *compilerSession) compileOperatorIN(q *AtomaryCondition, not bool) (str
 	}
 	searchStr += fmt.Sprintf("%v in (", field)
 	for j, cv := range rng {
-		vn := fmt.Sprintf("sqv%d_%d", cs.varCounter, j)
-		cs.params[vn], err = convValueElem(cv, q.Type)
+		v, err := convValueElem(cv, q.Type)
 		if err != nil {
-			return "", err
+			return "", cs.valueError(q, err)
 		}
-		searchStr += fmt.Sprintf(":%v,", vn)
+		vn := fmt.Sprintf("sqv%d_%d", cs.varCounter, j)
+		searchStr += fmt.Sprintf(":%v,", cs.param(vn, v))
 	}
 	cs.varCounter++
 	searchStr = strings.TrimRight(searchStr, ",") + "))"
@@ -154,23 +156,19 @@ func (cs *compilerSession) compileOperatorIN(q *AtomaryCondition, not bool) (str
 func (cs *compilerSession) compileOperatorBEGINS(q *AtomaryCondition, contains, ends bool) (string, error) {
 	vn := "sqv" + fmt.Sprint(cs.varCounter)
 	cs.varCounter++
-	result := ""
-	var err error
-	if field, ok := cs.getSearchField(q.Col, q.Type); ok {
-		op := "(%v LIKE :%v || '%%')"
-		if contains {
-			op = "(%v LIKE '%%' || :%v || '%%')"
-		} else if ends {
-			op = "(%v LIKE '%%' || :%v)"
-		}
-		result = fmt.Sprintf(op, field, vn)
-	} else {
-		return "", errors.New("w3sql: no such field name " + q.Col)
+	field, ok := cs.getSearchField(q.Col, q.Type)
+	if !ok {
+		return "", cs.unknownField(q)
 	}
-
-	cs.params[vn], err = convValue(q.Val, q.Type)
+	v, err := convValue(q.Val, q.Type)
 	if err != nil {
-		return "", err
+		return "", cs.valueError(q, err)
+	}
+	op := "(%v LIKE :%v || '%%')"
+	if contains {
+		op = "(%v LIKE '%%' || :%v || '%%')"
+	} else if ends {
+		op = "(%v LIKE '%%' || :%v)"
 	}
-	return result, nil
+	return fmt.Sprintf(op, field, cs.param(vn, v)), nil
 }
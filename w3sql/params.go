@@ -0,0 +1,44 @@
+package w3sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// имена параметров содержат только [A-Za-z0-9_], иначе драйверы не распознают placeholder
+func sanitizeParamName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "p"
+	}
+	return b.String()
+}
+
+// param регистрирует значение параметра и возвращает его имя. Имя очищается от недопустимых
+// символов и не пересекается с уже выданными; оно зависит только от позиции условия, но не от значения,
+// поэтому SQL одинаковых по форме запросов совпадает побайтно.
+//
+// Одинаковые значения одной колонки намеренно не объединяются в один параметр: общий параметр
+// меняет текст SQL в зависимости от значений (in (20, 21, 20) и in (1, 2, 3) дали бы разный SQL),
+// и подготовленный запрос из кеша нельзя было бы переиспользовать. Общее имя получают только
+// значения, которые сервер задает одним именем, например колонки ServerColumn во всех строках вставки.
+func (cs *compilerSession) param(name string, v any) string {
+	name = sanitizeParamName(name)
+	unique := name
+	for i := 1; ; i++ {
+		if _, ok := cs.params[unique]; !ok {
+			break
+		}
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	cs.params[unique] = v
+	return unique
+}
@@ -0,0 +1,99 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestParamNames(t *testing.T) {
+	s := `{
+		"Insert": {
+			"Cols": ["first-name", "a1", "a"],
+			"Values": [
+				["Vanya", 1, 2],
+				["Vanya", 3, 4],
+				["Masha", 5, 6],
+				["Petya", 7, 8],
+				["Lena", 9, 10],
+				["Olya", 11, 12]
+			]
+		}
+	}`
+	var q Query
+	err := json.Unmarshal([]byte(s), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iq, err := q.CompileInsert("postgres", map[string]string{"first-name": "first_name", "a1": "", "a": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// повтор "Vanya" получает свой параметр: имена не зависят от значений
+	if len(iq.SQLParams) != 18 {
+		t.Fatal("18 parameters expected, got", len(iq.SQLParams), iq.SQLParams)
+	}
+
+	seen := map[string]bool{}
+	for _, row := range iq.Values {
+		for _, p := range row {
+			for _, r := range p[1:] {
+				if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+					t.Fatal("unexpected character in parameter name", p)
+				}
+			}
+			if seen[p] {
+				t.Fatal("parameter name is not unique", p)
+			}
+			seen[p] = true
+		}
+	}
+	fmt.Println("VALUES:", iq.Values)
+}
+
+func TestParamPositions(t *testing.T) {
+	compile := func(s string) *SelectQuery {
+		var q Query
+		if err := json.Unmarshal([]byte(s), &q); err != nil {
+			t.Fatal(err)
+		}
+		cq, err := q.CompileSelect("sqlite", map[string]string{"age": "", "score": ""})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cq
+	}
+	query := func(in string, from, to int) string {
+		return fmt.Sprintf(`{"Search": {"Op": "or", "Query": [
+			{"Col": "age", "Type": "int", "Val": [%s], "Op": "in"},
+			{"Col": "age", "Type": "int", "Val": 20, "Op": ">"},
+			{"Col": "score", "Type": "int", "Val": [%d, %d], "Op": "between"}
+		]}}`, in, from, to)
+	}
+
+	cq := compile(query("20, 21, 20", 5, 5))
+	expected := "((age in (:sqv0_0,:sqv0_1,:sqv0_2)) OR (age>:sqv1) OR (score>=:sqv2_1 AND score<=:sqv2_2))"
+	if !EqualSQLStrings(expected, cq.Conditions) {
+		t.Fatal("unexpected conditions", cq.Conditions)
+	}
+	if len(cq.SQLParams) != 6 {
+		t.Fatal("6 parameters expected, got", cq.SQLParams)
+	}
+
+	// одинаковый по форме запрос с другими значениями дает побайтно одинаковый SQL
+	cq2 := compile(query("1, 2, 3", 5, 10))
+	if cq.Conditions != cq2.Conditions {
+		t.Fatal("identical sql expected, got", cq.Conditions, "and", cq2.Conditions)
+	}
+
+	// пустой список - условие-константа вместо in ()
+	cq = compile(`{"Search": {"Op": "and", "Query": [
+		{"Col": "age", "Type": "int", "Val": [], "Op": "in"},
+		{"Col": "score", "Type": "int", "Val": [], "Op": "not in"}
+	]}}`)
+	if !EqualSQLStrings("((1=0) AND (1=1))", cq.Conditions) || len(cq.SQLParams) != 0 {
+		t.Fatal("unexpected conditions", cq.Conditions, cq.SQLParams)
+	}
+}
@@ -1,5 +1,9 @@
 package w3sql
 
+import (
+	"fmt"
+)
+
 type SelectQuery struct {
 	CompiledQueryParams
 	Conditions string //логические ограничения, например age < 35 and name='John'
@@ -26,6 +30,7 @@ func (q *Query) CompileSelect(
 	}
 	var err error
 	if q.Search != nil {
+		cs.path = "Search"
 		result.Conditions, err = q.Search.compile(cs)
 		if err != nil {
 			return nil, err
@@ -34,6 +39,7 @@ func (q *Query) CompileSelect(
 	if q.Sort != nil && len(q.Sort) > 0 {
 		result.Order = make([]string, len(q.Sort))
 		for i, sq := range q.Sort {
+			cs.path = fmt.Sprintf("Sort[%d]", i)
 			p, err := sq.compile(cs)
 			if err != nil {
 				return nil, err
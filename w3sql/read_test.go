@@ -74,7 +74,7 @@ offset 20`
 		t.Fatal("1 parameter expected, got", len((p)))
 	}
 
-	if x, ok := p["sqv0"].(float64); !ok || x != 23 {
+	if x, ok := p["sqv0"].(int64); !ok || x != 23 {
 		t.Fatal("expected 23 for age, got", fmt.Sprintf("<%v> <%T> <%v>", x, p["sqv0"], ok))
 	}
 
@@ -0,0 +1,126 @@
+package w3sql
+
+import (
+	"fmt"
+)
+
+// ServerColumn колонка, значение которой задает сервер при insert и update,
+// клиенту присылать ее запрещено
+type ServerColumn struct {
+	Col   string                                   //колонка SQL
+	Expr  string                                   //SQL выражение, например current_timestamp
+	Value func(params map[string]any) (any, error) //значение из Query.ServerParams, если Expr не задано
+}
+
+// значения серверных колонок для одной строки values, параметры добавляются в params
+func serverValues(cols []ServerColumn, serverParams map[string]any, params map[string]any) ([]string, error) {
+	result := make([]string, len(cols))
+	for i, c := range cols {
+		if c.Expr != "" {
+			result[i] = c.Expr
+			continue
+		}
+		if c.Value == nil {
+			return nil, fmt.Errorf("w3sql: server column %s has neither Expr nor Value", c.Col)
+		}
+		v, err := c.Value(serverParams)
+		if err != nil {
+			return nil, err
+		}
+		name := "sv_" + sanitizeParamName(c.Col)
+		params[name] = v
+		result[i] = ":" + name
+	}
+	return result, nil
+}
+
+func checkServerColumns(cols []ServerColumn, queryCols []string, path string) error {
+	for i, qc := range queryCols {
+		for _, c := range cols {
+			if c.Col == qc {
+				return &ErrForbiddenField{ErrorInfo: ErrorInfo{
+					Field: qc,
+					Path:  fmt.Sprintf("%s[%d]", path, i),
+				}}
+			}
+		}
+	}
+	return nil
+}
+
+// SetServerColumns добавляет серверные колонки к каждой строке insert,
+// serverParams - Query.ServerParams, Params клиента сюда передавать нельзя
+func (q *InsertQuery) SetServerColumns(cols []ServerColumn, serverParams map[string]any) error {
+	if len(cols) == 0 {
+		return nil
+	}
+	if err := checkServerColumns(cols, q.Cols, "Insert.Cols"); err != nil {
+		return err
+	}
+	if q.SQLParams == nil {
+		q.SQLParams = map[string]any{}
+	}
+	vals, err := serverValues(cols, serverParams, q.SQLParams)
+	if err != nil {
+		return err
+	}
+	for _, c := range cols {
+		q.Cols = append(q.Cols, c.Col)
+	}
+	for i := range q.Values {
+		q.Values[i] = append(q.Values[i], vals...)
+	}
+	return nil
+}
+
+// SetServerColumns добавляет серверные колонки в set каждой группы update
+func (q *UpdateQuery) SetServerColumns(cols []ServerColumn, serverParams map[string]any) error {
+	if len(cols) == 0 {
+		return nil
+	}
+	groups := append([]*UpdateQuery{q}, q.Groups...)
+	for _, g := range groups {
+		if len(g.Values) == 0 {
+			continue
+		}
+		if err := checkServerColumns(cols, g.Cols, "Update.Cols"); err != nil {
+			return err
+		}
+		if g.SQLParams == nil {
+			g.SQLParams = map[string]any{}
+		}
+		vals, err := serverValues(cols, serverParams, g.SQLParams)
+		if err != nil {
+			return err
+		}
+		for _, c := range cols {
+			g.Cols = append(g.Cols, c.Col)
+		}
+		for i := range g.Values {
+			g.Values[i] = append(g.Values[i], vals...)
+		}
+	}
+	return nil
+}
+
+// SetServerColumns добавляет серверные колонки в set
+func (q *UpdateWhereQuery) SetServerColumns(cols []ServerColumn, serverParams map[string]any) error {
+	if len(cols) == 0 {
+		return nil
+	}
+	if err := checkServerColumns(cols, q.Cols, "UpdateWhere.Cols"); err != nil {
+		return err
+	}
+	if q.SQLParams == nil {
+		q.SQLParams = map[string]any{}
+	}
+	vals, err := serverValues(cols, serverParams, q.SQLParams)
+	if err != nil {
+		return err
+	}
+	for i, c := range cols {
+		q.Cols = append(q.Cols, c.Col)
+		q.Set = append(q.Set, c.Col+" = "+vals[i])
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var serverColumns = []ServerColumn{
+	{Col: "created_at", Expr: "current_timestamp"},
+	{Col: "created_by", Value: func(params map[string]any) (any, error) {
+		return params["user"], nil
+	}},
+}
+
+func TestInsertServerColumns(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"Insert": {"Cols": ["name"], "Values": [["Vanya"], ["Petya"]]},
+		"Params": {"user": "hacker"}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iq, err := q.CompileInsert("postgres", map[string]string{"name": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// значение берется только из серверных параметров, не из Params клиента
+	if err := iq.SetServerColumns(serverColumns, map[string]any{"user": "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	qs, err := iq.SQL(NewSQLString("insert into students"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQS := `insert into students (name,created_at,created_by)
+values
+(:uiname0,current_timestamp,:sv_created_by),
+(:uiname1,current_timestamp,:sv_created_by)`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+	if qs[0].Params["sv_created_by"] != "admin" {
+		t.Fatal("unexpected params", qs[0].Params)
+	}
+}
+
+func TestUpdateServerColumnsForbidden(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"Update": {"Cols": ["created_by", "id"], "Values": [["hacker", 1]]}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uq, err := q.CompileUpdate("postgres", map[string]string{"created_by": "", "id": ""}, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = uq.SetServerColumns(serverColumns, nil)
+	if e, ok := err.(*ErrForbiddenField); !ok || e.Field != "created_by" || e.Path != "Update.Cols[0]" {
+		t.Fatal("expected forbidden field error, got", err)
+	}
+}
+
+func TestUpdateWhereServerColumns(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"UpdateWhere": {"Cols": ["age"], "Values": [30], "Confirm": true},
+		"Search": {"Col": "age", "Val": 20, "Op": ">", "Type": "int"}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldmap := map[string]string{"age": "", "created_by": ""}
+
+	uq, err := q.CompileUpdateWhere("postgres", fieldmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uq.SetServerColumns(serverColumns, map[string]any{"user": "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	qs, err := uq.SQL(updateBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQS := `update students set
+age = :uiage0,
+created_at = current_timestamp,
+created_by = :sv_created_by
+where (age>:sqv1)`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+	if qs[0].Params["sv_created_by"] != "admin" {
+		t.Fatal("unexpected params", qs[0].Params)
+	}
+
+	q.UpdateWhere.Cols = []string{"created_by"}
+	q.UpdateWhere.Values = []any{"hacker"}
+	uq, err = q.CompileUpdateWhere("postgres", fieldmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = uq.SetServerColumns(serverColumns, nil)
+	if e, ok := err.(*ErrForbiddenField); !ok || e.Field != "created_by" || e.Path != "UpdateWhere.Cols[0]" {
+		t.Fatal("expected forbidden field error, got", err)
+	}
+}
@@ -0,0 +1,146 @@
+package w3sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UpdateWhere обновляет одними значениями все строки, подходящие под Search запроса
+type UpdateWhere struct {
+	Cols    []string
+	Values  []any
+	Confirm bool //должен быть true, иначе запрос не компилируется
+}
+
+type UpdateWhereQuery struct {
+	CompiledQueryParams
+	SQLSyntax  string
+	Set        []string //например age = :uiage0
+	Cols       []string //колонки SQL из Set
+	Conditions string
+	Returning  []string
+}
+
+// emptyCondition true для nil и составного условия без атомарных условий внутри,
+// такое условие в where подошло бы под все строки
+func emptyCondition(c RawCondition) bool {
+	switch t := c.(type) {
+	case nil:
+		return true
+	case *CompoundCondition:
+		for _, q := range t.Query {
+			if !emptyCondition(q) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// CompileUpdateWhere компилирует UpdateWhere в update ... set ... where,
+// условие берется из Search и обязательно
+func (q *Query) CompileUpdateWhere(
+	sqlSyntax string,
+	fieldmap map[string]string,
+	transform ...ValueTransform,
+) (*UpdateWhereQuery, error) {
+	if q.UpdateWhere == nil {
+		return nil, nil
+	}
+	u := q.UpdateWhere
+
+	if !u.Confirm {
+		return nil, &ErrBadValue{
+			ErrorInfo: ErrorInfo{Path: "UpdateWhere.Confirm"},
+			Err:       errors.New("w3sql: update by condition is not confirmed"),
+		}
+	}
+	if emptyCondition(q.Search) {
+		return nil, &ErrBadValue{
+			ErrorInfo: ErrorInfo{Path: "Search"},
+			Err:       errors.New("w3sql: update by condition requires Search"),
+		}
+	}
+	if len(u.Cols) != len(u.Values) {
+		return nil, &ErrBadValue{
+			ErrorInfo: ErrorInfo{Path: "UpdateWhere.Values"},
+			Err:       errors.New("w3sql: wrong length of list of values"),
+		}
+	}
+
+	result := &UpdateWhereQuery{
+		SQLSyntax: sqlSyntax,
+		Set:       make([]string, 0, len(u.Cols)),
+		CompiledQueryParams: CompiledQueryParams{
+			Params: q.Params,
+		},
+	}
+	cs := &compilerSession{
+		sqlSyntax: sqlSyntax,
+		fieldmap:  fieldmap,
+		params:    map[string]any{},
+	}
+
+	for i, field := range u.Cols {
+		f, ok := fieldmap[field]
+		if !ok {
+			return nil, &ErrUnknownField{ErrorInfo: ErrorInfo{
+				Field: field,
+				Path:  fmt.Sprintf("UpdateWhere.Cols[%d]", i),
+			}}
+		}
+		if f == "" {
+			f = field
+		}
+		alias, ok, err := cs.compileWritePair(field, u.Values[i], transform...)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		result.Set = append(result.Set, f+" = :"+alias)
+		result.Cols = append(result.Cols, f)
+	}
+	if len(result.Set) == 0 {
+		return nil, &ErrBadValue{
+			ErrorInfo: ErrorInfo{Path: "UpdateWhere.Cols"},
+			Err:       errors.New("w3sql: nothing to update"),
+		}
+	}
+
+	cs.path = "Search"
+	cond, err := q.Search.compile(cs)
+	if err != nil {
+		return nil, err
+	}
+	result.Conditions = cond
+	result.SQLParams = cs.params
+	return result, nil
+}
+
+func (q *UpdateWhereQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
+	result := SQLQuery{Params: q.SQLParams}
+	if baseSQL != nil && len(baseSQL) > 0 {
+		result.Base = baseSQL[0].String()
+		result.Code += result.Base
+	}
+
+	result.Cols = " set\n" + strings.Join(q.Set, ",\n")
+	result.Code += result.Cols
+
+	result.Conditions = "where " + q.Conditions
+	result.Code += "\n" + result.Conditions
+
+	returning, err := returningSQL(q.SQLSyntax, q.Returning)
+	if err != nil {
+		return nil, err
+	}
+	if returning != "" {
+		result.Returning = returning
+		result.Code += "\n" + returning
+	}
+	return []SQLQuery{result}, nil
+}
@@ -0,0 +1,69 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var updateWhereFieldMap = map[string]string{
+	"status": "",
+	"age":    "",
+}
+
+func TestCompileUpdateWhere(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"UpdateWhere": {"Cols": ["status"], "Values": ["archived"], "Confirm": true},
+		"Search": {"Col": "age", "Val": 30, "Op": ">", "Type": "int"}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uq, err := q.CompileUpdateWhere("sqlite", updateWhereFieldMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, err := uq.SQL(NewSQLString("update students"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQS := `update students set
+status = :uistatus0
+where (age>:sqv1)`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+	if qs[0].Params["uistatus0"] != "archived" || qs[0].Params["sqv1"] != int64(30) {
+		t.Fatal("unexpected params:", qs[0].Params)
+	}
+}
+
+func TestCompileUpdateWhereGuards(t *testing.T) {
+	cases := []struct {
+		json string
+		path string
+	}{
+		{`{"UpdateWhere": {"Cols": ["status"], "Values": ["x"]}, "Search": {"Col": "age", "Val": 1, "Op": ">", "Type": "int"}}`, "UpdateWhere.Confirm"},
+		{`{"UpdateWhere": {"Cols": ["status"], "Values": ["x"], "Confirm": true}}`, "Search"},
+		{`{"UpdateWhere": {"Cols": ["status"], "Values": ["x"], "Confirm": true}, "Search": {"Op": "AND", "Query": [{"Op": "OR", "Query": []}]}}`, "Search"},
+		{`{"UpdateWhere": {"Cols": ["status"], "Values": [], "Confirm": true}, "Search": {"Col": "age", "Val": 1, "Op": ">", "Type": "int"}}`, "UpdateWhere.Values"},
+		{`{"UpdateWhere": {"Cols": ["name"], "Values": ["x"], "Confirm": true}, "Search": {"Col": "age", "Val": 1, "Op": ">", "Type": "int"}}`, "UpdateWhere.Cols[0]"},
+	}
+	for _, c := range cases {
+		var q Query
+		if err := json.Unmarshal([]byte(c.json), &q); err != nil {
+			t.Fatal(err)
+		}
+		_, err := q.CompileUpdateWhere("sqlite", updateWhereFieldMap)
+		var qe QueryError
+		if !errors.As(err, &qe) {
+			t.Fatal("expected query error for", c.json, "got", err)
+		}
+		if qe.Info().Path != c.path {
+			t.Fatal("unexpected error path", qe.Info().Path, "expected", c.path)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package w3sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type UpsertQuery struct {
+	InsertQuery
+	ConflictCols []string //колонки уникального ключа, по которому определяется конфликт
+	UpdateCols   []string //колонки, обновляемые при конфликте
+	KeepCols     []string //колонки, которые при конфликте не перезаписываются
+}
+
+// CompileUpsert компилирует строки из Insert в insert ... on conflict do update,
+// conflictCols и keepCols - имена колонок SQL, keepCols при конфликте не перезаписываются
+func (q *Query) CompileUpsert(
+	sqlSyntax string,
+	fieldmap map[string]string,
+	conflictCols []string,
+	keepCols []string,
+	transform ...ValueTransform,
+) (*UpsertQuery, error) {
+	iq, err := q.CompileInsert(sqlSyntax, fieldmap, transform...)
+	if err != nil {
+		return nil, err
+	}
+	if iq == nil {
+		return nil, &ErrMissingField{ErrorInfo: ErrorInfo{Field: "Insert", Path: "Insert"}}
+	}
+
+	if sqlSyntax != "mysql" && len(conflictCols) == 0 {
+		return nil, &ErrMissingField{ErrorInfo: ErrorInfo{Field: "ConflictCols"}}
+	}
+
+	skip := map[string]bool{}
+	for _, c := range keepCols {
+		skip[c] = true
+	}
+	for _, c := range conflictCols {
+		skip[c] = true
+	}
+
+	result := &UpsertQuery{
+		InsertQuery:  *iq,
+		ConflictCols: conflictCols,
+		UpdateCols:   make([]string, 0, len(iq.Cols)),
+		KeepCols:     keepCols,
+	}
+	for _, c := range iq.Cols {
+		if !skip[c] {
+			result.UpdateCols = append(result.UpdateCols, c)
+		}
+	}
+	return result, nil
+}
+
+// SetServerColumns добавляет серверные колонки к каждой строке insert и, кроме KeepCols,
+// к колонкам, обновляемым при конфликте
+func (q *UpsertQuery) SetServerColumns(cols []ServerColumn, serverParams map[string]any) error {
+	if err := q.InsertQuery.SetServerColumns(cols, serverParams); err != nil {
+		return err
+	}
+	for _, c := range cols {
+		keep := false
+		for _, k := range q.KeepCols {
+			keep = keep || k == c.Col
+		}
+		if !keep {
+			q.UpdateCols = append(q.UpdateCols, c.Col)
+		}
+	}
+	return nil
+}
+
+func (q *UpsertQuery) SQL(baseSQL ...*SQLString) ([]SQLQuery, error) {
+	// без значений ключа конфликта не будет, и строки молча добавятся заново;
+	// ключ может включать серверные колонки, поэтому проверка здесь, а не в CompileUpsert
+	for _, c := range q.ConflictCols {
+		found := false
+		for _, ic := range q.Cols {
+			found = found || ic == c
+		}
+		if !found {
+			return nil, &ErrMissingField{ErrorInfo: ErrorInfo{Field: c, Path: "Insert.Cols"}}
+		}
+	}
+
+	result := q.insertSQL(baseSQL...)
+
+	var conflict string
+	switch q.SQLSyntax {
+	case "postgres", "sqlite":
+		target := "on conflict (" + strings.Join(q.ConflictCols, ",") + ")"
+		if len(q.UpdateCols) == 0 {
+			conflict = target + " do nothing"
+			break
+		}
+		sets := make([]string, len(q.UpdateCols))
+		for i, c := range q.UpdateCols {
+			sets[i] = fmt.Sprintf("%s = excluded.%s", c, c)
+		}
+		conflict = target + " do update set\n" + strings.Join(sets, ",\n")
+	case "mysql":
+		cols := q.UpdateCols
+		if len(cols) == 0 {
+			// ничего не обновлять: присваивание колонки самой себе
+			cols = q.Cols[:1]
+		}
+		sets := make([]string, len(cols))
+		for i, c := range cols {
+			sets[i] = fmt.Sprintf("%s = values(%s)", c, c)
+		}
+		conflict = "on duplicate key update\n" + strings.Join(sets, ",\n")
+	default:
+		return nil, &ErrUnsupportedType{ErrorInfo: ErrorInfo{Op: "upsert"}, Type: q.SQLSyntax}
+	}
+
+	result.Conditions = conflict
+	result.Code += "\n" + conflict
+
+	returning, err := returningSQL(q.SQLSyntax, q.Returning)
+	if err != nil {
+		return nil, err
+	}
+	if returning != "" {
+		result.Returning = returning
+		result.Code += "\n" + returning
+	}
+	return []SQLQuery{result}, nil
+}
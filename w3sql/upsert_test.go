@@ -0,0 +1,135 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var upsertJSON = `{
+	"Insert": {
+		"Cols": ["id", "name", "age", "created"],
+		"Values": [
+			[1, "Vanya", 21, 100],
+			[2, "Masha", 20, 200]
+		]
+	}
+}`
+
+func TestCompileUpsert(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(upsertJSON), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldmap := map[string]string{"id": "studentID", "name": "", "age": "", "created": ""}
+
+	uq, err := q.CompileUpsert("postgres", fieldmap, []string{"studentID"}, []string{"created"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qs, err := uq.SQL(insertBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("QUERY:", qs[0].Code)
+	fmt.Println("PARAMS:", qs[0].Params)
+
+	expectedQS := `insert into students (studentID,name,age,created)
+values
+(:uiid0,:uiname1,:uiage2,:uicreated3),
+(:uiid4,:uiname5,:uiage6,:uicreated7)
+on conflict (studentID) do update set
+name = excluded.name,
+age = excluded.age`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal(
+			"unexpected sql string result, got:",
+			fmt.Sprintf("<%s>", qs[0].Code),
+			"\nexpected",
+			fmt.Sprintf("<%s>", expectedQS),
+		)
+	}
+
+	uq, err = q.CompileUpsert("mysql", fieldmap, nil, []string{"created", "name", "age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, err = uq.SQL(insertBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQS = `insert into students (studentID,name,age,created)
+values
+(:uiid0,:uiname1,:uiage2,:uicreated3),
+(:uiid4,:uiname5,:uiage6,:uicreated7)
+on duplicate key update
+studentID = values(studentID)`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal(
+			"unexpected sql string result, got:",
+			fmt.Sprintf("<%s>", qs[0].Code),
+			"\nexpected",
+			fmt.Sprintf("<%s>", expectedQS),
+		)
+	}
+
+	var me *ErrMissingField
+	if _, err = q.CompileUpsert("sqlite", fieldmap, nil, nil); !errors.As(err, &me) {
+		t.Fatal("missing field error expected without conflict columns, got", err)
+	}
+	uq, err = q.CompileUpsert("sqlite", fieldmap, []string{"email"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = uq.SQL(insertBaseSQL); !errors.As(err, &me) || me.Field != "email" {
+		t.Fatal("missing field error expected for conflict column without values, got", err)
+	}
+	uq, err = q.CompileUpsert("oracle", fieldmap, []string{"studentID"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var qe QueryError
+	if _, err = uq.SQL(insertBaseSQL); !errors.As(err, &qe) || qe.Kind() != "unsupported_type" {
+		t.Fatal("unsupported type error expected for dialect, got", err)
+	}
+}
+
+func TestUpsertServerColumns(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(upsertJSON), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldmap := map[string]string{"id": "studentID", "name": "", "age": "", "created": ""}
+
+	uq, err := q.CompileUpsert("postgres", fieldmap, []string{"studentID"}, []string{"created", "created_by"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = uq.SetServerColumns([]ServerColumn{
+		{Col: "created_by", Expr: "current_user"},
+		{Col: "updated_at", Expr: "current_timestamp"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, err := uq.SQL(insertBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQS := `insert into students (studentID,name,age,created,created_by,updated_at)
+values
+(:uiid0,:uiname1,:uiage2,:uicreated3,current_user,current_timestamp),
+(:uiid4,:uiname5,:uiage6,:uicreated7,current_user,current_timestamp)
+on conflict (studentID) do update set
+name = excluded.name,
+age = excluded.age,
+updated_at = excluded.updated_at`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", fmt.Sprintf("<%s>", qs[0].Code))
+	}
+}
@@ -0,0 +1,343 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Запись запроса в строке URL, например
+//
+//	?limit=50&offset=100&sort=-age,name&age[gt]=20&name[starts]=pe&id[in]=1,2,3&grade[between]=60..80
+//
+// условия - ключи колонка[оператор] или колонка[оператор:тип], все они объединяются через AND,
+// тип по умолчанию берется из объявленных типов полей, иначе выводится из значения, как в ParseFilter
+// (name[eq]=123 без объявленного типа - условие int); условия, которые так не записать
+// (or, not, вложенные, значения с запятыми), пишутся языком фильтров в параметр filter.
+// Прочие ключи без скобок, например format или view, не читаются.
+
+var urlOps = map[string]string{
+	"eq":       "==",
+	"ne":       "!=",
+	"gt":       ">",
+	"ge":       ">=",
+	"lt":       "<",
+	"le":       "<=",
+	"starts":   "begins",
+	"contains": "contains",
+	"ends":     "ends",
+	"in":       "in",
+	"nin":      "not in",
+	"between":  "between",
+}
+
+// имя оператора в языке фильтров -> код в URL
+var urlOpCodes = map[string]string{
+	"=":        "eq",
+	"!=":       "ne",
+	">":        "gt",
+	">=":       "ge",
+	"<":        "lt",
+	"<=":       "le",
+	"starts":   "starts",
+	"contains": "contains",
+	"ends":     "ends",
+	"in":       "in",
+	"not in":   "nin",
+	"between":  "between",
+}
+
+var (
+	urlCondKey = regexp.MustCompile(`^(.+)\[([a-z]+)(?::([a-z]+))?\]$`)
+	urlNumber  = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?([eE][-+]?\d+)?$`)
+)
+
+// числовые типы, для остальных явно указанный тип оставляет значение строкой
+func urlNumericType(tp string) bool {
+	switch tp {
+	case "int", "number", "float", "decimal":
+		return true
+	}
+	return false
+}
+
+// urlValue значение условия и тип, который из него следует
+func urlValue(s string) (any, string) {
+	switch {
+	case urlNumber.MatchString(s):
+		if strings.ContainsAny(s, ".eE") {
+			return json.Number(s), "number"
+		}
+		return json.Number(s), "int"
+	case filterDate.MatchString(s):
+		return s, "date"
+	case filterDateTime.MatchString(s):
+		return s, "datetime"
+	}
+	return s, "text"
+}
+
+func urlBadValue(key string, err error) error {
+	return &ErrBadValue{ErrorInfo: ErrorInfo{Path: key}, Err: err}
+}
+
+func urlStrings(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		return t.String()
+	case []any:
+		for i := range t {
+			t[i] = urlStrings(t[i])
+		}
+	}
+	return v
+}
+
+func urlCondition(key string, value string, types map[string]string) (*AtomaryCondition, error) {
+	m := urlCondKey.FindStringSubmatch(key)
+	op, ok := urlOps[m[2]]
+	if !ok {
+		return nil, &ErrUnsupportedOperator{ErrorInfo: ErrorInfo{Field: m[1], Op: m[2], Path: key}}
+	}
+	c := &AtomaryCondition{Col: m[1], Op: op, Type: m[3]}
+	if c.Type == "" {
+		c.Type = types[c.Col]
+	}
+
+	var (
+		vals []string
+		tp   string
+	)
+	switch op {
+	case "in", "not in":
+		vals = strings.Split(value, ",")
+	case "between":
+		vals = strings.SplitN(value, "..", 2)
+		if len(vals) != 2 {
+			return nil, urlBadValue(key, errors.New("w3sql: range a..b expected"))
+		}
+	default:
+		c.Val, tp = urlValue(value)
+	}
+	if vals != nil {
+		list := make([]any, len(vals))
+		for i, v := range vals {
+			var vt string
+			list[i], vt = urlValue(v)
+			if i == 0 {
+				tp = vt
+			}
+		}
+		c.Val = list
+	}
+	if c.Type == "" {
+		c.Type = tp
+	} else if !urlNumericType(c.Type) {
+		c.Val = urlStrings(c.Val)
+	}
+	return c, nil
+}
+
+// ParseURLQuery читает запрос из строки URL (без '?'), порядок условий сохраняется;
+// types - объявленные типы полей (поле -> тип условия), тип в ключе условия важнее
+func ParseURLQuery(raw string, types ...map[string]string) (*Query, error) {
+	var fieldTypes map[string]string
+	if len(types) > 0 {
+		fieldTypes = types[0]
+	}
+	q := &Query{}
+	var (
+		conds  []RawCondition
+		filter []RawCondition
+	)
+	for _, part := range strings.Split(raw, "&") {
+		if part == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			return nil, urlBadValue(k, err)
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return nil, urlBadValue(key, err)
+		}
+
+		switch key {
+		case "limit", "offset":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, urlBadValue(key, errors.New("w3sql: non-negative integer expected"))
+			}
+			if key == "limit" {
+				q.Limit = &n
+			} else {
+				q.Offset = &n
+			}
+		case "sort":
+			for _, col := range strings.Split(value, ",") {
+				if col == "" {
+					continue
+				}
+				if strings.HasPrefix(col, "-") {
+					q.Sort = append(q.Sort, SortQuery{Col: col[1:], Dir: "desc"})
+				} else {
+					q.Sort = append(q.Sort, SortQuery{Col: col, Dir: "asc"})
+				}
+			}
+		case "filter":
+			c, err := ParseFilter(value)
+			if err != nil {
+				var se *ErrSyntax
+				if errors.As(err, &se) {
+					se.Path = "filter"
+				}
+				return nil, err
+			}
+			if c != nil {
+				filter = append(filter, c)
+			}
+		default:
+			if !urlCondKey.MatchString(key) {
+				continue
+			}
+			c, err := urlCondition(key, value, fieldTypes)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, c)
+		}
+	}
+
+	conds = append(conds, filter...)
+	switch len(conds) {
+	case 0:
+	case 1:
+		q.Search = conds[0]
+	default:
+		q.Search = &CompoundCondition{Op: "AND", Query: conds}
+	}
+	return q, nil
+}
+
+// urlParam записывает условие как ключ и значение или возвращает false, если нужен filter
+func urlParam(c RawCondition) (string, string, bool) {
+	a, ok := c.(*AtomaryCondition)
+	if !ok {
+		return "", "", false
+	}
+	code, ok := urlOpCodes[filterOpNames[strings.ToLower(a.Op)]]
+	if !ok || strings.ContainsAny(a.Col, "[]") {
+		return "", "", false
+	}
+
+	text := func(v any) (string, string, bool) {
+		var s string
+		switch t := v.(type) {
+		case string:
+			s = t
+		case json.Number, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			s, _ = formatFilterValue(t)
+		default:
+			return "", "", false
+		}
+		_, tp := urlValue(s)
+		// строка, похожая на число, прочитается числом, если тип не указан явно
+		if _, isString := v.(string); isString && urlNumericType(tp) && (a.Type == "" || urlNumericType(a.Type)) {
+			return "", "", false
+		}
+		return s, tp, true
+	}
+
+	var (
+		value string
+		tp    string
+	)
+	if list, isList := a.Val.([]any); isList {
+		if code != "in" && code != "nin" && code != "between" || len(list) == 0 {
+			return "", "", false
+		}
+		sep := ","
+		if code == "between" {
+			if len(list) != 2 {
+				return "", "", false
+			}
+			sep = ".."
+		}
+		parts := make([]string, len(list))
+		for i, e := range list {
+			s, et, ok := text(e)
+			if !ok || strings.Contains(s, ",") || strings.Contains(s, "..") {
+				return "", "", false
+			}
+			if i == 0 {
+				tp = et
+			}
+			parts[i] = s
+		}
+		value = strings.Join(parts, sep)
+	} else {
+		if code == "in" || code == "nin" || code == "between" {
+			return "", "", false
+		}
+		var ok bool
+		if value, tp, ok = text(a.Val); !ok {
+			return "", "", false
+		}
+	}
+
+	key := url.QueryEscape(a.Col) + "[" + code
+	if a.Type != tp && a.Type != "" {
+		key += ":" + a.Type
+	}
+	return key + "]", url.QueryEscape(value), true
+}
+
+// URLQuery записывает Limit, Offset, Sort и Search в строку URL без '?', ParseURLQuery читает ее
+// в равносильный запрос: условия из filter оказываются после остальных
+func (q *Query) URLQuery() string {
+	var parts []string
+	if q.Limit != nil {
+		parts = append(parts, "limit="+strconv.Itoa(*q.Limit))
+	}
+	if q.Offset != nil {
+		parts = append(parts, "offset="+strconv.Itoa(*q.Offset))
+	}
+	if len(q.Sort) > 0 {
+		cols := make([]string, len(q.Sort))
+		for i, s := range q.Sort {
+			cols[i] = s.Col
+			if strings.EqualFold(s.Dir, "desc") {
+				cols[i] = "-" + s.Col
+			}
+		}
+		parts = append(parts, "sort="+url.QueryEscape(strings.Join(cols, ",")))
+	}
+
+	var conds []RawCondition
+	if c, ok := q.Search.(*CompoundCondition); ok && strings.EqualFold(c.Op, "AND") {
+		conds = c.Query
+	} else if q.Search != nil {
+		conds = []RawCondition{q.Search}
+	}
+	var rest []RawCondition
+	for _, c := range conds {
+		if k, v, ok := urlParam(c); ok {
+			parts = append(parts, k+"="+v)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	switch len(rest) {
+	case 0:
+	case 1:
+		parts = append(parts, "filter="+url.QueryEscape(FormatFilter(rest[0])))
+	default:
+		parts = append(parts, "filter="+url.QueryEscape(FormatFilter(&CompoundCondition{Op: "AND", Query: rest})))
+	}
+	return strings.Join(parts, "&")
+}
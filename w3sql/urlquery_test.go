@@ -0,0 +1,106 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseURLQuery(t *testing.T) {
+	q, err := ParseURLQuery("sort=-age,name&age[gt]=20&name[starts]=pe&limit=50&format=csv" +
+		"&id[in]=1,2,3&grade[between]=60..80&code[eq:text]=007&born[ge]=2001-02-03&filter=a+%3D+1+or+b+%3D+2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *q.Limit != 50 || q.Offset != nil {
+		t.Fatal("unexpected limit and offset:", q.Limit, q.Offset)
+	}
+	if len(q.Sort) != 2 || q.Sort[0] != (SortQuery{Col: "age", Dir: "desc"}) || q.Sort[1] != (SortQuery{Col: "name", Dir: "asc"}) {
+		t.Fatal("unexpected sort:", q.Sort)
+	}
+	expected := `{"Op":"AND","Query":[` +
+		`{"Col":"age","Type":"int","Val":20,"Op":">"},` +
+		`{"Col":"name","Type":"text","Val":"pe","Op":"begins"},` +
+		`{"Col":"id","Type":"int","Val":[1,2,3],"Op":"in"},` +
+		`{"Col":"grade","Type":"int","Val":[60,80],"Op":"between"},` +
+		`{"Col":"code","Type":"text","Val":"007","Op":"=="},` +
+		`{"Col":"born","Type":"date","Val":"2001-02-03","Op":">="},` +
+		`{"Op":"OR","Query":[{"Col":"a","Type":"int","Val":1,"Op":"=="},{"Col":"b","Type":"int","Val":2,"Op":"=="}]}]}`
+	if s := filterJSON(q.Search); s != expected {
+		t.Fatal("unexpected tree:\n", s)
+	}
+
+	cq, err := q.CompileSelect("sqlite", map[string]string{"age": "", "name": "", "id": "", "grade": "score", "code": "", "born": "", "a": "", "b": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cq.SQLParams["sqv4"] != "007" {
+		t.Fatal("text value expected, got", cq.SQLParams["sqv4"])
+	}
+
+	// объявленный тип поля важнее выведенного из значения, тип в ключе важнее объявленного
+	q, err = ParseURLQuery("name[eq]=123&code[in]=1,2&age[gt]=20&name[ne:int]=5", map[string]string{"name": "text", "code": "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"Op":"AND","Query":[` +
+		`{"Col":"name","Type":"text","Val":"123","Op":"=="},` +
+		`{"Col":"code","Type":"text","Val":["1","2"],"Op":"in"},` +
+		`{"Col":"age","Type":"int","Val":20,"Op":">"},` +
+		`{"Col":"name","Type":"int","Val":5,"Op":"!="}]}`
+	if s := filterJSON(q.Search); s != expected {
+		t.Fatal("unexpected tree with field types:\n", s)
+	}
+
+	for _, c := range []struct {
+		raw  string
+		kind string
+	}{
+		{"limit=x", "bad_value"},
+		{"age[like]=1", "unsupported_operator"},
+		{"grade[between]=1", "bad_value"},
+		{"filter=age+>", "syntax_error"},
+	} {
+		_, err := ParseURLQuery(c.raw)
+		var qe QueryError
+		if !errors.As(err, &qe) || qe.Kind() != c.kind {
+			t.Fatal("error", c.kind, "expected for", c.raw, "got", err)
+		}
+	}
+}
+
+func TestURLQuery(t *testing.T) {
+	cases := []string{
+		"limit=50&offset=10&sort=-age%2Cname&age[gt]=20&name[starts]=pe",
+		"id[nin]=1%2C2&grade[between]=1.5..2&code[eq]=x&born[lt:datetime]=2001-02-03&n[eq:text]=20",
+		"age[ge]=1&filter=name+%3D+%2220%22+or+not+age+%3C+3",
+		"filter=a+in+%28%22x%2Cy%22%29+and+b+%3D+null",
+		"sort=name",
+	}
+	for _, raw := range cases {
+		q, err := ParseURLQuery(raw)
+		if err != nil {
+			t.Fatal(raw, err)
+		}
+		if s := q.URLQuery(); s != raw {
+			t.Fatal("round trip failed:\n", raw, "\n", s)
+		}
+	}
+
+	// условия фронта с русскими операторами, OR уходит в filter
+	var jq Query
+	err := json.Unmarshal([]byte(`{"Search": {"Op": "and", "Query": [
+		{"Col": "age", "Type": "int", "Val": 23, "Op": "равен"},
+		{"Op": "or", "Query": [
+			{"Col": "name", "Type": "text", "Val": "iv", "Op": "начинается с"},
+			{"Col": "name", "Type": "text", "Val": "25", "Op": "содержит"}
+		]}
+	]}}`), &jq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "age[eq]=23&filter=name+starts+%22iv%22+or+name+contains+%2225%22"
+	if s := jq.URLQuery(); s != expected {
+		t.Fatal("unexpected url query:\n", s)
+	}
+}
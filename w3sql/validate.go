@@ -0,0 +1,52 @@
+package w3sql
+
+import (
+	"fmt"
+)
+
+func (cs *compilerSession) validate(c RawCondition) []error {
+	q, ok := c.(*CompoundCondition)
+	if !ok {
+		if _, err := c.compile(cs); err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+
+	var errs []error
+	if _, _, err := cs.compoundLogics(q); err != nil {
+		errs = append(errs, err)
+	}
+
+	path := cs.path
+	defer func() { cs.path = path }()
+
+	for i, qp := range q.Query {
+		cs.path = fmt.Sprintf("%s.Query[%d]", path, i)
+		errs = append(errs, cs.validate(qp)...)
+	}
+	return errs
+}
+
+// Validate в отличие от CompileSelect возвращает сразу все ошибки условий и сортировки,
+// ошибки компиляции имеют тип QueryError
+func (q *Query) Validate(fieldmap map[string]string, sqlSyntax string) []error {
+	cs := &compilerSession{
+		sqlSyntax: sqlSyntax,
+		fieldmap:  fieldmap,
+		params:    map[string]any{},
+	}
+
+	var errs []error
+	if q.Search != nil {
+		cs.path = "Search"
+		errs = cs.validate(q.Search)
+	}
+	for i, sq := range q.Sort {
+		cs.path = fmt.Sprintf("Sort[%d]", i)
+		if _, err := sq.compile(cs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
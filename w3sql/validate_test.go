@@ -0,0 +1,69 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s := `{
+		"Sort": [{"Col": "name", "Dir": "desc"}, {"Col": "score", "Dir": "asc"}],
+		"Search": {
+			"Op": "and",
+			"Query": [
+				{"Col": "age", "Type": "int", "Val": "twenty", "Op": "<="},
+				{
+					"Op": "or",
+					"Query": [
+						{"Col": "name", "Type": "string", "Val": "Bob", "Op": "contains"},
+						{"Col": "surname", "Type": "string", "Val": "Alice", "Op": "starts with"},
+						{"Col": "name", "Type": "string", "Val": "Alice", "Op": "like"}
+					]
+				}
+			]
+		}
+	}`
+	var q Query
+	err := json.Unmarshal([]byte(s), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := q.Validate(map[string]string{"age": "", "name": ""}, "sqlite")
+	expected := []struct {
+		kind string
+		path string
+	}{
+		{"bad_value", "Search.Query[0]"},
+		{"unknown_field", "Search.Query[1].Query[1]"},
+		{"unsupported_operator", "Search.Query[1].Query[2]"},
+		{"unknown_field", "Sort[1]"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatal(len(expected), "errors expected, got", errs)
+	}
+	for i, e := range expected {
+		var qe QueryError
+		if !errors.As(errs[i], &qe) {
+			t.Fatal("QueryError expected, got", errs[i])
+		}
+		if qe.Kind() != e.kind || qe.Info().Path != e.path {
+			t.Fatalf("unexpected error %q: kind %s, path %s", qe.Error(), qe.Kind(), qe.Info().Path)
+		}
+	}
+
+	errs = q.Validate(map[string]string{"age": "", "name": "", "surname": "", "score": ""}, "sqlite")
+	if len(errs) != 2 {
+		t.Fatal("2 errors expected, got", errs)
+	}
+
+	var ok Query
+	err = json.Unmarshal([]byte(compoundJSON), &ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs = ok.Validate(map[string]string{"age": "", "name": ""}, "sqlite"); len(errs) != 0 {
+		t.Fatal("no errors expected, got", errs)
+	}
+}
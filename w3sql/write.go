@@ -3,19 +3,66 @@ package w3sql
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 type InsertQuery struct {
 	CompiledQueryParams
-	Cols   []string
-	Values [][]string
+	SQLSyntax string
+	Cols      []string
+	Values    [][]string
+	Returning []string //колонки для returning, только postgres и sqlite 3.35+
 }
 
 type UpdateQuery struct {
 	CompiledQueryParams
-	IDField string
-	Cols    []string
-	Values  [][]string
+	SQLSyntax string
+	IDField   string
+	Cols      []string
+	Values    [][]string
+	Returning []string //колонки для returning, в postgres их следует уточнять именем таблицы
+
+	// колонка версии строки для оптимистической блокировки, ее значение от фронта проверяется в where,
+	// счетчик увеличивается на 1, а при VersionTimestamp колонке присваивается current_timestamp
+	VersionCol       string
+	VersionTimestamp bool
+
+	Groups []*UpdateQuery //строки Update.Rows, сгруппированные по набору колонок, каждая группа - отдельный update
+}
+
+// RowCount число обновляемых строк во всех группах
+func (q *UpdateQuery) RowCount() int {
+	n := 0
+	if q.hasSetCols() {
+		n = len(q.Values)
+	}
+	for _, g := range q.Groups {
+		n += len(g.Values)
+	}
+	return n
+}
+
+// hasSetCols true, если кроме ID есть колонки для set
+func (q *UpdateQuery) hasSetCols() bool {
+	for _, c := range q.Cols {
+		if c != q.IDField {
+			return true
+		}
+	}
+	return false
+}
+
+// параметры, которые используются в values
+func paramsOf(values [][]string, all map[string]any) map[string]any {
+	result := map[string]any{}
+	for _, row := range values {
+		for _, v := range row {
+			name := strings.TrimPrefix(v, ":")
+			result[name] = all[name]
+		}
+	}
+	return result
 }
 
 func IsDefaultValue(v any) bool {
@@ -34,6 +81,8 @@ func IsDefaultValue(v any) bool {
 	return false
 }
 
+// ValueTransform числа из JSON получает как float64; если число вернулось без изменений,
+// в SQL передается его точное значение
 type ValueTransform func(field string, value any) (any, error)
 
 func (cs *compilerSession) compileWritePair(
@@ -42,6 +91,9 @@ func (cs *compilerSession) compileWritePair(
 	transform ...ValueTransform,
 ) (string, bool, error) {
 	v := value
+	if len(transform) > 0 {
+		v = transformArg(value)
+	}
 	var err error
 	for _, fn := range transform {
 		if fn == nil {
@@ -56,9 +108,9 @@ func (cs *compilerSession) compileWritePair(
 		}
 	}
 
-	alias := fmt.Sprintf("ui%s%d", field, cs.varCounter)
+	v = exactIfUnchanged(value, v)
+	alias := cs.param(fmt.Sprintf("ui%s%d", field, cs.varCounter), normalizeNumber(v))
 	cs.varCounter++
-	cs.params[alias] = v
 	return alias, true, nil
 }
 
@@ -71,8 +123,9 @@ func (q *Query) CompileInsert(
 		return nil, nil
 	}
 	result := &InsertQuery{
-		Cols:   make([]string, len(q.Insert.Cols)),
-		Values: make([][]string, 0, len(q.Insert.Values)),
+		SQLSyntax: sqlSyntax,
+		Cols:      make([]string, len(q.Insert.Cols)),
+		Values:    make([][]string, 0, len(q.Insert.Values)),
 		CompiledQueryParams: CompiledQueryParams{
 			Params: q.Params,
 		},
@@ -86,7 +139,10 @@ func (q *Query) CompileInsert(
 	for i, field := range q.Insert.Cols {
 		f, ok := fieldmap[field]
 		if !ok {
-			return nil, errors.New("w3sql: no such field: " + field)
+			return nil, &ErrUnknownField{ErrorInfo: ErrorInfo{
+				Field: field,
+				Path:  fmt.Sprintf("Insert.Cols[%d]", i),
+			}}
 		}
 		if f == "" {
 			f = field
@@ -97,7 +153,10 @@ func (q *Query) CompileInsert(
 rows:
 	for i, vals := range q.Insert.Values {
 		if len(vals) != len(result.Cols) {
-			return nil, errors.New("w3sql: wrong length of list of values in position " + fmt.Sprint(i))
+			return nil, &ErrBadValue{
+				ErrorInfo: ErrorInfo{Path: fmt.Sprintf("Insert.Values[%d]", i)},
+				Err:       errors.New("wrong length of list of values in position " + fmt.Sprint(i)),
+			}
 		}
 		rVals := make([]string, len(vals))
 		for j, v := range vals {
@@ -128,9 +187,10 @@ func (q *Query) CompileUpdate(
 		return nil, nil
 	}
 	result := &UpdateQuery{
-		Cols:    make([]string, len(q.Update.Cols)),
-		Values:  make([][]string, 0, len(q.Update.Values)),
-		IDField: idFieldName,
+		SQLSyntax: sqlSyntax,
+		Cols:      make([]string, len(q.Update.Cols)),
+		Values:    make([][]string, 0, len(q.Update.Values)),
+		IDField:   idFieldName,
 		CompiledQueryParams: CompiledQueryParams{
 			Params: q.Params,
 		},
@@ -141,45 +201,171 @@ func (q *Query) CompileUpdate(
 		params:    map[string]any{},
 	}
 
-	idFound := false
-	for i, field := range q.Update.Cols {
-		f, ok := fieldmap[field]
-		if !ok {
-			return nil, errors.New("w3sql: no such field: " + field)
+	if len(q.Update.Cols) > 0 || len(q.Update.Rows) == 0 {
+		idFound := false
+		for i, field := range q.Update.Cols {
+			f, ok := fieldmap[field]
+			if !ok {
+				return nil, &ErrUnknownField{ErrorInfo: ErrorInfo{
+					Field: field,
+					Path:  fmt.Sprintf("Update.Cols[%d]", i),
+				}}
+			}
+			if f == "" {
+				f = field
+			}
+			if f == idFieldName {
+				idFound = true
+			}
+			result.Cols[i] = f
 		}
-		if f == "" {
-			f = field
+
+		if !idFound {
+			return nil, &ErrMissingField{ErrorInfo: ErrorInfo{
+				Field: idFieldName,
+				Path:  "Update.Cols",
+			}}
 		}
-		if f == idFieldName {
-			idFound = true
+
+	rows:
+		for i, vals := range q.Update.Values {
+			if len(vals) != len(result.Cols) {
+				return nil, &ErrBadValue{
+					ErrorInfo: ErrorInfo{Path: fmt.Sprintf("Update.Values[%d]", i)},
+					Err:       errors.New("wrong length of list of values in position " + fmt.Sprint(i)),
+				}
+			}
+			rVals := make([]string, len(vals))
+			for j, v := range vals {
+				field := q.Update.Cols[j]
+				alias, ok, err := cs.compileWritePair(field, v, transform...)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					continue rows
+				}
+				rVals[j] = ":" + alias
+			}
+			result.Values = append(result.Values, rVals)
 		}
-		result.Cols[i] = f
 	}
 
-	if !idFound {
-		return nil, errors.New("w3sql: id not found")
+	if len(q.Update.Rows) > 0 {
+		err := cs.compileUpdateRows(result, q.Update.Rows, transform...)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	result.CompiledQueryParams.SQLParams = paramsOf(result.Values, cs.params)
+	return result, nil
+}
+
+// строки Update.Rows группируются по набору присланных колонок, чтобы не перезаписывать
+// неизмененные поля, nil от transform пропускает только эту колонку
+func (cs *compilerSession) compileUpdateRows(
+	parent *UpdateQuery,
+	rows []map[string]any,
+	transform ...ValueTransform,
+) error {
+	groups := map[string]*UpdateQuery{}
+
 rows:
-	for i, vals := range q.Update.Values {
-		if len(vals) != len(result.Cols) {
-			return nil, errors.New("w3sql: wrong length of list of values in position " + fmt.Sprint(i))
+	for i, row := range rows {
+		fields := make([]string, 0, len(row))
+		for field := range row {
+			fields = append(fields, field)
 		}
-		rVals := make([]string, len(vals))
-		for j, v := range vals {
-			field := q.Update.Cols[j]
-			alias, ok, err := cs.compileWritePair(field, v, transform...)
+		sort.Strings(fields)
+
+		cols := make([]string, 0, len(fields))
+		vals := make([]string, 0, len(fields))
+		idFound := false
+		for _, field := range fields {
+			f, ok := cs.fieldmap[field]
+			if !ok {
+				return &ErrUnknownField{ErrorInfo: ErrorInfo{
+					Field: field,
+					Path:  fmt.Sprintf("Update.Rows[%d]", i),
+				}}
+			}
+			if f == "" {
+				f = field
+			}
+			alias, ok, err := cs.compileWritePair(field, row[field], transform...)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			if !ok {
-				continue rows
+				if f == parent.IDField {
+					continue rows
+				}
+				continue
 			}
-			rVals[j] = ":" + alias
+			if f == parent.IDField {
+				idFound = true
+			}
+			cols = append(cols, f)
+			vals = append(vals, ":"+alias)
 		}
-		result.Values = append(result.Values, rVals)
+
+		if !idFound {
+			return &ErrMissingField{ErrorInfo: ErrorInfo{
+				Field: parent.IDField,
+				Path:  fmt.Sprintf("Update.Rows[%d]", i),
+			}}
+		}
+		if len(cols) < 2 {
+			continue //кроме ID нечего обновлять
+		}
+
+		key := strings.Join(cols, ",")
+		g, ok := groups[key]
+		if !ok {
+			g = &UpdateQuery{
+				SQLSyntax: parent.SQLSyntax,
+				IDField:   parent.IDField,
+				Cols:      cols,
+				CompiledQueryParams: CompiledQueryParams{
+					Params: parent.Params,
+				},
+			}
+			groups[key] = g
+			parent.Groups = append(parent.Groups, g)
+		}
+		g.Values = append(g.Values, vals)
 	}
 
-	result.CompiledQueryParams.SQLParams = cs.params
-	return result, nil
+	for _, g := range parent.Groups {
+		g.SQLParams = paramsOf(g.Values, cs.params)
+	}
+	return nil
+}
+
+// MaxParams наибольшее число параметров в одном запросе для диалекта
+func MaxParams(sqlSyntax string) int {
+	switch sqlSyntax {
+	case "sqlite":
+		return 32766
+	case "postgres", "mysql":
+		return 65535
+	}
+	return 999
+}
+
+// Chunks делит строки insert на части не больше maxRows строк, параметры делятся вместе со строками
+func (q *InsertQuery) Chunks(maxRows int) []*InsertQuery {
+	if maxRows <= 0 || len(q.Values) <= maxRows {
+		return []*InsertQuery{q}
+	}
+	result := make([]*InsertQuery, 0, (len(q.Values)+maxRows-1)/maxRows)
+	for start := 0; start < len(q.Values); start += maxRows {
+		end := min(start+maxRows, len(q.Values))
+		c := *q
+		c.Values = q.Values[start:end]
+		c.SQLParams = paramsOf(c.Values, q.SQLParams)
+		result = append(result, &c)
+	}
+	return result
 }
@@ -3,6 +3,7 @@ package w3sql
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -126,15 +127,17 @@ func TestCompileUpdate(t *testing.T) {
 
 	fmt.Println("QUERY:", qs[0].Code)
 	fmt.Println("PARAMS:", p)
+	// sqlite не принимает список имен колонок в алиасе values, поэтому колонки c.column1, c.column2...;
+	// запятая перед from, которую ожидал этот тест раньше, давала неверный SQL в любом диалекте
 	expectedQS := `update students set
-name = c.name,
-age = c.age,
-score_value = c.score_value,
+name = c.column1,
+age = c.column2,
+score_value = c.column3
 from (values
 (:uiname0,:uiage1,:uiscore2,:uiid3),
 (:uiname4,:uiage5,:uiscore6,:uiid7)
-) as c(name,age,score_value,id)
-where id = c.id`
+) as c
+where id = c.column4`
 	if !EqualSQLStrings(expectedQS, qs[0].Code) {
 		t.Fatal(
 			"unexpected sql string result, got:",
@@ -144,3 +147,295 @@ where id = c.id`
 		)
 	}
 }
+
+func TestCompileUpdatePostgres(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(updateJSON), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uq, err := q.CompileUpdate("postgres", map[string]string{
+		"name":  "",
+		"age":   "",
+		"score": "score_value",
+		"id":    "",
+	}, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qs, err := uq.SQL(updateBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQS := `update students set
+name = c.name,
+age = c.age,
+score_value = c.score_value
+from (values
+(:uiname0,:uiage1,:uiscore2,:uiid3),
+(:uiname4,:uiage5,:uiscore6,:uiid7),
+(:uiname8,:uiage9,:uiscore10,:uiid11)
+) as c(name,age,score_value,id)
+where id = c.id`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+}
+
+func TestCompileUpdateReturning(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(updateJSON), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uq, err := q.CompileUpdate("sqlite", map[string]string{
+		"name":  "",
+		"age":   "",
+		"score": "score_value",
+		"id":    "",
+	}, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uq.Returning = []string{"id", "name"}
+
+	qs, err := uq.SQL(updateBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQS := `update students set
+name = c.column1,
+age = c.column2,
+score_value = c.column3
+from (values
+(:uiname0,:uiage1,:uiscore2,:uiid3),
+(:uiname4,:uiage5,:uiscore6,:uiid7),
+(:uiname8,:uiage9,:uiscore10,:uiid11)
+) as c
+where id = c.column4
+returning id, name`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+
+	uq.SQLSyntax = "mysql"
+	if _, err := uq.SQL(updateBaseSQL); err == nil {
+		t.Fatal("expected error for returning in mysql")
+	}
+}
+
+func TestCompileInsertReturning(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(insertJSON), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iq, err := q.CompileInsert("postgres", map[string]string{
+		"name":  "",
+		"age":   "",
+		"score": "score_value",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	iq.Returning = []string{"id"}
+
+	qs, err := iq.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qs[0].Returning != "returning id" || !strings.HasSuffix(qs[0].Code, "\nreturning id") {
+		t.Fatal("unexpected returning clause:", qs[0].Code)
+	}
+}
+
+func TestCompileUpdateVersion(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"Update": {
+			"Cols": ["name", "version", "id"],
+			"Values": [["Vanya", 3, 1]]
+		}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fieldmap := map[string]string{"name": "", "version": "", "id": ""}
+	uq, err := q.CompileUpdate("postgres", fieldmap, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uq.VersionCol = "version"
+
+	qs, err := uq.SQL(updateBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQS := `update students set
+name = c.name,
+version = c.version + 1
+from (values
+(:uiname0,:uiversion1,:uiid2)
+) as c(name,version,id)
+where id = c.id and version = c.version`
+	if !EqualSQLStrings(expectedQS, qs[0].Code) {
+		t.Fatal("unexpected sql string result, got:", qs[0].Code, "\nexpected", expectedQS)
+	}
+
+	uq.VersionCol = "updated_at"
+	if _, err := uq.SQL(updateBaseSQL); err == nil {
+		t.Fatal("expected missing field error for version column")
+	}
+}
+
+func TestCompileUpdateRows(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"Update": {
+			"Rows": [
+				{"id": 1, "name": "Vanya"},
+				{"id": 2, "age": 20, "score": 91},
+				{"id": 3, "name": "Petya"},
+				{"id": 4, "score": 50}
+			]
+		}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// оценка ниже 60 не пишется, но остальные поля строки обновляются
+	dropScore := func(field string, value any) (any, error) {
+		if field == "score" {
+			if value.(float64) < 60 {
+				return nil, nil
+			}
+		}
+		return value, nil
+	}
+
+	uq, err := q.CompileUpdate("postgres", map[string]string{
+		"name":  "",
+		"age":   "",
+		"score": "score_value",
+		"id":    "",
+	}, "id", dropScore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(uq.Groups) != 2 || uq.RowCount() != 3 {
+		t.Fatal("expected 2 groups with 3 rows, got", len(uq.Groups), uq.RowCount())
+	}
+
+	qs, err := uq.SQL(updateBaseSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qs) != 2 {
+		t.Fatal("expected 2 sql queries, got", len(qs))
+	}
+
+	expected := []string{
+		`update students set
+name = c.name
+from (values
+(:uiid0,:uiname1),
+(:uiid5,:uiname6)
+) as c(id,name)
+where id = c.id`,
+		`update students set
+age = c.age,
+score_value = c.score_value
+from (values
+(:uiage2,:uiid3,:uiscore4)
+) as c(age,id,score_value)
+where id = c.id`,
+	}
+	for i, e := range expected {
+		if !EqualSQLStrings(e, qs[i].Code) {
+			t.Fatal("unexpected sql string result, got:", qs[i].Code, "\nexpected", e)
+		}
+		if len(qs[i].Params) != strings.Count(e, ":ui") {
+			t.Fatal("unexpected params", qs[i].Params)
+		}
+	}
+
+	// строки только с ID ничего не обновляют, запросов нет
+	err = json.Unmarshal([]byte(`{"Update": {"Rows": [{"id": 1}, {"id": 2}]}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uq, err = q.CompileUpdate("postgres", map[string]string{"name": "", "id": ""}, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qs, err := uq.SQL(updateBaseSQL); err != nil || len(qs) != 0 || uq.RowCount() != 0 {
+		t.Fatal("expected no queries for empty update, got", qs, err)
+	}
+	q = Query{}
+	err = json.Unmarshal([]byte(`{"Update": {"Cols": ["id"], "Values": [[1]]}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uq, err = q.CompileUpdate("sqlite", map[string]string{"name": "", "id": ""}, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qs, err := uq.SQL(updateBaseSQL); err != nil || len(qs) != 0 {
+		t.Fatal("expected no queries for id only update, got", qs, err)
+	}
+
+	q = Query{}
+	err = json.Unmarshal([]byte(`{"Update": {"Rows": [{"name": "Vanya"}]}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.CompileUpdate("postgres", map[string]string{"name": "", "id": ""}, "id")
+	if _, ok := err.(*ErrMissingField); !ok {
+		t.Fatal("expected missing id error, got", err)
+	}
+}
+
+func TestInsertChunks(t *testing.T) {
+	var q Query
+	err := json.Unmarshal([]byte(`{"Insert": {
+		"Cols": ["name", "age"],
+		"Values": [["a", 1], ["b", 2], ["c", 1], ["d", 4], ["e", 5]]
+	}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iq, err := q.CompileInsert("sqlite", map[string]string{"name": "", "age": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := iq.Chunks(2)
+	if len(chunks) != 3 {
+		t.Fatal("expected 3 chunks, got", len(chunks))
+	}
+	rows := 0
+	for _, c := range chunks {
+		rows += len(c.Values)
+		qs, err := c.SQL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// в каждой части только ее параметры, в том числе общие с другими частями
+		if len(qs[0].Params) != strings.Count(qs[0].Code, ":") {
+			t.Fatal("unexpected chunk params", qs[0].Params, "for", qs[0].Code)
+		}
+	}
+	if rows != 5 {
+		t.Fatal("expected 5 rows in chunks, got", rows)
+	}
+	if len(iq.Chunks(0)) != 1 || len(iq.Chunks(5)) != 1 {
+		t.Fatal("expected single chunk")
+	}
+}
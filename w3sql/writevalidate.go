@@ -0,0 +1,215 @@
+package w3sql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// ColumnRule проверки значения колонки при insert и update, нулевые значения полей не проверяются
+type ColumnRule struct {
+	Required  bool //при insert колонка обязательна, значение не может быть null или пустой строкой
+	Min       *float64
+	Max       *float64
+	MinLength int
+	MaxLength int
+	Pattern   *regexp.Regexp
+	Check     func(value any) error
+}
+
+// WriteRules правила по именам полей фронта
+type WriteRules map[string]*ColumnRule
+
+type RowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+}
+
+// ErrRowValidation все ошибки проверки строк insert или update
+type ErrRowValidation struct {
+	ErrorInfo
+	Rows []RowError
+}
+
+func (e *ErrRowValidation) Error() string {
+	r := e.Rows[0]
+	return fmt.Sprintf("w3sql: %d invalid values, row %d column %s: %s", len(e.Rows), r.Row, r.Column, r.Message)
+}
+
+func (e *ErrRowValidation) Kind() string {
+	return "row_validation"
+}
+
+func isEmptyValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && s == ""
+}
+
+func (rule *ColumnRule) check(v any) string {
+	if isEmptyValue(v) {
+		if rule.Required {
+			return "value is required"
+		}
+		return ""
+	}
+
+	if rule.Min != nil || rule.Max != nil {
+		f, err := getFloat(v)
+		if err != nil {
+			return "value is not a number"
+		}
+		if rule.Min != nil && f < *rule.Min {
+			return fmt.Sprintf("value must be >= %v", *rule.Min)
+		}
+		if rule.Max != nil && f > *rule.Max {
+			return fmt.Sprintf("value must be <= %v", *rule.Max)
+		}
+	}
+
+	if rule.MinLength > 0 || rule.MaxLength > 0 || rule.Pattern != nil {
+		s := getString(v)
+		n := utf8.RuneCountInString(s)
+		if rule.MinLength > 0 && n < rule.MinLength {
+			return fmt.Sprintf("length must be >= %d", rule.MinLength)
+		}
+		if rule.MaxLength > 0 && n > rule.MaxLength {
+			return fmt.Sprintf("length must be <= %d", rule.MaxLength)
+		}
+		if rule.Pattern != nil && !rule.Pattern.MatchString(s) {
+			return "value does not match pattern"
+		}
+	}
+
+	if rule.Check != nil {
+		if err := rule.Check(v); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateRows(rules WriteRules, section string, cols []string, values [][]any, requireAll bool) []RowError {
+	var result []RowError
+	if requireAll {
+		present := map[string]bool{}
+		for _, c := range cols {
+			present[c] = true
+		}
+		for _, col := range sortedKeys(rules) {
+			if rules[col].Required && !present[col] {
+				for i := range values {
+					result = append(result, RowError{
+						Row:     i,
+						Column:  col,
+						Message: "value is required",
+						Path:    fmt.Sprintf("%s.Values[%d]", section, i),
+					})
+				}
+			}
+		}
+	}
+	for i, row := range values {
+		for j, v := range row {
+			if j >= len(cols) {
+				break
+			}
+			rule, ok := rules[cols[j]]
+			if !ok {
+				continue
+			}
+			if msg := rule.check(v); msg != "" {
+				result = append(result, RowError{
+					Row:     i,
+					Column:  cols[j],
+					Message: msg,
+					Path:    fmt.Sprintf("%s.Values[%d][%d]", section, i, j),
+				})
+			}
+		}
+	}
+	return result
+}
+
+// ValidateInsert проверяет все строки Insert и возвращает ErrRowValidation со всеми ошибками
+func (q *Query) ValidateInsert(rules WriteRules) error {
+	if q.Insert == nil || len(rules) == 0 {
+		return nil
+	}
+	rows := validateRows(rules, "Insert", q.Insert.Cols, q.Insert.Values, true)
+	if len(rows) > 0 {
+		return &ErrRowValidation{ErrorInfo: ErrorInfo{Path: "Insert"}, Rows: rows}
+	}
+	return nil
+}
+
+// ValidateUpdate проверяет все строки Update, присутствие Required колонок не требуется
+func (q *Query) ValidateUpdate(rules WriteRules) error {
+	if q.Update == nil || len(rules) == 0 {
+		return nil
+	}
+	rows := validateRows(rules, "Update", q.Update.Cols, q.Update.Values, false)
+	for i, row := range q.Update.Rows {
+		for _, col := range sortedKeys(row) {
+			rule, ok := rules[col]
+			if !ok {
+				continue
+			}
+			if msg := rule.check(row[col]); msg != "" {
+				rows = append(rows, RowError{
+					Row:     i,
+					Column:  col,
+					Message: msg,
+					Path:    fmt.Sprintf("Update.Rows[%d].%s", i, col),
+				})
+			}
+		}
+	}
+	if len(rows) > 0 {
+		return &ErrRowValidation{ErrorInfo: ErrorInfo{Path: "Update"}, Rows: rows}
+	}
+	return nil
+}
+
+// ValidateUpdateWhere проверяет значения UpdateWhere, присутствие Required колонок не требуется
+func (q *Query) ValidateUpdateWhere(rules WriteRules) error {
+	if q.UpdateWhere == nil || len(rules) == 0 {
+		return nil
+	}
+	var rows []RowError
+	u := q.UpdateWhere
+	for j, v := range u.Values {
+		if j >= len(u.Cols) {
+			break
+		}
+		rule, ok := rules[u.Cols[j]]
+		if !ok {
+			continue
+		}
+		if msg := rule.check(v); msg != "" {
+			rows = append(rows, RowError{
+				Column:  u.Cols[j],
+				Message: msg,
+				Path:    fmt.Sprintf("UpdateWhere.Values[%d]", j),
+			})
+		}
+	}
+	if len(rows) > 0 {
+		return &ErrRowValidation{ErrorInfo: ErrorInfo{Path: "UpdateWhere"}, Rows: rows}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package w3sql
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestValidateInsert(t *testing.T) {
+	minAge, maxAge := 16.0, 99.0
+	rules := WriteRules{
+		"name":  {Required: true, MaxLength: 5},
+		"age":   {Min: &minAge, Max: &maxAge},
+		"email": {Pattern: regexp.MustCompile(`^[^@]+@[^@]+$`)},
+		"score": {Check: func(v any) error {
+			if v == json.Number("13") {
+				return errors.New("unlucky score")
+			}
+			return nil
+		}},
+	}
+
+	var q Query
+	err := json.Unmarshal([]byte(`{"Insert": {
+		"Cols": ["name", "age", "email", "score"],
+		"Values": [
+			["Vanya", 20, "vanya@mail.ru", 90],
+			["", 15, "petya", 13],
+			["Alexander", 100, null, 50]
+		]
+	}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = q.ValidateInsert(rules)
+	var rv *ErrRowValidation
+	if !errors.As(err, &rv) {
+		t.Fatal("expected row validation error, got", err)
+	}
+
+	expected := []RowError{
+		{Row: 1, Column: "name", Message: "value is required", Path: "Insert.Values[1][0]"},
+		{Row: 1, Column: "age", Message: "value must be >= 16", Path: "Insert.Values[1][1]"},
+		{Row: 1, Column: "email", Message: "value does not match pattern", Path: "Insert.Values[1][2]"},
+		{Row: 1, Column: "score", Message: "unlucky score", Path: "Insert.Values[1][3]"},
+		{Row: 2, Column: "name", Message: "length must be <= 5", Path: "Insert.Values[2][0]"},
+		{Row: 2, Column: "age", Message: "value must be <= 99", Path: "Insert.Values[2][1]"},
+	}
+	if len(rv.Rows) != len(expected) {
+		t.Fatal("unexpected errors:", rv.Rows)
+	}
+	for i, e := range expected {
+		if rv.Rows[i] != e {
+			t.Fatal("unexpected error", rv.Rows[i], "expected", e)
+		}
+	}
+
+	// обязательная колонка не прислана
+	err = json.Unmarshal([]byte(`{"Insert": {"Cols": ["age"], "Values": [[20]]}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.As(q.ValidateInsert(rules), &rv) || rv.Rows[0].Column != "name" {
+		t.Fatal("expected missing required column error")
+	}
+}
+
+func TestValidateUpdateRows(t *testing.T) {
+	rules := WriteRules{"name": {Required: true}}
+
+	var q Query
+	err := json.Unmarshal([]byte(`{"Update": {"Rows": [{"id": 1, "age": 20}, {"id": 2, "name": ""}]}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rv *ErrRowValidation
+	if !errors.As(q.ValidateUpdate(rules), &rv) {
+		t.Fatal("expected row validation error")
+	}
+	if len(rv.Rows) != 1 || rv.Rows[0].Row != 1 || rv.Rows[0].Path != "Update.Rows[1].name" {
+		t.Fatal("unexpected errors:", rv.Rows)
+	}
+}
+
+func TestValidateUpdateWhere(t *testing.T) {
+	max := 100.0
+	rules := WriteRules{"name": {Required: true}, "grade": {Max: &max}}
+
+	var q Query
+	err := json.Unmarshal([]byte(`{
+		"UpdateWhere": {"Cols": ["age", "name", "grade"], "Values": [20, "", 101], "Confirm": true},
+		"Search": {"Col": "age", "Val": 30, "Op": ">", "Type": "int"}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rv *ErrRowValidation
+	if !errors.As(q.ValidateUpdateWhere(rules), &rv) {
+		t.Fatal("expected row validation error")
+	}
+	if len(rv.Rows) != 2 || rv.Rows[0].Path != "UpdateWhere.Values[1]" || rv.Rows[1].Column != "grade" {
+		t.Fatal("unexpected errors:", rv.Rows)
+	}
+
+	q.UpdateWhere.Values = []any{20, "Vanya", 99}
+	if err := q.ValidateUpdateWhere(rules); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
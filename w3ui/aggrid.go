@@ -0,0 +1,377 @@
+package w3ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+// Адаптер серверной модели строк AG Grid (IServerSideGetRowsRequest): фильтры, сортировка,
+// страницы startRow/endRow и группы rowGroupCols/groupKeys с агрегатами valueCols,
+// ответ {rowData, rowCount}. Поля колонок AG Grid - поля фронта из карты полей DataRequester.
+
+type agGridColumn struct {
+	ID      string `json:"id"`
+	Field   string `json:"field"`
+	AggFunc string `json:"aggFunc"`
+}
+
+func (c *agGridColumn) field() string {
+	if c.Field != "" {
+		return c.Field
+	}
+	return c.ID
+}
+
+type agGridSort struct {
+	ColID string `json:"colId"`
+	Sort  string `json:"sort"`
+}
+
+type agGridFilter struct {
+	FilterType   string          `json:"filterType"`
+	Type         string          `json:"type"`
+	Filter       any             `json:"filter"`
+	FilterTo     any             `json:"filterTo"`
+	DateFrom     *string         `json:"dateFrom"`
+	DateTo       *string         `json:"dateTo"`
+	Values       []any           `json:"values"`
+	Operator     string          `json:"operator"`
+	Conditions   []*agGridFilter `json:"conditions"`
+	Condition1   *agGridFilter   `json:"condition1"` //до AG Grid 29
+	Condition2   *agGridFilter   `json:"condition2"`
+	FilterModels []*agGridFilter `json:"filterModels"` //multi
+}
+
+type agGridRequest struct {
+	StartRow     *int                     `json:"startRow"`
+	EndRow       *int                     `json:"endRow"`
+	RowGroupCols []agGridColumn           `json:"rowGroupCols"`
+	ValueCols    []agGridColumn           `json:"valueCols"`
+	GroupKeys    []any                    `json:"groupKeys"`
+	FilterModel  map[string]*agGridFilter `json:"filterModel"`
+	SortModel    []agGridSort             `json:"sortModel"`
+}
+
+type agGridAnswer struct {
+	RowData  any    `json:"rowData"`
+	RowCount *int64 `json:"rowCount,omitempty"` //нет, если есть еще строки
+}
+
+// операторы фильтров text, number и date
+var agGridOps = map[string]string{
+	"equals":             "==",
+	"notEqual":           "!=",
+	"contains":           "contains",
+	"notContains":        "contains",
+	"startsWith":         "begins",
+	"endsWith":           "ends",
+	"lessThan":           "<",
+	"lessThanOrEqual":    "<=",
+	"greaterThan":        ">",
+	"greaterThanOrEqual": ">=",
+	"inRange":            "between",
+}
+
+func agGridValueType(v any) string {
+	if n, ok := v.(json.Number); ok {
+		if strings.ContainsAny(n.String(), ".eE") {
+			return "number"
+		}
+		return "int"
+	}
+	return "text"
+}
+
+// agGridDate дата фильтра date приходит как "2006-01-02 15:04:05", время всегда нулевое
+func agGridDate(s *string) any {
+	if s == nil {
+		return nil
+	}
+	d, _, _ := strings.Cut(*s, " ")
+	return d
+}
+
+func agGridCondition(col string, f *agGridFilter, path string) (w3sql.RawCondition, error) {
+	logic := strings.ToUpper(f.Operator)
+	parts := f.Conditions
+	if len(parts) == 0 && f.Condition1 != nil {
+		parts = []*agGridFilter{f.Condition1, f.Condition2}
+	}
+	if f.FilterType == "multi" {
+		parts = f.FilterModels
+		logic = "AND"
+	}
+	if len(parts) > 0 || f.FilterType == "multi" {
+		if logic != "OR" {
+			logic = "AND"
+		}
+		c := &w3sql.CompoundCondition{Op: logic}
+		for _, p := range parts {
+			if p == nil {
+				continue
+			}
+			pc, err := agGridCondition(col, p, path)
+			if err != nil {
+				return nil, err
+			}
+			c.Query = append(c.Query, pc)
+		}
+		switch len(c.Query) {
+		case 0:
+			return nil, nil
+		case 1:
+			return c.Query[0], nil
+		}
+		return c, nil
+	}
+
+	c := &w3sql.AtomaryCondition{Col: col}
+	switch f.FilterType {
+	case "set":
+		// пустой values - ничего не выбрано, пустой in компилируется в ложное условие
+		c.Op = "in"
+		c.Val = f.Values
+		c.Type = "text"
+		if len(f.Values) > 0 {
+			c.Type = agGridValueType(f.Values[0])
+		}
+		return c, nil
+	case "text":
+		c.Type = "text"
+		c.Val = f.Filter
+	case "number":
+		c.Type = agGridValueType(f.Filter)
+		c.Val = f.Filter
+		if f.Type == "inRange" {
+			c.Val = []any{f.Filter, f.FilterTo}
+		}
+	case "date":
+		c.Type = "date"
+		c.Val = agGridDate(f.DateFrom)
+		if f.Type == "inRange" {
+			c.Val = []any{agGridDate(f.DateFrom), agGridDate(f.DateTo)}
+		}
+	default:
+		return nil, &w3sql.ErrUnsupportedType{ErrorInfo: w3sql.ErrorInfo{Field: col, Path: path}, Type: f.FilterType}
+	}
+
+	op, ok := agGridOps[f.Type]
+	if !ok {
+		return nil, &w3sql.ErrUnsupportedOperator{ErrorInfo: w3sql.ErrorInfo{Field: col, Op: f.Type, Path: path}}
+	}
+	c.Op = op
+	if f.Type == "notContains" {
+		return w3sql.Not(c), nil
+	}
+	return c, nil
+}
+
+// query запрос строк уровня len(GroupKeys): условия фильтров и ключей открытых групп
+func (r *agGridRequest) query() (*Query, error) {
+	var conds []w3sql.RawCondition
+
+	cols := make([]string, 0, len(r.FilterModel))
+	for col := range r.FilterModel {
+		cols = append(cols, col)
+	}
+	// порядок условий не зависит от порядка ключей в map
+	sort.Strings(cols)
+	for _, col := range cols {
+		f := r.FilterModel[col]
+		if f == nil {
+			continue
+		}
+		c, err := agGridCondition(col, f, "filterModel."+col)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			conds = append(conds, c)
+		}
+	}
+
+	if len(r.GroupKeys) > len(r.RowGroupCols) {
+		return nil, errors.New("w3ui: more group keys than group columns")
+	}
+	for i, key := range r.GroupKeys {
+		conds = append(conds, &w3sql.AtomaryCondition{
+			Col:  r.RowGroupCols[i].field(),
+			Op:   "==",
+			Type: agGridValueType(key),
+			Val:  key,
+		})
+	}
+
+	q := &Query{}
+	switch len(conds) {
+	case 0:
+	case 1:
+		q.Search = conds[0]
+	default:
+		q.Search = &w3sql.CompoundCondition{Op: "AND", Query: conds}
+	}
+
+	for _, s := range r.SortModel {
+		col := s.ColID
+		// колонка группы по умолчанию сортирует текущий уровень групп
+		if strings.HasPrefix(col, "ag-Grid-AutoColumn") {
+			if !r.grouped() {
+				continue
+			}
+			col = r.RowGroupCols[len(r.GroupKeys)].field()
+		}
+		q.Sort = append(q.Sort, w3sql.SortQuery{Col: col, Dir: s.Sort})
+	}
+	return q, nil
+}
+
+// grouped true, если запрошены строки-группы, а не записи
+func (r *agGridRequest) grouped() bool {
+	return len(r.GroupKeys) < len(r.RowGroupCols)
+}
+
+func readAGGridRequest(req any, maxBodySize int64) (*agGridRequest, error) {
+	body, err := readBody(req, maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	r := &agGridRequest{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// строки-группы текущего уровня с агрегатами valueCols
+func (d *DataRequester[T]) agGridGroups(r *agGridRequest, q *Query) ([]map[string]any, error) {
+	aggs := make([]w3sql.Aggregate, len(r.ValueCols))
+	for i, c := range r.ValueCols {
+		aggs[i] = w3sql.Aggregate{Col: c.field(), Func: c.AggFunc}
+	}
+	cols := []string{r.RowGroupCols[len(r.GroupKeys)].field()}
+
+	rows := []map[string]any{}
+	err := d.rows.Group((*w3sql.Query)(q), cols, aggs, func(cols []string, vals []any) error {
+		row := make(map[string]any, len(vals))
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			row[cols[i]] = v
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// GetAGGridHandlerInner отвечает на запрос getRows серверной модели AG Grid, limit ограничивает размер блока;
+// для строк-групп DB должна реализовать w3req.RowsDB, колонки групп и агрегатов - колонки результата AllSQL
+func (d *DataRequester[T]) GetAGGridHandlerInner(w http.ResponseWriter, req any, limit int, appendQuery *Query) {
+	defer d.onPanic()
+
+	out := newResponder(w, req)
+	r, err := readAGGridRequest(req, d.maxBodySize)
+	if errors.Is(err, ErrBodyTooLarge) {
+		d.logger.LogError(BODY_TOO_LARGE, err, out.errout)
+		return
+	} else if err != nil {
+		d.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	q, err := r.query()
+	if _, _, ok := QueryErrorDetails(err); ok {
+		d.logger.logHandleError(err, out)
+		return
+	} else if err != nil {
+		d.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+	appendToQuery(q, appendQuery)
+	q, ok := d.applyView(req, q, out)
+	if !ok {
+		return
+	}
+
+	start, size := 0, limit
+	if r.StartRow != nil && *r.StartRow > 0 {
+		start = *r.StartRow
+	}
+	if r.EndRow != nil && *r.EndRow-start < size {
+		size = *r.EndRow - start
+	}
+	if size <= 0 {
+		d.logger.LogError(INVALID_PARAMETERS, fmt.Errorf("w3ui: bad row range, start %d, size %d", start, size), out.errout)
+		return
+	}
+	// лишняя строка показывает, есть ли следующий блок
+	fetch := size + 1
+	q.Offset, q.Limit = &start, &fetch
+
+	var (
+		data any
+		n    int
+	)
+	if r.grouped() {
+		rows, err := d.agGridGroups(r, q)
+		if err != nil {
+			d.logger.logHandleError(err, out)
+			return
+		}
+		n = len(rows)
+		if n > size {
+			rows = rows[:size]
+		}
+		data = rows
+	} else {
+		records, _, err := d.sel.Handle((*w3sql.Query)(q))
+		if err != nil {
+			d.logger.logHandleError(err, out)
+			return
+		}
+		n = len(records)
+		if n > size {
+			records = records[:size]
+		}
+		if records == nil {
+			records = []T{}
+		}
+		if d.formatFields != nil && len(records) != 0 {
+			d.formatFields(records)
+		}
+		data = records
+	}
+
+	answer := agGridAnswer{RowData: data}
+	if n <= size {
+		count := int64(start + n)
+		answer.RowCount = &count
+	}
+	buf, _ := json.Marshal(&answer)
+	out.successout(buf)
+}
+
+// fasthttp
+func (d *DataRequester[T]) GetFasthttpAGGridHandler(limit int, appendQuery *Query) fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		d.GetAGGridHandlerInner(nil, ctx, limit, appendQuery)
+	})
+}
+
+// net/http
+func (d *DataRequester[T]) GetHttpAGGridHandler(limit int, appendQuery *Query) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.GetAGGridHandlerInner(w, r, limit, appendQuery)
+	})
+}
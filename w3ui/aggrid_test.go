@@ -0,0 +1,99 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type agGridResponse struct {
+	Status   string
+	Message  string
+	RowData  []map[string]any `json:"rowData"`
+	RowCount *int64           `json:"rowCount"`
+}
+
+func agGridCall(t *testing.T, handler http.HandlerFunc, body string) agGridResponse {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	var resp agGridResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	return resp
+}
+
+func agGridNames(resp agGridResponse) string {
+	names := make([]string, len(resp.RowData))
+	for i, r := range resp.RowData {
+		names[i] = r["FirstName"].(string)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestAGGridRows(t *testing.T) {
+	handler := newExportRequester(t, &countingDB{DbMap: openStudents(t)}).GetHttpAGGridHandler(100, nil)
+
+	resp := agGridCall(t, handler, `{"startRow": 0, "endRow": 2, "sortModel": [{"colId": "age", "sort": "asc"}]}`)
+	if agGridNames(resp) != "masha,lena" || resp.RowCount != nil {
+		t.Fatal("first block without row count expected:", GetJSON(resp))
+	}
+	resp = agGridCall(t, handler, `{"startRow": 2, "endRow": 4, "sortModel": [{"colId": "age", "sort": "asc"}]}`)
+	if agGridNames(resp) != "petya,vanya" || resp.RowCount == nil || *resp.RowCount != 4 {
+		t.Fatal("last block with row count expected:", GetJSON(resp))
+	}
+
+	resp = agGridCall(t, handler, `{"startRow": 0, "endRow": 100, "filterModel": {
+		"age": {"filterType": "number", "operator": "OR", "conditions": [
+			{"filterType": "number", "type": "inRange", "filter": 19, "filterTo": 20},
+			{"filterType": "number", "type": "greaterThan", "filter": 21}
+		]},
+		"firstName": {"filterType": "text", "type": "notContains", "filter": "ash"},
+		"id": {"filterType": "set", "values": [1, 2, 3, 4]}
+	}, "sortModel": [{"colId": "grade", "sort": "desc"}]}`)
+	if agGridNames(resp) != "vanya,lena" || resp.RowCount == nil || *resp.RowCount != 2 {
+		t.Fatal("unexpected filtered rows:", GetJSON(resp))
+	}
+
+	// в фильтре set ничего не выбрано - строк нет
+	resp = agGridCall(t, handler, `{"startRow": 0, "endRow": 100, "filterModel": {"id": {"filterType": "set", "values": []}}}`)
+	if resp.Status == "error" || len(resp.RowData) != 0 || resp.RowCount == nil || *resp.RowCount != 0 {
+		t.Fatal("empty set filter should match nothing:", GetJSON(resp))
+	}
+
+	resp = agGridCall(t, handler, `{"startRow": 0, "endRow": 100, "filterModel": {"age": {"filterType": "number", "type": "blank"}}}`)
+	if resp.Status != "error" || !strings.HasPrefix(resp.Message, UNSUPPORTED_OPERATOR) {
+		t.Fatal("unsupported operator expected:", GetJSON(resp))
+	}
+}
+
+func TestAGGridGroups(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+	if _, err := db.Exec("insert into students (firstName, secondName, age, score) values ('olga', 'orlova', 22, 50)"); err != nil {
+		t.Fatal(err)
+	}
+	handler := newExportRequester(t, db).GetHttpAGGridHandler(100, nil)
+
+	groups := `"rowGroupCols": [{"id": "age", "field": "age"}], "valueCols": [{"id": "grade", "field": "grade", "aggFunc": "sum"}]`
+	resp := agGridCall(t, handler, `{"startRow": 0, "endRow": 3, `+groups+`,
+		"groupKeys": [], "sortModel": [{"colId": "ag-Grid-AutoColumn", "sort": "desc"}]}`)
+	expected := `[{"age":22,"grade":149},{"age":21,"grade":88},{"age":20,"grade":77}]`
+	if b, _ := json.Marshal(resp.RowData); string(b) != expected || resp.RowCount != nil {
+		t.Fatal("unexpected groups:", GetJSON(resp))
+	}
+
+	// без sortModel страница групп упорядочена по полю группировки
+	resp = agGridCall(t, handler, `{"startRow": 1, "endRow": 3, `+groups+`, "groupKeys": []}`)
+	expected = `[{"age":20,"grade":77},{"age":21,"grade":88}]`
+	if b, _ := json.Marshal(resp.RowData); string(b) != expected {
+		t.Fatal("unexpected unsorted groups:", GetJSON(resp))
+	}
+
+	resp = agGridCall(t, handler, `{"startRow": 0, "endRow": 100, `+groups+`,
+		"groupKeys": [22], "sortModel": [{"colId": "firstName", "sort": "asc"}]}`)
+	if agGridNames(resp) != "olga,vanya" || *resp.RowCount != 2 {
+		t.Fatal("unexpected group rows:", GetJSON(resp))
+	}
+}
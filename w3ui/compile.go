@@ -88,8 +88,14 @@ func (q *Query) CompileUpsert(
 			return nil, err
 		}
 
-		result.UpdateQueries[idFieldName] = usql[0].Code
-		result.UpdateValues = usql[0].Params
+		// в SqlUpsertQuery помещается один запрос обновления, группы Update.Rows не выразить
+		if len(usql) > 1 {
+			return nil, errors.New("w3ui: CompileUpsert does not support Update.Rows with different columns, use w3req.UpdateRequester")
+		}
+		if len(usql) > 0 {
+			result.UpdateQueries[idFieldName] = usql[0].Code
+			result.UpdateValues = usql[0].Params
+		}
 	}
 
 	fi := func(field string, value any) (any, error) {
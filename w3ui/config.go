@@ -19,8 +19,25 @@ var globalConfig = GlobalConfig{
 	SQLSyntax: SyntaxSQLite,
 	GetLogger: nil,
 	ErrorCodes: ErrorCodes{
-		SYSTEM_ERROR:       1,
-		INVALID_PARAMETERS: 2,
+		SYSTEM_ERROR:         1,
+		INVALID_PARAMETERS:   2,
+		UNKNOWN_FIELD:        3,
+		MISSING_FIELD:        4,
+		UNSUPPORTED_OPERATOR: 5,
+		UNSUPPORTED_TYPE:     6,
+		BAD_SORT_DIRECTION:   7,
+		BAD_VALUE:            8,
+		MAX_DEPTH:            9,
+		MAX_CONDITIONS:       10,
+		MAX_LIST_LENGTH:      11,
+		MAX_SORT:             12,
+		MAX_LIMIT:            13,
+		BODY_TOO_LARGE:       14,
+		MAX_ROWS:             15,
+		UPDATE_CONFLICT:      16,
+		FORBIDDEN_FIELD:      17,
+		ROW_VALIDATION:       18,
+		SYNTAX_ERROR:         19,
 	},
 }
 
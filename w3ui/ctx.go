@@ -1,8 +1,11 @@
 package w3ui
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"runtime"
@@ -18,45 +21,119 @@ var urlReplacer = strings.NewReplacer("/", "_", ".", "")
 
 type TWebAnswer struct {
 	Status  string
-	ErrCode int         `json:",omitempty"` //код ошибки, если есть, описан в strings, =0 если все ОК
-	Message string      `json:",omitempty"`
-	Record  interface{} `json:",omitempty"`
+	ErrCode int           `json:",omitempty"` //код ошибки, если есть, описан в strings, =0 если все ОК
+	Message string        `json:",omitempty"`
+	Record  interface{}   `json:",omitempty"`
+	Details *ErrorDetails `json:",omitempty"` //условие запроса, вызвавшее ошибку
 }
 
 type W2UIError struct {
-	Status  string `json:"status"`
-	ErrCode int    `json:"errcode,omitempty"`
-	Message string `json:"message"`
+	Status  string        `json:"status"`
+	ErrCode int           `json:"errcode,omitempty"`
+	Message string        `json:"message"`
+	Details *ErrorDetails `json:"details,omitempty"`
 }
 
-func ReadCtxQuery(req any) (*Query, error) {
-	var rq w3sql.Query
-	var decoder *json.Decoder
+var ErrBodyTooLarge = errors.New("w3ui: request body is too large")
+
+func bodyReader(req any) io.Reader {
+	switch t := req.(type) {
+	case *http.Request:
+		return t.Body
+	case *fasthttp.RequestCtx:
+		return t.RequestBodyStream()
+	}
+	return nil
+}
+
+// читает тело запроса целиком, maxBodySize больше 0 ограничивает его размер
+func readBody(req any, maxBodySize int64) ([]byte, error) {
+	body := bodyReader(req)
+	if body == nil {
+		return nil, errors.New("w3ui: no request body")
+	}
+	if maxBodySize <= 0 {
+		return io.ReadAll(body)
+	}
+	b, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return b, nil
+}
 
+// строка параметров URL запроса GET
+func getQueryString(req any) (string, bool) {
 	switch t := req.(type) {
 	case *http.Request:
-		decoder = json.NewDecoder(t.Body)
+		return t.URL.RawQuery, t.Method == http.MethodGet
 	case *fasthttp.RequestCtx:
-		decoder = json.NewDecoder(t.RequestBodyStream())
+		return string(t.QueryArgs().QueryString()), t.IsGet()
+	}
+	return "", false
+}
+
+// запрос GET читается из строки URL (см. w3sql.ParseURLQuery), остальные - из JSON в теле;
+// maxBodySize, если указан и больше 0, ограничивает размер тела запроса в байтах
+func ReadCtxQuery(req any, maxBodySize ...int64) (*Query, error) {
+	var size int64
+	if len(maxBodySize) > 0 {
+		size = maxBodySize[0]
+	}
+	return readCtxQuery(req, size, nil)
+}
+
+// types - объявленные типы полей для условий из строки URL
+func readCtxQuery(req any, maxBodySize int64, types map[string]string) (*Query, error) {
+	if raw, ok := getQueryString(req); ok {
+		q, err := w3sql.ParseURLQuery(raw, types)
+		return (*Query)(q), err
 	}
 
-	err := decoder.Decode(&rq)
+	var rq w3sql.Query
+	body := bodyReader(req)
+
+	if maxBodySize > 0 {
+		b, err := readBody(req, maxBodySize)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	err := json.NewDecoder(body).Decode(&rq)
 	return (*Query)(&rq), err
 }
 
+func (codes ErrorCodes) code(text string) int {
+	if code, ok := codes[text]; ok {
+		return code
+	}
+	// при OutputOriginalErrorText текст имеет вид "<ключ>: <исходная ошибка>"
+	if i := strings.Index(text, ": "); i > 0 {
+		if code, ok := codes[text[:i]]; ok {
+			return code
+		}
+	}
+	return 1
+}
+
 func (codes ErrorCodes) Error(w http.ResponseWriter, msg string) string {
+	return codes.ErrorWithDetails(w, msg, nil)
+}
+
+func (codes ErrorCodes) ErrorWithDetails(w http.ResponseWriter, msg string, details *ErrorDetails) string {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
-	code, ok := codes[msg]
-	if !ok {
-		code = 1
-	}
-
 	ret := W2UIError{
 		Status:  "error",
-		ErrCode: code,
+		ErrCode: codes.code(msg),
 		Message: msg,
+		Details: details,
 	}
 
 	encoder := json.NewEncoder(w)
@@ -101,16 +178,12 @@ func GetRealIP(ctx *fasthttp.RequestCtx) string {
 	return ctx.RemoteIP().To4().String()
 }
 
-func (codes ErrorCodes) ctxRetErrorCommon(ctx *fasthttp.RequestCtx, text string) string {
-	code, ok := codes[text]
-	if !ok {
-		code = 1
-	}
-
+func (codes ErrorCodes) ctxRetErrorCommon(ctx *fasthttp.RequestCtx, text string, details *ErrorDetails) string {
 	buf, _ := json.MarshalIndent(&TWebAnswer{
 		Status:  "error",
-		ErrCode: code,
+		ErrCode: codes.code(text),
 		Message: text,
+		Details: details,
 	}, "", " ")
 	ctx.Success("application/json", buf)
 	return string(buf) //+ string(ctx.PostBody())
@@ -121,8 +194,16 @@ func SetLogGetter(f func(requestPath string, logPurpose LogPurpose) ExtLogger) {
 }
 
 func (codes ErrorCodes) CtxRetError(ctx *fasthttp.RequestCtx, text string) string {
+	return codes.ctxRetError(ctx, text, nil)
+}
+
+func (codes ErrorCodes) CtxRetErrorWithDetails(ctx *fasthttp.RequestCtx, text string, details *ErrorDetails) string {
+	return codes.ctxRetError(ctx, text, details)
+}
+
+func (codes ErrorCodes) ctxRetError(ctx *fasthttp.RequestCtx, text string, details *ErrorDetails) string {
 	if runtime.GOOS == "windows" {
-		_, f, l, _ := runtime.Caller(1)
+		_, f, l, _ := runtime.Caller(2)
 		pos := fmt.Sprintf("%s:%d: ", f, l)
 		os.Stdout.WriteString("\n-->> " + pos + text + "\n\n")
 	}
@@ -153,7 +234,7 @@ func (codes ErrorCodes) CtxRetError(ctx *fasthttp.RequestCtx, text string) strin
 			"[%s]=>[%s] => %s\n-----\n%s\n-----",
 			ipv4,
 			ctx.Request.RequestURI(),
-			codes.ctxRetErrorCommon(ctx, text),
+			codes.ctxRetErrorCommon(ctx, text, details),
 			st,
 		)
 	} else {
@@ -161,7 +242,7 @@ func (codes ErrorCodes) CtxRetError(ctx *fasthttp.RequestCtx, text string) strin
 			"[%s]=>[%s] => %s\n",
 			ipv4,
 			ctx.Request.RequestURI(),
-			codes.ctxRetErrorCommon(ctx, text),
+			codes.ctxRetErrorCommon(ctx, text, details),
 		)
 	}
 	logged := ret
@@ -1,7 +1,14 @@
 package w3ui
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
 )
 
 func TestCompileDelete(t *testing.T) {
@@ -41,7 +48,7 @@ func TestCompileDelete(t *testing.T) {
 	if !ok {
 		t.Fatal("bad map values")
 	}
-	if v.(float64) != 4 {
+	if v.(int64) != 4 {
 		t.Fatalf("incorrect map value: %v", v)
 	}
 
@@ -75,14 +82,14 @@ func TestCompileDelete(t *testing.T) {
 	if !ok {
 		t.Fatal("bad map values 2")
 	}
-	if v.(float64) != 4 {
+	if v.(int64) != 4 {
 		t.Fatalf("incorrect map value 2: %v", v)
 	}
 	v, ok = sqs[0].Map["ui1"]
 	if !ok {
 		t.Fatal("bad map values 3")
 	}
-	if v.(float64) != 6 {
+	if v.(int64) != 6 {
 		t.Fatalf("incorrect map value 3: %v", v)
 	}
 
@@ -118,3 +125,122 @@ func TestCompileDelete(t *testing.T) {
 		t.Fatal("both sql texts are equal")
 	}
 }
+
+// racyTx добавляет строку сразу после подсчета, как параллельная вставка
+type racyTx struct {
+	namedTx
+	racy bool
+}
+
+func (tx *racyTx) SelectInt(query string, args ...any) (int64, error) {
+	n, err := tx.namedTx.SelectInt(query, args...)
+	if err == nil && tx.racy {
+		_, err = tx.Exec("insert into students (firstName, secondName, age, score) values ('olga', 'orlova', 30, 50)")
+	}
+	return n, err
+}
+
+func TestSoftDelete(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+	if _, err := db.Exec("alter table students add column deleted_at text"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleter, err := w3req.NewDeleteRequester(&w3req.DeleteConfig{
+		Tables:        []*w3sql.DeletePair{{TableName: "students", IDName: "studentID"}},
+		SQLDialect:    "sqlite",
+		OnPanic:       func() {},
+		SoftDeleteCol: "deleted_at",
+		FieldMap:      compileMap,
+		MaxRows:       1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var racy bool
+	deleter.InitOnce(func() *w3req.DeleteOptions {
+		return &w3req.DeleteOptions{
+			DB: func() w3req.DB { return db },
+			Begin: func() (w3req.Tx, error) {
+				tx, err := db.DbMap.Begin()
+				return &racyTx{namedTx: namedTx{tx}, racy: racy}, err
+			},
+		}
+	})
+
+	requester := NewDataRequester3[Student](
+		w3sql.NewSQLString("select studentID, firstName, secondName, age, score from students"),
+		compileMap, toLowerCols, func() {},
+	)
+	requester.InitOnce(func() RequesterOptions[Student] {
+		requester.SetSoftDeleteCol("deleted_at")
+		return RequesterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+		}
+	})
+	handler := requester.GetHttpRequestHandler(100, &Query{})
+	total := func() float64 {
+		req := httptest.NewRequest(
+			http.MethodPost, "/",
+			strings.NewReader(`{"Search": {"Col": "age", "Val": 0, "Op": ">", "Type": "int"}}`),
+		)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return resp["total"].(float64)
+	}
+
+	q, _ := ReadJSON(`{"Delete": [1]}`)
+	if err := deleter.Handle((*w3sql.Query)(q)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 4 {
+		t.Fatal("soft delete should keep rows, got", n)
+	}
+	if n := total(); n != 3 {
+		t.Fatal("soft deleted row should be hidden, got total", n)
+	}
+
+	// повторное удаление не перезаписывает время удаления
+	if _, err := db.Exec("update students set deleted_at = '2000-01-01' where studentID = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleter.Handle((*w3sql.Query)(q)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.SelectInt("select count(*) from students where deleted_at = '2000-01-01'"); n != 1 {
+		t.Fatal("deleted row should not be stamped again")
+	}
+
+	q, _ = ReadJSON(`{"DeleteWhere": {"Confirm": true}, "Search": {"Col": "age", "Val": 19, "Op": ">", "Type": "int"}}`)
+	_, err = deleter.HandleWhere((*w3sql.Query)(q))
+	if text, _, ok := QueryErrorDetails(err); !ok || text != MAX_ROWS {
+		t.Fatal("expected max rows error, got", err)
+	}
+
+	// строка, добавленная между подсчетом и удалением, откатывает удаление
+	q, _ = ReadJSON(`{"DeleteWhere": {"Confirm": true}, "Search": {"Col": "age", "Val": 20, "Op": ">", "Type": "int"}}`)
+	racy = true
+	_, err = deleter.HandleWhere((*w3sql.Query)(q))
+	racy = false
+	if text, _, ok := QueryErrorDetails(err); !ok || text != MAX_ROWS {
+		t.Fatal("expected max rows error after delete, got", err)
+	}
+	if n := total(); n != 3 {
+		t.Fatal("delete should be rolled back, got total", n)
+	}
+
+	n, err := deleter.HandleWhere((*w3sql.Query)(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("expected 1 deleted row, got", n)
+	}
+	if n := total(); n != 2 {
+		t.Fatal("expected total 2 after delete by condition, got", n)
+	}
+}
@@ -0,0 +1,71 @@
+package w3ui
+
+import (
+	"errors"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+)
+
+// ErrorDetails отдается фронту вместе с ошибкой, чтобы подсветить неверное условие фильтра
+type ErrorDetails struct {
+	Kind  string `json:"kind"`
+	Field string `json:"field,omitempty"`
+	Op    string `json:"op,omitempty"`
+	Path  string `json:"path,omitempty"`
+	Pos   *int   `json:"pos,omitempty"` //позиция ошибки в текстовом фильтре
+
+	Rows []w3sql.RowError `json:"rows,omitempty"` //ошибки по ячейкам при проверке insert и update
+}
+
+var queryErrorTexts = map[string]string{
+	"unknown_field":        UNKNOWN_FIELD,
+	"missing_field":        MISSING_FIELD,
+	"unsupported_operator": UNSUPPORTED_OPERATOR,
+	"unsupported_type":     UNSUPPORTED_TYPE,
+	"bad_sort_direction":   BAD_SORT_DIRECTION,
+	"bad_value":            BAD_VALUE,
+	"forbidden_field":      FORBIDDEN_FIELD,
+	"row_validation":       ROW_VALIDATION,
+	"syntax_error":         SYNTAX_ERROR,
+
+	w3sql.LimitMaxDepth:      MAX_DEPTH,
+	w3sql.LimitMaxConditions: MAX_CONDITIONS,
+	w3sql.LimitMaxListLength: MAX_LIST_LENGTH,
+	w3sql.LimitMaxSort:       MAX_SORT,
+	w3sql.LimitMaxLimit:      MAX_LIMIT,
+	w3sql.LimitMaxRows:       MAX_ROWS,
+
+	w3req.KindUpdateConflict: UPDATE_CONFLICT,
+}
+
+// QueryErrorDetails возвращает текст ошибки для ErrorCodes и подробности для фронта,
+// если err - ошибка компиляции запроса w3sql
+func QueryErrorDetails(err error) (string, *ErrorDetails, bool) {
+	var qe w3sql.QueryError
+	if !errors.As(err, &qe) {
+		return "", nil, false
+	}
+
+	text, ok := queryErrorTexts[qe.Kind()]
+	if !ok {
+		text = INVALID_PARAMETERS
+	}
+
+	info := qe.Info()
+	details := &ErrorDetails{
+		Kind:  qe.Kind(),
+		Field: info.Field,
+		Op:    info.Op,
+		Path:  info.Path,
+	}
+	var rv *w3sql.ErrRowValidation
+	if errors.As(err, &rv) {
+		details.Rows = rv.Rows
+	}
+	var se *w3sql.ErrSyntax
+	if errors.As(err, &se) {
+		details.Pos = &se.Pos
+	}
+	return text, details, true
+}
@@ -0,0 +1,363 @@
+package w3ui
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+// форматы выгрузки, выбираются параметром format строки запроса, по умолчанию csv
+const (
+	EXPORT_CSV    = "csv"
+	EXPORT_NDJSON = "ndjson"
+	EXPORT_XLSX   = "xlsx"
+)
+
+var exportContentTypes = map[string]string{
+	EXPORT_CSV:    "text/csv; charset=utf-8",
+	EXPORT_NDJSON: "application/x-ndjson",
+	EXPORT_XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type exportWriter interface {
+	header(cols []string) error
+	row(vals []any) error
+	close() error
+}
+
+func newExportWriter(format string, w io.Writer) exportWriter {
+	switch format {
+	case EXPORT_NDJSON:
+		return &ndjsonExport{enc: json.NewEncoder(w)}
+	case EXPORT_XLSX:
+		return &xlsxExport{z: zip.NewWriter(w)}
+	}
+	return &csvExport{w: csv.NewWriter(w)}
+}
+
+// значение колонки как текст: []byte от драйвера - строка, даты без часового пояса, если он UTC
+func exportText(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(t)
+	case string:
+		return t
+	case time.Time:
+		if t.Location() == time.UTC {
+			return t.Format("2006-01-02 15:04:05")
+		}
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+type csvExport struct {
+	w     *csv.Writer
+	cells []string
+}
+
+func (e *csvExport) header(cols []string) error {
+	e.cells = make([]string, len(cols))
+	return e.w.Write(cols)
+}
+
+// csvFormulaChars первые символы, с которых табличный редактор начинает формулу
+const csvFormulaChars = "=+-@\t\r"
+
+func (e *csvExport) row(vals []any) error {
+	for i, v := range vals {
+		e.cells[i] = exportText(v)
+		// текст, похожий на формулу, экранируется апострофом, числа остаются как есть
+		switch v.(type) {
+		case string, []byte:
+			if c := e.cells[i]; c != "" && strings.ContainsRune(csvFormulaChars, rune(c[0])) {
+				e.cells[i] = "'" + c
+			}
+		}
+	}
+	return e.w.Write(e.cells)
+}
+
+func (e *csvExport) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExport struct {
+	enc  *json.Encoder
+	cols []string
+}
+
+func (e *ndjsonExport) header(cols []string) error {
+	e.cols = cols
+	return nil
+}
+
+func (e *ndjsonExport) row(vals []any) error {
+	rec := make(map[string]any, len(vals))
+	for i, v := range vals {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		rec[e.cols[i]] = v
+	}
+	return e.enc.Encode(rec)
+}
+
+func (e *ndjsonExport) close() error {
+	return nil
+}
+
+// xlsxExport пишет лист построчно прямо в zip, строки - inline, без общей таблицы строк
+type xlsxExport struct {
+	z     *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+const xlsxSheetHead = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+var xlsxStaticFiles = [][2]string{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+func (e *xlsxExport) header(cols []string) error {
+	w, err := e.z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = bufio.NewWriter(w)
+	e.sheet.WriteString(xlsxSheetHead)
+
+	vals := make([]any, len(cols))
+	for i, c := range cols {
+		vals[i] = c
+	}
+	return e.row(vals)
+}
+
+func (e *xlsxExport) row(vals []any) error {
+	e.rows++
+	fmt.Fprintf(e.sheet, `<row r="%d">`, e.rows)
+	for _, v := range vals {
+		switch t := v.(type) {
+		case nil:
+			e.sheet.WriteString(`<c/>`)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			// Excel хранит числа как double, целые больше 2^53 пишутся текстом без потери точности
+			s := fmt.Sprint(t)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= -1<<53 && n <= 1<<53 {
+				e.sheet.WriteString(`<c><v>` + s + `</v></c>`)
+			} else {
+				e.sheet.WriteString(`<c t="inlineStr"><is><t>` + s + `</t></is></c>`)
+			}
+		case float32:
+			e.sheet.WriteString(`<c><v>` + strconv.FormatFloat(float64(t), 'g', -1, 32) + `</v></c>`)
+		case float64:
+			e.sheet.WriteString(`<c><v>` + strconv.FormatFloat(t, 'g', -1, 64) + `</v></c>`)
+		default:
+			e.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(e.sheet, []byte(exportText(v))); err != nil {
+				return err
+			}
+			e.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := e.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (e *xlsxExport) close() error {
+	// пустой лист тоже нужен
+	if e.sheet == nil {
+		w, err := e.z.Create("xl/worksheets/sheet1.xml")
+		if err != nil {
+			return err
+		}
+		e.sheet = bufio.NewWriter(w)
+		e.sheet.WriteString(xlsxSheetHead)
+	}
+	e.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	for _, f := range xlsxStaticFiles {
+		w, err := e.z.Create(f[0])
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, f[1]); err != nil {
+			return err
+		}
+	}
+	return e.z.Close()
+}
+
+// заголовки колонок - поля фронта из карты полей, иначе имена колонок результата, то есть db теги T;
+// если колонке соответствует несколько полей, берется поле с тем же именем, иначе первое по алфавиту
+func (d *DataRequester[T]) exportHeader(cols []string) []string {
+	fields := make([]string, 0, len(d.fieldMap))
+	for field := range d.fieldMap {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	names := map[string]string{}
+	for _, field := range fields {
+		col := d.fieldMap[field]
+		if col == "" {
+			col = field
+		}
+		if _, ok := names[col]; !ok || field == col {
+			names[col] = field
+		}
+	}
+	result := make([]string, len(cols))
+	for i, c := range cols {
+		if f, ok := names[c]; ok {
+			result[i] = f
+		} else {
+			result[i] = c
+		}
+	}
+	return result
+}
+
+// выгрузка результата запроса целиком, limit и offset запроса не учитываются,
+// maxRows ограничивает число строк, 0 - без ограничения; DB должна реализовать w3req.RowsDB
+func (d *DataRequester[T]) GetExportHandlerInner(
+	w http.ResponseWriter,
+	req any,
+	maxRows int,
+	appendQuery *Query,
+) {
+	defer d.onPanic()
+
+	out := newResponder(w, req)
+	q, ok := d.readQuery(req, appendQuery, out)
+	if !ok {
+		return
+	}
+
+	var format string
+	switch t := req.(type) {
+	case *http.Request:
+		format = t.URL.Query().Get("format")
+	case *fasthttp.RequestCtx:
+		format = string(t.QueryArgs().Peek("format"))
+	}
+	if format == "" {
+		format = EXPORT_CSV
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		d.logger.LogError(INVALID_PARAMETERS, fmt.Errorf("w3ui: unknown export format '%s'", format), out.errout)
+		return
+	}
+
+	disposition := fmt.Sprintf(`attachment; filename="export.%s"`, format)
+	switch t := req.(type) {
+	case *http.Request:
+		// заголовки ответа пишутся с первой строкой, до нее еще можно ответить ошибкой
+		err := d.export(q, format, maxRows, w, func() {
+			w.Header().Set("Content-Type", exportContentTypes[format])
+			w.Header().Set("Content-Disposition", disposition)
+			w.WriteHeader(200)
+		})
+		if errors.Is(err, errExportStarted) {
+			d.logExportError(err)
+		} else if err != nil {
+			d.logger.logHandleError(err, out)
+		}
+
+	case *fasthttp.RequestCtx:
+		// тело пишется после возврата из обработчика, поэтому ошибки запроса проверяются заранее
+		if errs := d.sel.Validate((*w3sql.Query)(q)); len(errs) > 0 {
+			d.logger.logHandleError(errs[0], out)
+			return
+		}
+		t.SetContentType(exportContentTypes[format])
+		t.Response.Header.Set("Content-Disposition", disposition)
+		t.SetBodyStreamWriter(func(bw *bufio.Writer) {
+			defer d.onPanic()
+			if err := d.export(q, format, maxRows, bw, func() {}); err != nil {
+				d.logExportError(err)
+			}
+		})
+	}
+}
+
+var errExportStarted = errors.New("w3ui: export is interrupted")
+
+// begin вызывается перед первой записью в w, ошибки после нее оборачиваются в errExportStarted
+func (d *DataRequester[T]) export(q *Query, format string, maxRows int, w io.Writer, begin func()) error {
+	var ew exportWriter
+	err := d.rows.Export((*w3sql.Query)(q), maxRows, func(cols []string, row []any) error {
+		// первый вызов - колонки без строки, заголовок пишется и для пустого результата
+		if ew == nil {
+			begin()
+			ew = newExportWriter(format, w)
+			return ew.header(d.exportHeader(cols))
+		}
+		return ew.row(row)
+	})
+	if ew == nil {
+		return err
+	}
+	if err == nil {
+		err = ew.close()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errExportStarted, err)
+	}
+	return nil
+}
+
+// ответ уже начат, ошибку можно только записать в журнал
+func (d *DataRequester[T]) logExportError(err error) {
+	if d.logger.errorLog != nil {
+		d.logger.errorLog.Print(SYSTEM_ERROR + ": " + err.Error())
+	}
+}
+
+// fasthttp
+func (d *DataRequester[T]) GetFasthttpExportHandler(maxRows int, appendQuery *Query) fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		d.GetExportHandlerInner(nil, ctx, maxRows, appendQuery)
+	})
+}
+
+// net/http
+func (d *DataRequester[T]) GetHttpExportHandler(maxRows int, appendQuery *Query) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.GetExportHandlerInner(w, r, maxRows, appendQuery)
+	})
+}
@@ -0,0 +1,213 @@
+package w3ui
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+)
+
+func (db *countingDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.DbMap.Db.Query(query, args...)
+}
+
+func newExportRequester(t *testing.T, db w3req.DB) *DataRequester[Student] {
+	r := NewDataRequester3[Student](allSQL, compileMap, toLowerCols, func() {})
+	r.InitOnce(func() RequesterOptions[Student] {
+		return RequesterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+		}
+	})
+	return r.OutputOriginalErrorText()
+}
+
+func exportRequest(handler http.HandlerFunc, format string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/?format="+format, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+const exportQuery = `{
+	"Search": {"Col": "age", "Val": 19, "Op": ">", "Type": "int"},
+	"Sort": [{"Col": "grade", "Dir": "desc"}],
+	"Limit": 1,
+	"Offset": 1
+}`
+
+func TestExportCSV(t *testing.T) {
+	handler := newExportRequester(t, &countingDB{DbMap: openStudents(t)}).GetHttpExportHandler(2, nil)
+
+	w := exportRequest(handler, "", exportQuery)
+	if ct := w.Header().Get("Content-Type"); ct != exportContentTypes[EXPORT_CSV] {
+		t.Fatal("unexpected content type:", ct, w.Body.String())
+	}
+	expected := "id,firstName,secondName,age,grade\n" +
+		"1,vanya,ivanov,22,99\n" +
+		"2,petya,petrov,21,88\n"
+	if w.Body.String() != expected {
+		t.Fatal("unexpected csv:\n" + w.Body.String())
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	handler := newExportRequester(t, &countingDB{DbMap: openStudents(t)}).GetHttpExportHandler(0, nil)
+
+	w := exportRequest(handler, EXPORT_NDJSON, exportQuery)
+	var rows []map[string]any
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var r map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err, sc.Text())
+		}
+		rows = append(rows, r)
+	}
+	if len(rows) != 3 || rows[2]["firstName"] != "lena" || rows[2]["grade"] != float64(77) {
+		t.Fatal("unexpected rows:", rows)
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	handler := newExportRequester(t, &countingDB{DbMap: openStudents(t)}).GetHttpExportHandler(0, nil)
+
+	w := exportRequest(handler, EXPORT_XLSX, `{"Search": {"Col": "grade", "Val": 66, "Op": "==", "Type": "int"}}`)
+	table, lines, err := readXLSX(w.Body.Bytes(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 2 || strings.Join(table[0], ",") != "id,firstName,secondName,age,grade" ||
+		strings.Join(table[1], ",") != "4,masha,marinina,19,66" || lines[1] != 2 {
+		t.Fatal("unexpected sheet:", table)
+	}
+
+	// пустой результат - файл только с заголовком
+	w = exportRequest(handler, EXPORT_XLSX, `{"Search": {"Col": "age", "Val": 100, "Op": ">", "Type": "int"}}`)
+	if table, _, err := readXLSX(w.Body.Bytes(), 0, 0); err != nil || len(table) != 1 || table[0][1] != "firstName" {
+		t.Fatal("expected header only:", table, err)
+	}
+	w = exportRequest(handler, EXPORT_CSV, `{"Search": {"Col": "age", "Val": 100, "Op": ">", "Type": "int"}}`)
+	if w.Body.String() != "id,firstName,secondName,age,grade\n" {
+		t.Fatal("expected csv header only:", w.Body.String())
+	}
+}
+
+func TestExportValues(t *testing.T) {
+	var buf strings.Builder
+	e := newExportWriter(EXPORT_CSV, &buf)
+	e.header([]string{"a", "b", "c", "d"})
+	e.row([]any{"=1+1", []byte("@x"), -5, "a-b"})
+	e.close()
+	if buf.String() != "a,b,c,d\n'=1+1,'@x,-5,a-b\n" {
+		t.Fatal("formulas should be escaped:", buf.String())
+	}
+
+	var xbuf bytes.Buffer
+	e = newExportWriter(EXPORT_XLSX, &xbuf)
+	e.header([]string{"small", "big", "huge"})
+	e.row([]any{int64(1 << 53), int64(1<<53 + 1), uint64(1<<64 - 1)})
+	e.close()
+	data := xbuf.Bytes()
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := z.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, _ := io.ReadAll(f)
+	// большие целые пишутся текстом
+	if !bytes.Contains(sheet, []byte("<c><v>9007199254740992</v></c>")) ||
+		!bytes.Contains(sheet, []byte(`<c t="inlineStr"><is><t>9007199254740993</t></is></c>`)) {
+		t.Fatal("unexpected sheet:", string(sheet))
+	}
+	table, _, err := readXLSX(data, 0, 0)
+	if err != nil || table[1][1] != "9007199254740993" || table[1][2] != "18446744073709551615" {
+		t.Fatal("unexpected big ints:", table, err)
+	}
+}
+
+func TestExportHeader(t *testing.T) {
+	// несколько полей на одну колонку - заголовок не зависит от порядка обхода карты
+	d := &DataRequester[Student]{fieldMap: map[string]string{
+		"score": "", "grade": "score", "points": "score",
+		"name": "first", "alias": "first",
+	}}
+	for i := 0; i < 20; i++ {
+		if h := strings.Join(d.exportHeader([]string{"score", "first", "age"}), ","); h != "score,alias,age" {
+			t.Fatal("unexpected header:", h)
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	db := openStudents(t)
+
+	// gorp.DbMap не умеет построчное чтение
+	w := exportRequest(newExportRequester(t, db).GetHttpExportHandler(0, nil), "", exportQuery)
+	if !strings.Contains(w.Body.String(), "RowsDB") {
+		t.Fatal("expected RowsDB error:", w.Body.String())
+	}
+
+	handler := newExportRequester(t, &countingDB{DbMap: db}).GetHttpExportHandler(0, nil)
+	w = exportRequest(handler, "pdf", exportQuery)
+	if !strings.Contains(w.Body.String(), "unknown export format") {
+		t.Fatal("expected format error:", w.Body.String())
+	}
+
+	w = exportRequest(handler, "", `{"Search": {"Col": "unknown", "Val": 1, "Op": ">", "Type": "int"}}`)
+	var resp W2UIError
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Status != "error" || resp.Details == nil {
+		t.Fatal("expected unknown field error:", w.Body.String())
+	}
+}
+
+func TestIterate(t *testing.T) {
+	r := newExportRequester(t, &countingDB{DbMap: openStudents(t)})
+
+	var wq w3sql.Query
+	if err := json.Unmarshal([]byte(exportQuery), &wq); err != nil {
+		t.Fatal(err)
+	}
+	q := (*Query)(&wq)
+	var names []string
+	err := r.Iterate(context.Background(), q, func(s Student) error {
+		names = append(names, s.FirstName)
+		return nil
+	})
+	if err != nil || strings.Join(names, ",") != "petya" {
+		t.Fatal("unexpected records:", names, err)
+	}
+
+	q.Limit, q.Offset = nil, nil
+	names = nil
+	stop := errors.New("stop")
+	err = r.Iterate(context.Background(), q, func(s Student) error {
+		names = append(names, s.FirstName)
+		if s.Score == 88 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || strings.Join(names, ",") != "vanya,petya" {
+		t.Fatal("iteration should stop on callback error:", names, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Iterate(ctx, q, func(Student) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context error, got", err)
+	}
+}
@@ -0,0 +1,392 @@
+package w3ui
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+type ImportConfig struct {
+	Fields        map[string]string //поле фронта -> тип значения для w3sql.ConvValue (int, number, decimal, date, datetime, text)
+	HeaderAliases map[string]string //заголовок файла -> поле фронта, без учета регистра
+	Comma         rune              //разделитель CSV, по умолчанию ','
+	SkipInvalid   bool              //строки с ошибками пропускаются, иначе при любой ошибке ничего не вставляется
+	MaxRows       int               //наибольшее число строк данных, 0 - без ограничения
+	MaxXLSXSize   int64             //наибольший размер каждого распакованного файла внутри xlsx, 0 - 64 МБ
+	FormField     string            //поле multipart формы с файлом, по умолчанию "file"
+}
+
+// DataImporter вставляет строки CSV или XLSX файла через InsertRequester или UpsertRequester
+type DataImporter struct {
+	cfg         *ImportConfig
+	insert      func(q *w3sql.Query) (int64, error)
+	logger      *Logger
+	onPanic     func()
+	maxBodySize int64
+}
+
+type importReport struct {
+	Status   string           `json:"status"`
+	Total    int              `json:"total"` //строк данных в файле
+	Inserted int64            `json:"inserted"`
+	Errors   []w3sql.RowError `json:"errors,omitempty"`
+}
+
+func newDataImporter(cfg *ImportConfig, insert func(q *w3sql.Query) (int64, error), onPanic func()) *DataImporter {
+	if onPanic == nil {
+		panic("[w3ui.NewDataImporter] ERROR: onPanic should not be nil")
+	}
+	return &DataImporter{
+		cfg:     cfg,
+		insert:  insert,
+		logger:  &Logger{},
+		onPanic: onPanic,
+	}
+}
+
+// ins должен быть уже инициализирован через InitOnce
+func NewInsertImporter(cfg *ImportConfig, ins w3req.InsertRequester, onPanic func()) *DataImporter {
+	return newDataImporter(cfg, ins.HandleBatch, onPanic)
+}
+
+// ups должен быть уже инициализирован через InitOnce
+func NewUpsertImporter(cfg *ImportConfig, ups w3req.UpsertRequester, onPanic func()) *DataImporter {
+	return newDataImporter(cfg, func(q *w3sql.Query) (int64, error) {
+		if err := ups.Handle(q); err != nil {
+			return 0, err
+		}
+		return int64(len(q.Insert.Values)), nil
+	}, onPanic)
+}
+
+func (d *DataImporter) SetErrorLog(log ExtLogger) *DataImporter {
+	d.logger.setErrorLogger(log)
+	return d
+}
+
+// ограничивает размер файла, 0 - без ограничения
+func (d *DataImporter) SetMaxBodySize(size int64) *DataImporter {
+	d.maxBodySize = size
+	return d
+}
+
+// если включен, то вместо "Invalid Parameters" будет возвращать настоящую ошибку
+func (d *DataImporter) OutputOriginalErrorText() *DataImporter {
+	d.logger.outputOriginalError = true
+	return d
+}
+
+// файл берется из поля multipart формы или из тела запроса целиком
+func (d *DataImporter) readFile(req any) ([]byte, error) {
+	field := d.cfg.FormField
+	if field == "" {
+		field = "file"
+	}
+
+	var (
+		body io.Reader
+		fh   *multipart.FileHeader
+	)
+	switch t := req.(type) {
+	case *http.Request:
+		if strings.HasPrefix(t.Header.Get("Content-Type"), "multipart/form-data") {
+			if d.maxBodySize > 0 {
+				t.Body = http.MaxBytesReader(nil, t.Body, d.maxBodySize)
+			}
+			f, _, err := t.FormFile(field)
+			// части формы больше лимита памяти лежат во временных файлах
+			if t.MultipartForm != nil {
+				defer t.MultipartForm.RemoveAll()
+			}
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, ErrBodyTooLarge
+			} else if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			body = f
+		} else {
+			body = t.Body
+		}
+	case *fasthttp.RequestCtx:
+		if f, err := t.FormFile(field); err == nil {
+			defer t.Request.RemoveMultipartFormFiles()
+			fh = f
+		} else {
+			body = bytes.NewReader(t.PostBody())
+		}
+	}
+
+	if fh != nil {
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		body = f
+	}
+	if body == nil {
+		return nil, errors.New("w3ui: no file")
+	}
+
+	if d.maxBodySize > 0 {
+		b, err := io.ReadAll(io.LimitReader(body, d.maxBodySize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(b)) > d.maxBodySize {
+			return nil, ErrBodyTooLarge
+		}
+		return b, nil
+	}
+	return io.ReadAll(body)
+}
+
+// возвращает строки таблицы и их номера в файле, пустые строки CSV пропускаются;
+// при MaxRows читается не больше заголовка и MaxRows+1 строк данных, лишняя строка - признак превышения
+func (d *DataImporter) readTable(data []byte) ([][]string, []int, bool, error) {
+	limit := 0
+	if d.cfg.MaxRows > 0 {
+		limit = d.cfg.MaxRows + 2
+	}
+	// xlsx - это zip архив
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		t, lines, err := readXLSX(data, limit, d.cfg.MaxXLSXSize)
+		return t, lines, true, err
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	if d.cfg.Comma != 0 {
+		r.Comma = d.cfg.Comma
+	}
+	r.FieldsPerRecord = -1
+
+	var (
+		table [][]string
+		lines []int
+	)
+	for limit <= 0 || len(table) < limit {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return table, lines, false, nil
+		} else if err != nil {
+			return nil, nil, false, err
+		}
+		line, _ := r.FieldPos(0)
+		table = append(table, rec)
+		lines = append(lines, line)
+	}
+	return table, lines, false, nil
+}
+
+// строит Insert из таблицы, в ошибках номера строк файла от 1, как в редакторе таблиц
+func (d *DataImporter) buildQuery(table [][]string, fileLines []int, xlsx bool) (*w3sql.Query, []int, []w3sql.RowError) {
+	aliases := map[string]string{}
+	for h, f := range d.cfg.HeaderAliases {
+		aliases[strings.ToLower(h)] = f
+	}
+
+	// пустые ячейки в конце заголовка - след форматирования в редакторе, колонки за ними не читаются
+	header := table[0]
+	for len(header) > 0 && strings.TrimSpace(header[len(header)-1]) == "" {
+		header = header[:len(header)-1]
+	}
+	if len(header) == 0 {
+		return nil, nil, []w3sql.RowError{{Row: fileLines[0], Message: "no columns in header"}}
+	}
+	cols := make([]string, len(header))
+	seen := map[string]bool{}
+	var errs []w3sql.RowError
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			errs = append(errs, w3sql.RowError{Row: fileLines[0], Column: fmt.Sprintf("#%d", i+1), Message: "empty column name"})
+			continue
+		}
+		f, ok := aliases[strings.ToLower(h)]
+		if !ok {
+			f = h
+		}
+		if _, ok := d.cfg.Fields[f]; !ok {
+			errs = append(errs, w3sql.RowError{Row: fileLines[0], Column: h, Message: "unknown column"})
+			continue
+		}
+		// иначе значения последней из одинаковых колонок молча заменили бы остальные
+		if seen[f] {
+			errs = append(errs, w3sql.RowError{Row: fileLines[0], Column: h, Message: "duplicate column"})
+			continue
+		}
+		seen[f] = true
+		cols[i] = f
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	q := &w3sql.Query{}
+	q.Insert = &struct {
+		Cols   []string
+		Values [][]any
+	}{Cols: cols}
+	var lines []int
+
+rows:
+	for n, row := range table[1:] {
+		line := fileLines[n+1]
+		empty := true
+		for _, cell := range row {
+			if strings.TrimSpace(cell) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+
+		vals := make([]any, len(cols))
+		for i, f := range cols {
+			if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+				continue
+			}
+			cell := strings.TrimSpace(row[i])
+			tp := d.cfg.Fields[f]
+			if xlsx && (tp == "date" || tp == "datetime") {
+				if s, ok := excelDate(cell, tp == "datetime"); ok {
+					cell = s
+				}
+			}
+			v, err := w3sql.ConvValue(cell, tp)
+			if err != nil {
+				errs = append(errs, w3sql.RowError{Row: line, Column: f, Message: err.Error()})
+				continue rows
+			}
+			vals[i] = v
+		}
+		q.Insert.Values = append(q.Insert.Values, vals)
+		lines = append(lines, line)
+	}
+	return q, lines, errs
+}
+
+// при SkipInvalid строки, не прошедшие проверку InsertRequester, убираются и вставка повторяется
+func (d *DataImporter) insertRows(q *w3sql.Query, lines []int, report *importReport) error {
+	for {
+		if len(q.Insert.Values) == 0 {
+			return nil
+		}
+		n, err := d.insert(q)
+		if err == nil {
+			report.Inserted = n
+			return nil
+		}
+
+		var rv *w3sql.ErrRowValidation
+		if !errors.As(err, &rv) {
+			return err
+		}
+		bad := map[int]bool{}
+		for _, e := range rv.Rows {
+			if e.Row < len(lines) {
+				e.Row = lines[e.Row]
+			}
+			e.Path = ""
+			report.Errors = append(report.Errors, e)
+			bad[e.Row] = true
+		}
+		if !d.cfg.SkipInvalid {
+			return nil
+		}
+
+		values := q.Insert.Values[:0]
+		kept := lines[:0]
+		for i, v := range q.Insert.Values {
+			if !bad[lines[i]] {
+				values = append(values, v)
+				kept = append(kept, lines[i])
+			}
+		}
+		q.Insert.Values, lines = values, kept
+	}
+}
+
+func (d *DataImporter) GetRequestHandlerInner(w http.ResponseWriter, req any) {
+	defer d.onPanic()
+
+	out := newResponder(w, req)
+	data, err := d.readFile(req)
+	if errors.Is(err, ErrBodyTooLarge) {
+		d.logger.LogError(BODY_TOO_LARGE, err, out.errout)
+		return
+	} else if err != nil {
+		d.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	table, fileLines, xlsx, err := d.readTable(data)
+	if err == nil && len(table) == 0 {
+		err = errors.New("w3ui: no header row")
+	}
+	if err == nil && d.cfg.MaxRows > 0 && len(table)-1 > d.cfg.MaxRows {
+		err = fmt.Errorf("w3ui: more than %d rows", d.cfg.MaxRows)
+	}
+	if err != nil {
+		d.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	report := importReport{Status: "success", Total: len(table) - 1}
+	q, lines, errs := d.buildQuery(table, fileLines, xlsx)
+	report.Errors = errs
+
+	// ошибки заголовка не пропускаются
+	if q == nil {
+		d.logger.logHandleError(&w3sql.ErrRowValidation{
+			ErrorInfo: w3sql.ErrorInfo{Path: "Import"},
+			Rows:      errs,
+		}, out)
+		return
+	}
+
+	if len(errs) == 0 || d.cfg.SkipInvalid {
+		if err := d.insertRows(q, lines, &report); err != nil {
+			d.logger.logHandleError(err, out)
+			return
+		}
+	}
+
+	if len(report.Errors) > 0 && !d.cfg.SkipInvalid {
+		d.logger.logHandleError(&w3sql.ErrRowValidation{
+			ErrorInfo: w3sql.ErrorInfo{Path: "Import"},
+			Rows:      report.Errors,
+		}, out)
+		return
+	}
+
+	buf, _ := json.Marshal(&report)
+	out.successout(buf)
+}
+
+// fasthttp
+func (d *DataImporter) GetFasthttpRequestHandler() fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		d.GetRequestHandlerInner(nil, ctx)
+	})
+}
+
+// net/http
+func (d *DataImporter) GetHttpRequestHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.GetRequestHandlerInner(w, r)
+	})
+}
@@ -0,0 +1,253 @@
+package w3ui
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+)
+
+func newStudentImporter(t *testing.T, db *countingDB, skipInvalid bool) *DataImporter {
+	cfg := &w3req.InsertConfig{
+		AllSQL:     w3sql.NewSQLString("insert into students"),
+		FieldMap:   compileMap,
+		SQLDialect: "sqlite",
+		OnPanic:    func() {},
+		Rules: w3sql.WriteRules{
+			"firstName": {Required: true},
+		},
+	}
+	ins, err := w3req.NewInsertRequester(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins.InitOnce(func() *w3req.InsertOptions {
+		return &w3req.InsertOptions{
+			Logger: &Logger{},
+			DB:     func() w3req.DB { return db },
+		}
+	})
+
+	return NewInsertImporter(&ImportConfig{
+		Fields: map[string]string{
+			"firstName":  "text",
+			"secondName": "text",
+			"age":        "int",
+		},
+		HeaderAliases: map[string]string{"Имя": "firstName", "Фамилия": "secondName", "Возраст": "age"},
+		SkipInvalid:   skipInvalid,
+	}, ins, func() {}).OutputOriginalErrorText()
+}
+
+type importResponse struct {
+	Status   string
+	Message  string
+	Total    int
+	Inserted int64
+	Errors   []w3sql.RowError
+	Details  struct {
+		Kind string
+		Rows []w3sql.RowError
+	}
+}
+
+func postImport(t *testing.T, d *DataImporter, contentType string, body []byte) importResponse {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	d.GetHttpRequestHandler()(w, req)
+
+	var resp importResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	return resp
+}
+
+const importCSV = "\xef\xbb\xbfимя,фамилия,возраст\n" +
+	"Petr,Petrov,30\n" +
+	",Nobody,31\n" +
+	"\n" +
+	"Anna,Ivanova,abc\n" +
+	"Olga,Sidorova,\n"
+
+func TestImportCSV(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	// без SkipInvalid любая ошибка отменяет вставку
+	resp := postImport(t, newStudentImporter(t, db, false), "text/csv", []byte(importCSV))
+	if resp.Status != "error" || resp.Details.Kind != "row_validation" {
+		t.Fatal("unexpected response:", resp)
+	}
+	if len(resp.Details.Rows) != 1 || resp.Details.Rows[0].Row != 5 || resp.Details.Rows[0].Column != "age" {
+		t.Fatal("unexpected row errors:", resp.Details.Rows)
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 4 {
+		t.Fatal("nothing should be inserted, got", n)
+	}
+
+	resp = postImport(t, newStudentImporter(t, db, true), "text/csv", []byte(importCSV))
+	if resp.Status != "success" || resp.Total != 4 || resp.Inserted != 2 {
+		t.Fatal("unexpected response:", resp)
+	}
+	if len(resp.Errors) != 2 ||
+		resp.Errors[0] != (w3sql.RowError{Row: 5, Column: "age", Message: resp.Errors[0].Message}) ||
+		resp.Errors[1] != (w3sql.RowError{Row: 3, Column: "firstName", Message: "value is required"}) {
+		t.Fatal("unexpected row errors:", resp.Errors)
+	}
+	if n, _ := db.SelectInt("select count(*) from students where secondName in ('Petrov', 'Sidorova')"); n != 2 {
+		t.Fatal("expected 2 imported students, got", n)
+	}
+}
+
+func TestImportUnknownColumn(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	resp := postImport(t, newStudentImporter(t, db, true), "", []byte("firstName;score\nIvan;5\n"))
+	if resp.Status != "error" || len(resp.Details.Rows) != 1 ||
+		resp.Details.Rows[0] != (w3sql.RowError{Row: 1, Column: "firstName;score", Message: "unknown column"}) {
+		t.Fatal("unexpected response:", resp)
+	}
+
+	// пустые ячейки в конце заголовка пропускаются
+	resp = postImport(t, newStudentImporter(t, db, false), "", []byte("firstName,age,,\nIvan,5,,\n"))
+	if resp.Status != "success" || resp.Inserted != 1 {
+		t.Fatal("trailing empty header cells should be skipped:", resp)
+	}
+
+	resp = postImport(t, newStudentImporter(t, db, false), "", []byte("firstName,,age\nIvan,x,5\n"))
+	if resp.Status != "error" || len(resp.Details.Rows) != 1 ||
+		resp.Details.Rows[0] != (w3sql.RowError{Row: 1, Column: "#2", Message: "empty column name"}) {
+		t.Fatal("empty column name expected:", resp)
+	}
+
+	resp = postImport(t, newStudentImporter(t, db, false), "", []byte("firstName,Имя\nIvan,Petr\n"))
+	if resp.Status != "error" || len(resp.Details.Rows) != 1 ||
+		resp.Details.Rows[0] != (w3sql.RowError{Row: 1, Column: "Имя", Message: "duplicate column"}) {
+		t.Fatal("duplicate column expected:", resp)
+	}
+}
+
+func buildXLSX(t *testing.T, shared []string, sheet string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	// первый лист не обязательно sheet1.xml, путь к нему берется из связей workbook
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Data" sheetId="2" r:id="rId7"/><sheet name="Other" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId7" Target="/xl/worksheets/data.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c t="inlineStr"><is><t>wrong</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/data.xml":   `<worksheet><sheetData>` + sheet + `</sheetData></worksheet>`,
+	}
+	if shared != nil {
+		sst := `<sst>`
+		for _, s := range shared {
+			sst += `<si><t>` + s + `</t></si>`
+		}
+		files["xl/sharedStrings.xml"] = sst + `</sst>`
+	}
+	for name, content := range files {
+		f, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportXLSX(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	data := buildXLSX(t, []string{"Имя", "Возраст", "Фамилия", "Semen"}, `
+		<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c></row>
+		<row r="2"><c r="A2" t="s"><v>3</v></c><c r="B2"><v>25</v></c><c r="C2" t="inlineStr"><is><t>Semenov</t></is></c></row>
+		<row r="3"><c r="A3" t="inlineStr"><is><t>Vera</t></is></c><c r="C3" t="inlineStr"><is><t>Vasina</t></is></c></row>`)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "students.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	resp := postImport(t, newStudentImporter(t, db, false), mw.FormDataContentType(), body.Bytes())
+	if resp.Status != "success" || resp.Total != 2 || resp.Inserted != 2 || len(resp.Errors) != 0 {
+		t.Fatal("unexpected response:", resp)
+	}
+	var age int64
+	if age, _ = db.SelectInt("select age from students where secondName = 'Semenov'"); age != 25 {
+		t.Fatal("expected age 25, got", age)
+	}
+	if n, _ := db.SelectInt("select count(*) from students where secondName = 'Vasina' and age is null"); n != 1 {
+		t.Fatal("skipped cell should be null")
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	if s, ok := excelDate("45292", false); !ok || s != "2024/1/1" {
+		t.Fatal("unexpected date:", s)
+	}
+	if s, ok := excelDate("45292.75", true); !ok || s != "2024/1/1 18:00:00" {
+		t.Fatal("unexpected datetime:", s)
+	}
+	if _, ok := excelDate("2024-01-01", false); ok {
+		t.Fatal("text date should be left as is")
+	}
+	if c, _ := xlsxColumn("AB12"); c != 27 {
+		t.Fatal("wrong column index", c)
+	}
+	if c, _ := xlsxColumn("XFD1"); c != 16383 {
+		t.Fatal("wrong column index", c)
+	}
+	for _, ref := range []string{"c12", "12", "C", "XFE1", "XFDXFDXFD1", "C1x"} {
+		if _, err := xlsxColumn(ref); err == nil {
+			t.Fatal("bad cell reference expected for", ref)
+		}
+	}
+}
+
+func TestReadXLSXLimits(t *testing.T) {
+	if _, _, err := readXLSX(buildXLSX(t, nil, `<row><c r="c1"><v>1</v></c></row>`), 0, 0); err == nil {
+		t.Fatal("error expected for bad cell reference")
+	}
+	if _, _, err := readXLSX(buildXLSX(t, nil, `<row><c r="XFDXFDXFD1"><v>1</v></c></row>`), 0, 0); err == nil {
+		t.Fatal("error expected for huge cell reference")
+	}
+
+	rows := strings.Repeat(`<row><c><v>1</v></c></row>`, 10)
+	table, lines, err := readXLSX(buildXLSX(t, nil, rows), 3, 0)
+	if err != nil || len(table) != 3 || lines[2] != 3 {
+		t.Fatal("expected 3 rows, got", table, lines, err)
+	}
+
+	d := newStudentImporter(t, &countingDB{DbMap: openStudents(t)}, true)
+	d.cfg.MaxRows = 2
+	if _, rows, _, _ := d.readTable([]byte(importCSV)); len(rows) != 4 {
+		t.Fatal("expected header and 3 data rows, got", rows)
+	}
+	if resp := postImport(t, d, "text/csv", []byte(importCSV)); resp.Status != "error" || !strings.HasSuffix(resp.Message, "more than 2 rows") {
+		t.Fatal("unexpected response:", resp)
+	}
+
+	// распакованный лист больше ограничения
+	if _, _, err := readXLSX(buildXLSX(t, nil, rows), 0, 100); err == nil {
+		t.Fatal("error expected for too large xlsx part")
+	}
+}
@@ -0,0 +1,169 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+// Ответ в формате OData на параметры $filter, $orderby, $top, $skip и $count (см. w3sql.ParseOData):
+// {"@odata.count": n, "value": [...], "@odata.nextLink": "..."}, ошибки - {"error": {code, message, target}}
+// с кодом HTTP 400, системные - 500. Имена свойств - поля фронта из карты полей DataRequester.
+
+type odataAnswer struct {
+	Count    *int64 `json:"@odata.count,omitempty"`
+	Value    any    `json:"value"`
+	NextLink string `json:"@odata.nextLink,omitempty"`
+}
+
+type odataError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Target  string `json:"target,omitempty"`
+	} `json:"error"`
+}
+
+// odataErrorBody код ошибки - вид ошибки запроса, если он известен, иначе ее текст
+func odataErrorBody(text string, details *ErrorDetails) (int, []byte) {
+	e := odataError{}
+	e.Error.Code = text
+	e.Error.Message = text
+	status := http.StatusBadRequest
+	if details != nil {
+		e.Error.Code = details.Kind
+		e.Error.Target = details.Path
+	} else if strings.HasPrefix(text, SYSTEM_ERROR) {
+		status = http.StatusInternalServerError
+	}
+	buf, _ := json.Marshal(&e)
+	return status, buf
+}
+
+func newODataResponder(w http.ResponseWriter, req any) *responder {
+	out := newResponder(w, req)
+	switch t := req.(type) {
+	case *http.Request:
+		out.errdetails = func(text string, details *ErrorDetails) {
+			status, buf := odataErrorBody(text, details)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(buf)
+		}
+	case *fasthttp.RequestCtx:
+		out.errdetails = func(text string, details *ErrorDetails) {
+			status, buf := odataErrorBody(text, details)
+			t.SetStatusCode(status)
+			t.SetContentType("application/json")
+			t.SetBody(buf)
+		}
+	}
+	return out
+}
+
+// odataNextLink ссылка на следующую страницу: параметры запроса с новыми $skip и $top
+func odataNextLink(req any, raw string, skip int, top *int) string {
+	var path string
+	switch t := req.(type) {
+	case *http.Request:
+		path = t.URL.Path
+	case *fasthttp.RequestCtx:
+		path = string(t.Path())
+	}
+	values, _ := url.ParseQuery(raw)
+	values.Set("$skip", strconv.Itoa(skip))
+	if top != nil {
+		values.Set("$top", strconv.Itoa(*top))
+	}
+	return path + "?" + values.Encode()
+}
+
+// GetODataHandlerInner отвечает на запрос с параметрами OData в строке URL; limit - размер страницы,
+// если $top больше или не задан, в ответ добавляется @odata.nextLink на следующую страницу
+func (d *DataRequester[T]) GetODataHandlerInner(w http.ResponseWriter, req any, limit int, appendQuery *Query) {
+	defer d.onPanic()
+
+	out := newODataResponder(w, req)
+	raw, _ := getQueryString(req)
+	rq, count, err := w3sql.ParseOData(raw)
+	if err != nil {
+		d.logger.logHandleError(err, out)
+		return
+	}
+	q := (*Query)(rq)
+	appendToQuery(q, appendQuery)
+	q, ok := d.applyView(req, q, out)
+	if !ok {
+		return
+	}
+
+	answer := odataAnswer{}
+	if count {
+		n, err := d.rows.Count((*w3sql.Query)(q))
+		if err != nil {
+			d.logger.logHandleError(err, out)
+			return
+		}
+		answer.Count = &n
+	}
+
+	skip, top := 0, q.Limit
+	if q.Offset != nil {
+		skip = *q.Offset
+	}
+	size := limit
+	if top != nil && (*top < size || size <= 0) {
+		size = *top
+	}
+	if size > 0 {
+		// лишняя строка показывает, есть ли следующая страница
+		fetch := size + 1
+		q.Limit = &fetch
+	}
+
+	records, _, err := d.sel.Handle((*w3sql.Query)(q))
+	if err != nil {
+		d.logger.logHandleError(err, out)
+		return
+	}
+	if size > 0 && len(records) > size {
+		records = records[:size]
+		if top == nil || *top > size {
+			var rest *int
+			if top != nil {
+				n := *top - size
+				rest = &n
+			}
+			answer.NextLink = odataNextLink(req, raw, skip+size, rest)
+		}
+	}
+	if records == nil {
+		records = []T{}
+	}
+	if d.formatFields != nil && len(records) != 0 {
+		d.formatFields(records)
+	}
+	answer.Value = records
+
+	buf, _ := json.Marshal(&answer)
+	out.successout(buf)
+}
+
+// fasthttp
+func (d *DataRequester[T]) GetFasthttpODataHandler(limit int, appendQuery *Query) fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		d.GetODataHandlerInner(nil, ctx, limit, appendQuery)
+	})
+}
+
+// net/http
+func (d *DataRequester[T]) GetHttpODataHandler(limit int, appendQuery *Query) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.GetODataHandlerInner(w, r, limit, appendQuery)
+	})
+}
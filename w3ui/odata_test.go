@@ -0,0 +1,78 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type odataResponse struct {
+	Count    *int64           `json:"@odata.count"`
+	Value    []map[string]any `json:"value"`
+	NextLink string           `json:"@odata.nextLink"`
+	Error    struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Target  string `json:"target"`
+	} `json:"error"`
+}
+
+func odataCall(t *testing.T, handler http.HandlerFunc, target string) (int, odataResponse) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+	var resp odataResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	return w.Code, resp
+}
+
+func odataNames(resp odataResponse) string {
+	names := make([]string, len(resp.Value))
+	for i, r := range resp.Value {
+		names[i] = r["FirstName"].(string)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestODataHandler(t *testing.T) {
+	handler := newExportRequester(t, &countingDB{DbMap: openStudents(t)}).GetHttpODataHandler(2, nil)
+
+	_, resp := odataCall(t, handler, "/students?$orderby=age&$count=true")
+	if odataNames(resp) != "masha,lena" || resp.Count == nil || *resp.Count != 4 {
+		t.Fatal("first page with count expected:", GetJSON(resp))
+	}
+	if resp.NextLink != "/students?%24count=true&%24orderby=age&%24skip=2" {
+		t.Fatal("unexpected next link:", resp.NextLink)
+	}
+	_, resp = odataCall(t, handler, resp.NextLink)
+	if odataNames(resp) != "petya,vanya" || resp.NextLink != "" {
+		t.Fatal("last page without next link expected:", GetJSON(resp))
+	}
+
+	_, resp = odataCall(t, handler, "/students?$top=3&$orderby=grade%20desc")
+	if odataNames(resp) != "vanya,petya" || resp.Count != nil || !strings.Contains(resp.NextLink, "%24top=1") {
+		t.Fatal("page with rest of $top expected:", GetJSON(resp))
+	}
+	_, resp = odataCall(t, handler, "/students?$top=1&$orderby=grade%20desc")
+	if odataNames(resp) != "vanya" || resp.NextLink != "" {
+		t.Fatal("no next link expected within $top:", GetJSON(resp))
+	}
+
+	filter := "(age ge 20 and not startswith(firstName,'v')) or endswith(secondName,'nina') eq true"
+	_, resp = odataCall(t, handler, "/students?$count=true&$orderby=age&$filter="+strings.ReplaceAll(filter, " ", "%20"))
+	if odataNames(resp) != "masha,lena" || *resp.Count != 3 || resp.NextLink == "" {
+		t.Fatal("unexpected filtered rows:", GetJSON(resp))
+	}
+
+	code, resp := odataCall(t, handler, "/students?$filter=age%20gt")
+	if code != http.StatusBadRequest || resp.Error.Code != "syntax_error" || resp.Error.Target != "$filter" {
+		t.Fatal("syntax error expected:", code, GetJSON(resp))
+	}
+	code, resp = odataCall(t, handler, "/students?$filter=nickname%20eq%20'x'&$count=true")
+	if code != http.StatusBadRequest || resp.Error.Code != "unknown_field" || !strings.HasPrefix(resp.Error.Message, UNKNOWN_FIELD) {
+		t.Fatal("unknown field expected:", code, GetJSON(resp))
+	}
+}
@@ -1,11 +1,15 @@
 package w3ui
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"runtime"
 	"runtime/debug"
+	"strings"
 
 	"github.com/algebrain/w3/w3req"
 	"github.com/algebrain/w3/w3sql"
@@ -13,8 +17,25 @@ import (
 )
 
 const (
-	SYSTEM_ERROR       = "System error"
-	INVALID_PARAMETERS = "Invalid parameters"
+	SYSTEM_ERROR         = "System error"
+	INVALID_PARAMETERS   = "Invalid parameters"
+	UNKNOWN_FIELD        = "Unknown field"
+	MISSING_FIELD        = "Missing field"
+	UNSUPPORTED_OPERATOR = "Unsupported operator"
+	UNSUPPORTED_TYPE     = "Unsupported type"
+	BAD_SORT_DIRECTION   = "Bad sort direction"
+	BAD_VALUE            = "Bad value"
+	MAX_DEPTH            = "Query is too deep"
+	MAX_CONDITIONS       = "Too many conditions"
+	MAX_LIST_LENGTH      = "List of values is too long"
+	MAX_SORT             = "Too many sort columns"
+	MAX_LIMIT            = "Limit is too large"
+	BODY_TOO_LARGE       = "Request body is too large"
+	MAX_ROWS             = "Too many rows to update"
+	UPDATE_CONFLICT      = "Record was changed by another user"
+	FORBIDDEN_FIELD      = "Field is set by server"
+	ROW_VALIDATION       = "Invalid values"
+	SYNTAX_ERROR         = "Filter syntax error"
 )
 
 type ExtLogger interface {
@@ -30,9 +51,21 @@ type Logger struct {
 
 type DataRequester[T any] struct {
 	sel          w3req.SelectRequester[T]
+	rows         w3req.SelectRowsRequester[T]
+	fieldMap     map[string]string
 	formatFields func([]T)
 	logger       *Logger
 	onPanic      func()
+	maxBodySize  int64
+	views        *ViewStore
+	viewGrid     string
+	fieldTypes   map[string]string //типы полей для условий GET запроса, см. SetFieldTypes
+}
+
+// Limits ограничения сложности запроса и размера тела запроса, 0 - без ограничения
+type Limits struct {
+	w3sql.Limits
+	MaxBodySize int64
 }
 
 func (log *Logger) setErrorLogger(z ExtLogger) {
@@ -99,12 +132,65 @@ func NewDataRequester3[T any](
 	if err != nil {
 		panic(err)
 	}
+	rows, err := w3req.NewSelectRowsRequester(req)
+	if err != nil {
+		panic(err)
+	}
 
 	return &DataRequester[T]{
 		sel:        req,
+		rows:       rows,
+		fieldMap:   compileMap,
 		onPanic:    onPanic,
 		logger:     &Logger{},
+		fieldTypes: textFields[T](compileMap),
+	}
+}
+
+// textFields поля, колонки которых - строковые поля T (по db тегу или имени), получают тип text:
+// иначе name[eq]=123 в строке URL стало бы числовым условием
+func textFields[T any](fieldmap map[string]string) map[string]string {
+	types := map[string]string{}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return types
+	}
+	strs := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		if f.Type.Kind() == reflect.String {
+			strs[strings.ToLower(name)] = true
+		}
+	}
+
+	for field, col := range fieldmap {
+		if col == "" {
+			col = field
+		}
+		if i := strings.LastIndex(col, "."); i >= 0 {
+			col = col[i+1:]
+		}
+		if strs[strings.ToLower(col)] {
+			types[field] = "text"
+		}
+	}
+	return types
+}
+
+// типы полей (поле фронта -> тип условия) для условий GET запроса без типа в ключе,
+// дополняют выведенные из строковых полей T; вызывать внутри InitOnce
+func (d *DataRequester[T]) SetFieldTypes(types map[string]string) *DataRequester[T] {
+	if d.fieldTypes == nil {
+		d.fieldTypes = map[string]string{}
+	}
+	for f, tp := range types {
+		d.fieldTypes[f] = tp
 	}
+	return d
 }
 
 type RequesterOptions[T any] struct {
@@ -119,8 +205,9 @@ func (d *DataRequester[T]) InitOnce(f func() RequesterOptions[T]) *DataRequester
 		d.formatFields = opt.FormatFields
 		d.logger.setErrorLogger(opt.ErrorLog)
 		return &w3req.SelectOptions[T]{
-			Logger: d.logger,
-			DB:     opt.GetDB,
+			Logger:      d.logger,
+			DB:          opt.GetDB,
+			BindDialect: string(globalConfig.SQLSyntax),
 		}
 	})
 	return d
@@ -140,6 +227,74 @@ func (d *DataRequester[T]) SetDebugLog(log ExtLogger) *DataRequester[T] {
 	return d
 }
 
+// ограничивает сложность запросов, превышение возвращает свой код ошибки
+// вызывать внутри InitOnce
+func (d *DataRequester[T]) SetLimits(l Limits) *DataRequester[T] {
+	d.sel.SetLimits(&l.Limits)
+	d.maxBodySize = l.MaxBodySize
+	return d
+}
+
+// включает LRU кеш подготовленных запросов, если DB реализует w3req.PreparingDB,
+// например w3req.SQLPreparingDB{DB: dbmap, SQL: dbmap.Db}; вызывать внутри InitOnce
+func (d *DataRequester[T]) SetStmtCacheSize(size int) *DataRequester[T] {
+	d.sel.SetStmtCacheSize(size)
+	return d
+}
+
+// включает кеш результатов, запись в cfg.Tables через w3req.Insert/Update/DeleteRequester его сбрасывает
+// вызывать внутри InitOnce
+func (d *DataRequester[T]) SetResultCache(cfg w3req.ResultCacheConfig) *DataRequester[T] {
+	d.sel.SetResultCache(&cfg)
+	return d
+}
+
+// скрывает мягко удаленные строки: добавляет к условию <col> is null
+// вызывать внутри InitOnce
+func (d *DataRequester[T]) SetSoftDeleteCol(col string) *DataRequester[T] {
+	d.sel.SetSoftDeleteCol(col)
+	return d
+}
+
+// передает записи запроса в f по одной, например для фоновых задач; DB должна реализовать w3req.RowsDB
+func (d *DataRequester[T]) Iterate(ctx context.Context, q *Query, f func(T) error) error {
+	return d.rows.Iterate(ctx, (*w3sql.Query)(q), f)
+}
+
+// сохраненный фильтр таблицы grid, имя которого передано параметром view строки запроса,
+// накладывается на запрос, см. SavedView.Merge
+func (d *DataRequester[T]) SetViews(store *ViewStore, grid string) *DataRequester[T] {
+	d.views = store
+	d.viewGrid = grid
+	return d
+}
+
+// readQuery с наложением сохраненного фильтра
+func (d *DataRequester[T]) readQuery(req any, appendQuery *Query, out *responder) (*Query, bool) {
+	q, ok := d.logger.readTypedQuery(req, d.maxBodySize, d.fieldTypes, appendQuery, out)
+	if !ok {
+		return nil, false
+	}
+	return d.applyView(req, q, out)
+}
+
+// накладывает на запрос сохраненный фильтр из параметра view, если заданы фильтры
+func (d *DataRequester[T]) applyView(req any, q *Query, out *responder) (*Query, bool) {
+	if d.views == nil {
+		return q, true
+	}
+	q, err := d.views.viewQuery(req, d.viewGrid, q)
+	if err != nil {
+		d.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return nil, false
+	}
+	return q, true
+}
+
+func (d *DataRequester[T]) StmtCacheStats() w3req.StmtCacheStats {
+	return d.sel.StmtCacheStats()
+}
+
 // если включен, то вместо "Invalid Parameters" будет возвращать настоящую ошибку
 // вызывать внутри InitOnce
 func (d *DataRequester[T]) OutputOriginalErrorText() *DataRequester[T] {
@@ -147,46 +302,69 @@ func (d *DataRequester[T]) OutputOriginalErrorText() *DataRequester[T] {
 	return d
 }
 
-type allTableW2UI struct {
-	Status  string `json:"status"`
-	Total   int64  `json:"total"`
-	Records any    `json:"records"`
+type responder struct {
+	errdetails func(string, *ErrorDetails)
+	successout func([]byte)
 }
 
-func (d *DataRequester[T]) GetFasthttpRequestHandlerInner(
-	w http.ResponseWriter,
-	req any,
-	limit int,
-	appendQuery *Query,
-) {
-	defer d.onPanic()
-
-	errout := func(t string) {}
-	successout := func(b []byte) {}
+func newResponder(w http.ResponseWriter, req any) *responder {
+	out := &responder{
+		errdetails: func(t string, d *ErrorDetails) {},
+		successout: func(b []byte) {},
+	}
 
 	switch t := req.(type) {
 	case *http.Request:
-		errout = func(text string) {
-			globalConfig.ErrorCodes.Error(w, text)
+		out.errdetails = func(text string, details *ErrorDetails) {
+			globalConfig.ErrorCodes.ErrorWithDetails(w, text, details)
 		}
-		successout = func(b []byte) {
+		out.successout = func(b []byte) {
 			w.WriteHeader(200)
 			w.Header().Set("Content-Type", "application/json")
 			w.Write(b)
 		}
 
 	case *fasthttp.RequestCtx:
-		errout = func(text string) {
-			globalConfig.ErrorCodes.CtxRetError(t, text)
+		out.errdetails = func(text string, details *ErrorDetails) {
+			globalConfig.ErrorCodes.CtxRetErrorWithDetails(t, text, details)
 		}
-		successout = func(b []byte) {
+		out.successout = func(b []byte) {
 			t.Success("application/json", b)
 		}
 	}
+	return out
+}
 
-	q, err := ReadCtxQuery(req)
-	if err != nil {
-		d.logger.LogError(INVALID_PARAMETERS, err, errout)
+func (out *responder) errout(text string) {
+	out.errdetails(text, nil)
+}
+
+// читает запрос и добавляет к нему условия и сортировку из appendQuery
+func (log *Logger) readQuery(req any, maxBodySize int64, appendQuery *Query, out *responder) (*Query, bool) {
+	return log.readTypedQuery(req, maxBodySize, nil, appendQuery, out)
+}
+
+// как readQuery, types - объявленные типы полей для условий из строки URL
+func (log *Logger) readTypedQuery(req any, maxBodySize int64, types map[string]string, appendQuery *Query, out *responder) (*Query, bool) {
+	q, err := readCtxQuery(req, maxBodySize, types)
+	if errors.Is(err, ErrBodyTooLarge) {
+		log.LogError(BODY_TOO_LARGE, err, out.errout)
+		return nil, false
+	} else if _, _, ok := QueryErrorDetails(err); ok {
+		log.logHandleError(err, out)
+		return nil, false
+	} else if err != nil {
+		log.LogError(INVALID_PARAMETERS, err, out.errout)
+		return nil, false
+	}
+
+	appendToQuery(q, appendQuery)
+	return q, true
+}
+
+// добавляет к запросу условия и сортировку из appendQuery
+func appendToQuery(q *Query, appendQuery *Query) {
+	if appendQuery == nil {
 		return
 	}
 
@@ -194,7 +372,9 @@ func (d *DataRequester[T]) GetFasthttpRequestHandlerInner(
 		q.Sort = append(q.Sort, appendQuery.Sort...)
 	}
 
-	if appendQuery.Search != nil {
+	if appendQuery.Search != nil && q.Search == nil {
+		q.Search = appendQuery.Search
+	} else if appendQuery.Search != nil {
 		switch v := appendQuery.Search.(type) {
 		case *w3sql.AtomaryCondition:
 			q.Search = w3sql.And(q.Search, v)
@@ -205,17 +385,51 @@ func (d *DataRequester[T]) GetFasthttpRequestHandlerInner(
 			}
 		}
 	}
+}
+
+// ошибки компиляции запроса отдаются со своим кодом и подробностями, остальные - как SYSTEM_ERROR
+func (log *Logger) logHandleError(err error, out *responder) {
+	if text, details, ok := QueryErrorDetails(err); ok {
+		log.LogError(text, err, func(t string) {
+			out.errdetails(t, details)
+		})
+		return
+	}
+	log.LogError(SYSTEM_ERROR, err, out.errout)
+}
+
+type allTableW2UI struct {
+	Status  string `json:"status"`
+	Total   int64  `json:"total"`
+	Records any    `json:"records"`
+}
+
+func (d *DataRequester[T]) GetFasthttpRequestHandlerInner(
+	w http.ResponseWriter,
+	req any,
+	limit int,
+	appendQuery *Query,
+) {
+	defer d.onPanic()
+
+	out := newResponder(w, req)
+	q, ok := d.readQuery(req, appendQuery, out)
+	if !ok {
+		return
+	}
 
 	rr := allTableW2UI{}
 
-	if q.Search != nil {
+	// GET запрос без условий - закладка состояния грида, например ?sort=-age&limit=50
+	_, fromURL := getQueryString(req)
+	if q.Search != nil || fromURL {
 		if q.Limit == nil || *q.Limit > limit || *q.Limit == 0 {
 			q.Limit = &limit
 		}
 
 		records, total, err := d.sel.Handle((*w3sql.Query)(q))
 		if err != nil {
-			d.logger.LogError(SYSTEM_ERROR, err, errout)
+			d.logger.logHandleError(err, out)
 			return
 		} else {
 			rr.Status = "success"
@@ -230,7 +444,7 @@ func (d *DataRequester[T]) GetFasthttpRequestHandlerInner(
 	}
 
 	buf, _ := json.Marshal(&rr)
-	successout(buf)
+	out.successout(buf)
 }
 
 // fasthttp
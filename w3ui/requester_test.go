@@ -7,9 +7,11 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/algebrain/w3/w3req"
 	"github.com/algebrain/w3/w3sql"
@@ -183,3 +185,341 @@ func TestRequesterSelect(t *testing.T) {
 		t.Fatal("total=3 expected, got", answer.Total)
 	}
 }
+
+func TestRequesterSelectError(t *testing.T) {
+	db := openStudents(t)
+
+	requester1.InitOnce(func() RequesterOptions[Student] {
+		return RequesterOptions[Student]{
+			GetDB:    func() w3req.DB { return db },
+			ErrorLog: testLogger{},
+		}
+	})
+
+	cond := `{
+		"Search": {
+			"Op": "AND",
+			"Query": [
+				{"Col": "age", "Val": 20, "Op": ">", "Type": "int"},
+				{"Col": "nickName", "Val": "vanya", "Op": "==", "Type": "text"}
+			]
+		}
+	}`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(cond))
+	w := httptest.NewRecorder()
+	handler := requester1.GetHttpRequestHandler(100, &Query{})
+	handler(w, req)
+
+	var answer W2UIError
+	b, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal(b, &answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("ANSWER:", GetJSON(answer))
+
+	if answer.Status != "error" || answer.Message != UNKNOWN_FIELD {
+		t.Fatal("unknown field error expected, got", answer.Message)
+	}
+	if answer.ErrCode != globalConfig.ErrorCodes[UNKNOWN_FIELD] {
+		t.Fatal("unexpected error code", answer.ErrCode)
+	}
+	d := answer.Details
+	if d == nil || d.Kind != "unknown_field" || d.Field != "nickName" || d.Path != "Search.Query[1]" {
+		t.Fatal("unexpected error details", GetJSON(d))
+	}
+}
+
+func TestRequesterLimits(t *testing.T) {
+	db := openStudents(t)
+
+	requester := NewDataRequester3[Student](allSQL, compileMap, toLowerCols, func() {})
+	requester.InitOnce(func() RequesterOptions[Student] {
+		requester.SetLimits(Limits{
+			Limits:      w3sql.Limits{MaxListLength: 2},
+			MaxBodySize: 200,
+		})
+		return RequesterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+		}
+	})
+
+	handler := requester.GetHttpRequestHandler(100, &Query{})
+	for _, c := range []struct {
+		body string
+		err  string
+	}{
+		{`{"Search": {"Col": "age", "Val": [19, 20, 21], "Op": "in", "Type": "int"}}`, MAX_LIST_LENGTH},
+		{`{"Search": {"Col": "firstName", "Val": "` + strings.Repeat("a", 200) + `", "Op": "==", "Type": "text"}}`, BODY_TOO_LARGE},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		var answer W2UIError
+		err := json.NewDecoder(w.Result().Body).Decode(&answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if answer.Message != c.err || answer.ErrCode != globalConfig.ErrorCodes[c.err] {
+			t.Fatal("error", c.err, "expected, got", GetJSON(answer))
+		}
+	}
+}
+
+// preparingDB готовит запросы через настоящий *sql.DB и считает подготовленные и закрытые
+type preparingDB struct {
+	*gorp.DbMap
+	prepared []string
+	closed   int
+}
+
+type closeCounter struct {
+	w3req.Stmt
+	db *preparingDB
+}
+
+func (db *preparingDB) Prepare(query string) (w3req.Stmt, error) {
+	stmt, err := (&w3req.SQLPreparingDB{DB: db.DbMap, SQL: db.DbMap.Db}).Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	db.prepared = append(db.prepared, query)
+	return &closeCounter{Stmt: stmt, db: db}, nil
+}
+
+func (s *closeCounter) Close() error {
+	s.db.closed++
+	return s.Stmt.Close()
+}
+
+func TestRequesterStmtCache(t *testing.T) {
+	db := &preparingDB{DbMap: openStudents(t)}
+
+	requester := NewDataRequester3[Student](allSQL, compileMap, toLowerCols, func() {})
+	requester.InitOnce(func() RequesterOptions[Student] {
+		requester.SetStmtCacheSize(1)
+		return RequesterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+		}
+	})
+
+	handler := requester.GetHttpRequestHandler(100, &Query{})
+	for _, c := range []struct {
+		body    string
+		records int
+	}{
+		{`{"Search": {"Col": "age", "Val": 20, "Op": ">", "Type": "int"}}`, 2},
+		{`{"Search": {"Col": "age", "Val": 21, "Op": ">", "Type": "int"}}`, 1},
+		{`{"Search": {"Col": "age", "Val": 19, "Op": ">", "Type": "int"}}`, 3},
+		{`{"Search": {"Col": "age", "Val": 19, "Op": "<", "Type": "int"}}`, 0},
+		{`{"Search": {"Col": "age", "Val": 19, "Op": ">", "Type": "int"}}`, 3},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		var answer struct {
+			Status  string `json:"status"`
+			Records []Student
+		}
+		err := json.NewDecoder(w.Result().Body).Decode(&answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if answer.Status != "success" {
+			t.Fatal("success expected, got", answer.Status)
+		}
+		if c.records != len(answer.Records) {
+			t.Fatal(c.records, "records expected, got", GetJSON(answer))
+		}
+	}
+
+	stats := requester.StmtCacheStats()
+	t.Log("STATS:", GetJSON(stats), stats.HitRate())
+	if stats.Hits != 2 || stats.Misses != 3 || stats.Evictions != 2 || stats.Size != 1 {
+		t.Fatal("unexpected cache stats", GetJSON(stats))
+	}
+	if len(db.prepared) != 3 || db.closed != 2 {
+		t.Fatal("3 prepared and 2 closed statements expected, got", db.prepared, db.closed)
+	}
+	// готовится SQL с плейсхолдерами драйвера, а не с именованными параметрами
+	if !strings.Contains(db.prepared[0], "age>?") || strings.Contains(db.prepared[0], ":") {
+		t.Fatal("positional SQL expected, got", db.prepared[0])
+	}
+}
+
+type countingDB struct {
+	*gorp.DbMap
+	selects int
+}
+
+func (db *countingDB) Select(holder any, query string, args ...any) ([]any, error) {
+	db.selects++
+	return db.DbMap.Select(holder, query, args...)
+}
+
+// gorp.v1 не подставляет именованные параметры в Exec, поэтому они передаются через sql.Named
+func namedArgs(args []any) []any {
+	var named []any
+	for _, a := range args {
+		if m, ok := a.(map[string]any); ok {
+			for k, v := range m {
+				named = append(named, sql.Named(k, v))
+			}
+		} else {
+			named = append(named, a)
+		}
+	}
+	return named
+}
+
+func (db *countingDB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.DbMap.Exec(query, namedArgs(args)...)
+}
+
+type namedTx struct {
+	*gorp.Transaction
+}
+
+func (tx namedTx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.Transaction.Exec(query, namedArgs(args)...)
+}
+
+func TestRequesterResultCache(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	requester := NewDataRequester3[Student](allSQL, compileMap, toLowerCols, func() {})
+	requester.InitOnce(func() RequesterOptions[Student] {
+		requester.SetResultCache(w3req.ResultCacheConfig{
+			Size:   10,
+			TTL:    time.Minute,
+			Tables: []string{"students"},
+		})
+		return RequesterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			FormatFields: func(r []Student) {
+				for i := 0; i < len(r); i++ {
+					r[i].FirstName = strings.ToUpper(r[i].FirstName)
+				}
+			},
+		}
+	})
+
+	inserter, err := w3req.NewInsertRequester(&w3req.InsertConfig{
+		AllSQL:     w3sql.NewSQLString("insert into students"),
+		FieldMap:   compileMap,
+		SQLDialect: "sqlite",
+		OnPanic:    func() {},
+		Table:      "students",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inserter.InitOnce(func() *w3req.InsertOptions {
+		return &w3req.InsertOptions{
+			DB: func() w3req.DB { return db },
+		}
+	})
+
+	handler := requester.GetHttpRequestHandler(100, &Query{})
+	get := func(expectedTotal int) {
+		req := httptest.NewRequest(
+			http.MethodPost, "/",
+			strings.NewReader(`{"Search": {"Col": "age", "Val": 20, "Op": ">", "Type": "int"}}`),
+		)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		var answer struct {
+			Total   int       `json:"total"`
+			Records []Student `json:"records"`
+		}
+		err := json.NewDecoder(w.Result().Body).Decode(&answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if answer.Total != expectedTotal {
+			t.Fatal("total", expectedTotal, "expected, got", answer.Total)
+		}
+		for _, s := range answer.Records {
+			if s.FirstName != strings.ToUpper(strings.ToLower(s.FirstName)) {
+				t.Fatal("formatted name expected, got", s.FirstName)
+			}
+		}
+	}
+
+	get(2)
+	get(2)
+	if db.selects != 1 {
+		t.Fatal("1 select expected, got", db.selects)
+	}
+
+	q := MustReadJSON(`{
+		"Insert": {
+			"Cols": ["firstName", "secondName", "age", "grade"],
+			"Values": [["kolya", "kolin", 23, 55]]
+		}
+	}`)
+	err = inserter.Handle((*w3sql.Query)(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get(3)
+	if db.selects != 2 {
+		t.Fatal("2 selects expected, got", db.selects)
+	}
+}
+
+func TestRequesterGetQuery(t *testing.T) {
+	handler := newExportRequester(t, &countingDB{DbMap: openStudents(t)}).GetHttpRequestHandler(100, nil)
+
+	get := func(url string) (W2UIError, []Student) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var answer struct {
+			W2UIError
+			Records []Student `json:"records"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return answer.W2UIError, answer.Records
+	}
+
+	answer, records := get("/?age[lt]=21&sort=-age&limit=1&offset=1&view=none")
+	if answer.Status != "success" || len(records) != 1 || records[0].FirstName != "masha" {
+		t.Fatal("unexpected answer:", GetJSON(answer), GetJSON(records))
+	}
+	answer, records = get("/?firstName[starts]=pe&filter=" + url.QueryEscape(`age > 20 or grade < 0`))
+	if answer.Status != "success" || len(records) != 1 || records[0].FirstName != "petya" {
+		t.Fatal("unexpected answer:", GetJSON(answer), GetJSON(records))
+	}
+
+	// без условий - все записи с сортировкой и лимитом
+	answer, records = get("/?sort=-age&limit=2")
+	if answer.Status != "success" || len(records) != 2 || records[0].FirstName != "vanya" || records[1].FirstName != "petya" {
+		t.Fatal("unexpected answer without search:", GetJSON(answer), GetJSON(records))
+	}
+	// тип условия по строковому полю Student - text, а не int по первому значению
+	answer, records = get("/?firstName[eq]=123")
+	if answer.Status != "success" || len(records) != 0 {
+		t.Fatal("text condition expected:", GetJSON(answer), GetJSON(records))
+	}
+
+	answer, _ = get("/?filter=age+%3E")
+	if answer.Status != "error" || answer.Details == nil || answer.Details.Kind != "syntax_error" || answer.Details.Path != "filter" {
+		t.Fatal("syntax error expected, got", GetJSON(answer))
+	}
+	answer, _ = get("/?nickName[eq]=x")
+	if answer.Status != "error" || answer.Details == nil || answer.Details.Field != "nickName" {
+		t.Fatal("unknown field error expected, got", GetJSON(answer))
+	}
+}
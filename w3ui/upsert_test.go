@@ -1,8 +1,17 @@
 package w3ui
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
 )
 
 func TestCompileUpdate(t *testing.T) {
@@ -76,4 +85,664 @@ func TestCompileUpdate(t *testing.T) {
 	if v, ok := sq.InsertValues["uicreated2"]; !ok || v == "" {
 		t.Fatal("bad insert values")
 	}
+
+	// несколько групп Update.Rows не теряются молча
+	q, err = ReadJSON(`{"Update": {"Rows": [{"id": 1, "name": "a"}, {"id": 2, "email": "b"}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.CompileUpsert("id", fieldMap, beforeCreate); err == nil {
+		t.Fatal("error expected for several update groups")
+	} else if !strings.Contains(err.Error(), "Update.Rows") {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestUpsertRequester(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	upserter, err := w3req.NewUpsertRequester(&w3req.UpsertConfig{
+		AllSQL:       w3sql.NewSQLString("insert into students"),
+		FieldMap:     compileMap,
+		ConflictCols: []string{"studentID"},
+		KeepCols:     []string{"firstName"},
+		SQLDialect:   "sqlite",
+		OnPanic:      func() {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upserter.InitOnce(func() *w3req.UpsertOptions {
+		return &w3req.UpsertOptions{
+			DB: func() w3req.DB { return db },
+		}
+	})
+
+	q := MustReadJSON(`{
+		"Insert": {
+			"Cols": ["id", "firstName", "secondName", "age", "grade"],
+			"Values": [
+				[1, "ivan", "ivanov", 23, 100],
+				[5, "kolya", "kolin", 18, 55]
+			]
+		}
+	}`)
+	err = upserter.Handle((*w3sql.Query)(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var students []Student
+	_, err = db.Select(&students, "select * from students order by studentID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("STUDENTS:", GetJSON(students))
+
+	if len(students) != 5 {
+		t.Fatal("5 students expected, got", len(students))
+	}
+	if s := students[0]; s.FirstName != "vanya" || s.Age != 23 || s.Score != 100 {
+		t.Fatal("updated student with kept first name expected, got", GetJSON(s))
+	}
+	if s := students[4]; s.StudentID != 5 || s.FirstName != "kolya" {
+		t.Fatal("inserted student expected, got", GetJSON(s))
+	}
+}
+
+func TestDataWriterReturning(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	writer := NewDataWriter[Student](
+		&w3req.InsertConfig{
+			AllSQL:     w3sql.NewSQLString("insert into students"),
+			FieldMap:   compileMap,
+			SQLDialect: "sqlite",
+			Returning:  []string{"*"},
+		},
+		&w3req.UpdateConfig{
+			AllSQL:      w3sql.NewSQLString("update students"),
+			IDFieldName: "studentID",
+			FieldMap:    compileMap,
+			SQLDialect:  "sqlite",
+			Returning:   []string{"*"},
+		},
+		func() {},
+	)
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+		}
+	})
+	handler := writer.GetHttpRequestHandler()
+
+	write := func(body string) []Student {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		var resp struct {
+			Status  string
+			Records []struct {
+				StudentID  int64  `json:"StudentID"`
+				FirstName  string `json:"FirstName"`
+				SecondName string `json:"SecondName"`
+				Age        int    `json:"Age"`
+			}
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		if resp.Status != "success" {
+			t.Fatal("unexpected response:", w.Body.String())
+		}
+		result := make([]Student, len(resp.Records))
+		for i, r := range resp.Records {
+			result[i] = Student{StudentID: r.StudentID, FirstName: r.FirstName, SecondName: r.SecondName, Age: r.Age}
+		}
+		return result
+	}
+
+	inserted := write(`{"Insert": {
+		"Cols": ["firstName", "secondName", "age", "grade"],
+		"Values": [["Ivan", "Petrov", 30, 70]]
+	}}`)
+	if len(inserted) != 1 || inserted[0].StudentID == 0 || inserted[0].FirstName != "Ivan" {
+		t.Fatal("unexpected inserted records:", inserted)
+	}
+
+	updated := write(fmt.Sprintf(`{"Update": {
+		"Cols": ["age", "id"],
+		"Values": [[31, %d]]
+	}}`, inserted[0].StudentID))
+	if len(updated) != 1 || updated[0].StudentID != inserted[0].StudentID || updated[0].Age != 31 {
+		t.Fatal("unexpected updated records:", updated)
+	}
+}
+
+func TestDataWriterUpdateWhere(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	writer := NewDataWriter[Student](
+		nil,
+		&w3req.UpdateConfig{
+			AllSQL:      w3sql.NewSQLString("update students"),
+			IDFieldName: "studentID",
+			FieldMap:    compileMap,
+			SQLDialect:  "sqlite",
+			Table:       "students",
+			MaxRows:     3,
+		},
+		func() {},
+	)
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			Begin: func() (w3req.Tx, error) {
+				tx, err := db.DbMap.Begin()
+				return namedTx{tx}, err
+			},
+		}
+	})
+	handler := writer.GetHttpRequestHandler()
+
+	write := func(body string) map[string]any {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return resp
+	}
+
+	resp := write(`{
+		"UpdateWhere": {"Cols": ["grade"], "Values": [100], "Confirm": true},
+		"Search": {"Col": "age", "Val": 0, "Op": ">", "Type": "int"}
+	}`)
+	if resp["status"] != "error" || resp["message"] != MAX_ROWS {
+		t.Fatal("expected max rows error, got", resp)
+	}
+
+	resp = write(`{
+		"UpdateWhere": {"Cols": ["grade"], "Values": [100], "Confirm": true},
+		"Search": {"Col": "age", "Val": 20, "Op": ">", "Type": "int"}
+	}`)
+	if resp["status"] != "success" {
+		t.Fatal("unexpected response", resp)
+	}
+	n, err := db.SelectInt("select count(*) from students where score = 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp["total"] != float64(2) || n != 2 {
+		t.Fatal("unexpected total", resp["total"], "updated", n)
+	}
+
+	resp = write(`{"UpdateWhere": {"Cols": ["grade"], "Values": [100], "Confirm": true}}`)
+	if resp["status"] != "error" {
+		t.Fatal("update without Search should fail, got", resp)
+	}
+	resp = write(`{
+		"UpdateWhere": {"Cols": ["grade"], "Values": [100], "Confirm": true},
+		"Search": {"Op": "AND", "Query": [{"Op": "OR", "Query": []}]}
+	}`)
+	if resp["status"] != "error" {
+		t.Fatal("update with empty Search should fail, got", resp)
+	}
+
+	// без Table строки не считаются заранее, лишнее обновление откатывается по числу строк
+	writer = NewDataWriter[Student](
+		nil,
+		&w3req.UpdateConfig{
+			AllSQL:      w3sql.NewSQLString("update students"),
+			IDFieldName: "studentID",
+			FieldMap:    compileMap,
+			SQLDialect:  "sqlite",
+			MaxRows:     1,
+		},
+		func() {},
+	)
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			Begin: func() (w3req.Tx, error) {
+				tx, err := db.DbMap.Begin()
+				return namedTx{tx}, err
+			},
+		}
+	})
+	handler = writer.GetHttpRequestHandler()
+	resp = write(`{
+		"UpdateWhere": {"Cols": ["grade"], "Values": [0], "Confirm": true},
+		"Search": {"Col": "age", "Val": 20, "Op": ">", "Type": "int"}
+	}`)
+	if resp["status"] != "error" || resp["message"] != MAX_ROWS {
+		t.Fatal("expected max rows error, got", resp)
+	}
+	n, err = db.SelectInt("select count(*) from students where score = 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatal("update over MaxRows should be rolled back, got", n)
+	}
+}
+
+func TestDataWriterUpdateConflict(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+	if _, err := db.Exec("alter table students add column version int not null default 0"); err != nil {
+		t.Fatal(err)
+	}
+
+	fieldMap := map[string]string{"id": "studentID", "age": "", "version": ""}
+	writer := NewDataWriter[Student](
+		nil,
+		&w3req.UpdateConfig{
+			AllSQL:      w3sql.NewSQLString("update students"),
+			IDFieldName: "studentID",
+			FieldMap:    fieldMap,
+			SQLDialect:  "sqlite",
+			VersionCol:  "version",
+		},
+		func() {},
+	)
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			Begin: func() (w3req.Tx, error) {
+				tx, err := db.DbMap.Begin()
+				return namedTx{tx}, err
+			},
+		}
+	})
+	handler := writer.GetHttpRequestHandler()
+
+	update := func(values string) map[string]any {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Update": {
+			"Cols": ["age", "version", "id"],
+			"Values": `+values+`
+		}}`))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return resp
+	}
+
+	if resp := update(`[[40, 0, 1]]`); resp["status"] != "success" {
+		t.Fatal("first update should succeed, got", resp)
+	}
+	if v, _ := db.SelectInt("select version from students where studentID = 1"); v != 1 {
+		t.Fatal("version should be incremented, got", v)
+	}
+
+	resp := update(`[[40, 0, 1]]`)
+	if resp["status"] != "error" || resp["message"] != UPDATE_CONFLICT {
+		t.Fatal("expected update conflict, got", resp)
+	}
+	if resp["errcode"] != float64(globalConfig.ErrorCodes.code(UPDATE_CONFLICT)) {
+		t.Fatal("unexpected error code", resp["errcode"])
+	}
+
+	// вторая строка обновилась бы, но конфликт первой откатывает обе
+	resp = update(`[[50, 0, 2], [50, 0, 1]]`)
+	if resp["status"] != "error" || resp["message"] != UPDATE_CONFLICT {
+		t.Fatal("expected update conflict, got", resp)
+	}
+	if age, _ := db.SelectInt("select age from students where studentID = 2"); age != 21 {
+		t.Fatal("update should be rolled back, got age", age)
+	}
+}
+
+func TestDataWriterUpdateRows(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	writer := NewDataWriter[Student](
+		nil,
+		&w3req.UpdateConfig{
+			AllSQL:      w3sql.NewSQLString("update students"),
+			IDFieldName: "studentID",
+			FieldMap:    compileMap,
+			SQLDialect:  "sqlite",
+			Returning:   []string{"*"},
+		},
+		func() {},
+	)
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			Begin: func() (w3req.Tx, error) {
+				tx, err := db.DbMap.Begin()
+				return namedTx{tx}, err
+			},
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Update": {"Rows": [
+		{"id": 1, "age": 30},
+		{"id": 2, "firstName": "pavel"},
+		{"id": 3, "age": 31}
+	]}}`))
+	w := httptest.NewRecorder()
+	writer.GetHttpRequestHandler()(w, req)
+
+	var resp struct {
+		Status  string
+		Total   int64
+		Records []map[string]any
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if resp.Status != "success" || resp.Total != 3 || len(resp.Records) != 3 {
+		t.Fatal("unexpected response:", w.Body.String())
+	}
+
+	var students []Student
+	if _, err := db.Select(&students, "select * from students order by studentID"); err != nil {
+		t.Fatal(err)
+	}
+	// не присланные поля не изменились
+	if students[0].Age != 30 || students[0].FirstName != "vanya" ||
+		students[1].Age != 21 || students[1].FirstName != "pavel" ||
+		students[2].Age != 31 || students[2].FirstName != "lena" {
+		t.Fatal("unexpected rows after partial update:", students)
+	}
+
+	// строки только с ID ничего не обновляют
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Update": {"Rows": [{"id": 1}]}}`))
+	w = httptest.NewRecorder()
+	writer.GetHttpRequestHandler()(w, req)
+	resp.Records = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if resp.Status != "success" || resp.Total != 0 || len(resp.Records) != 0 {
+		t.Fatal("unexpected response for empty update:", w.Body.String())
+	}
+}
+
+func TestDataWriterServerColumns(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+	if _, err := db.Exec("alter table students add column created_by text"); err != nil {
+		t.Fatal(err)
+	}
+
+	serverColumns := []w3sql.ServerColumn{{
+		Col: "created_by",
+		Value: func(params map[string]any) (any, error) {
+			return params["user"], nil
+		},
+	}}
+	fieldMap := map[string]string{"firstName": "", "age": "", "created_by": ""}
+	writer := NewDataWriter[Student](
+		&w3req.InsertConfig{
+			AllSQL:        w3sql.NewSQLString("insert into students"),
+			FieldMap:      fieldMap,
+			SQLDialect:    "sqlite",
+			ServerColumns: serverColumns,
+		},
+		&w3req.UpdateConfig{
+			AllSQL:        w3sql.NewSQLString("update students"),
+			FieldMap:      fieldMap,
+			SQLDialect:    "sqlite",
+			ServerColumns: serverColumns,
+		},
+		func() {},
+	)
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			RequestParams: func(req any) map[string]any {
+				return map[string]any{"user": req.(*http.Request).Header.Get("X-User")}
+			},
+		}
+	})
+	handler := writer.GetHttpRequestHandler()
+
+	write := func(body string) map[string]any {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("X-User", "admin")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return resp
+	}
+
+	resp := write(`{"Insert": {"Cols": ["firstName"], "Values": [["ivan"]]}, "Params": {"user": "hacker"}}`)
+	if resp["status"] != "success" {
+		t.Fatal("unexpected response", resp)
+	}
+	var createdBy string
+	if err := db.SelectOne(&createdBy, "select created_by from students where firstName = 'ivan'"); err != nil {
+		t.Fatal(err)
+	}
+	if createdBy != "admin" {
+		t.Fatal("server column should come from request, got", createdBy)
+	}
+
+	resp = write(`{"Insert": {"Cols": ["firstName", "created_by"], "Values": [["petr", "hacker"]]}}`)
+	if resp["message"] != FORBIDDEN_FIELD {
+		t.Fatal("expected forbidden field error, got", resp)
+	}
+
+	resp = write(`{
+		"UpdateWhere": {"Cols": ["age"], "Values": [40], "Confirm": true},
+		"Search": {"Col": "firstName", "Val": "vanya", "Op": "is", "Type": "text"},
+		"Params": {"user": "hacker"}
+	}`)
+	if resp["status"] != "success" {
+		t.Fatal("unexpected response", resp)
+	}
+	if err := db.SelectOne(&createdBy, "select created_by from students where firstName = 'vanya'"); err != nil {
+		t.Fatal(err)
+	}
+	if createdBy != "admin" {
+		t.Fatal("server column should be set by update where, got", createdBy)
+	}
+
+	resp = write(`{
+		"UpdateWhere": {"Cols": ["created_by"], "Values": ["hacker"], "Confirm": true},
+		"Search": {"Col": "firstName", "Val": "vanya", "Op": "is", "Type": "text"}
+	}`)
+	if resp["message"] != FORBIDDEN_FIELD {
+		t.Fatal("expected forbidden field error, got", resp)
+	}
+}
+
+func TestDataWriterRowValidation(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	writer := NewDataWriter[Student](
+		&w3req.InsertConfig{
+			AllSQL:     w3sql.NewSQLString("insert into students"),
+			FieldMap:   compileMap,
+			SQLDialect: "sqlite",
+			Rules: w3sql.WriteRules{
+				"firstName": {Required: true},
+				"age":       {Check: func(v any) error { return errors.New("age is read only") }},
+			},
+		},
+		nil,
+		func() {},
+	)
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Insert": {
+		"Cols": ["firstName", "age"],
+		"Values": [["ivan", null], ["", 20]]
+	}}`))
+	w := httptest.NewRecorder()
+	writer.GetHttpRequestHandler()(w, req)
+
+	var resp struct {
+		Status  string
+		Message string
+		Details struct {
+			Kind string
+			Rows []w3sql.RowError
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if resp.Status != "error" || resp.Message != ROW_VALIDATION || resp.Details.Kind != "row_validation" {
+		t.Fatal("unexpected response:", w.Body.String())
+	}
+	if len(resp.Details.Rows) != 2 ||
+		resp.Details.Rows[0] != (w3sql.RowError{Row: 1, Column: "firstName", Message: "value is required", Path: "Insert.Values[1][0]"}) ||
+		resp.Details.Rows[1].Message != "age is read only" {
+		t.Fatal("unexpected row errors:", resp.Details.Rows)
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 4 {
+		t.Fatal("nothing should be inserted, got", n)
+	}
+}
+
+type chunkLogger struct {
+	chunks int
+}
+
+func (l *chunkLogger) Print(s string) string { return s }
+
+func (l *chunkLogger) Printf(format string, arg any, args ...any) string {
+	if strings.HasPrefix(format, "Insert chunk") {
+		l.chunks++
+	}
+	return format
+}
+
+func TestDataWriterChunkedInsert(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+
+	writer := NewDataWriter[Student](
+		&w3req.InsertConfig{
+			AllSQL:     w3sql.NewSQLString("insert into students"),
+			FieldMap:   compileMap,
+			SQLDialect: "sqlite",
+			ChunkRows:  3,
+		},
+		nil,
+		func() {},
+	)
+	log := &chunkLogger{}
+	writer.InitOnce(func() WriterOptions[Student] {
+		writer.SetDebugLog(log)
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			Begin: func() (w3req.Tx, error) {
+				tx, err := db.DbMap.Begin()
+				return namedTx{tx}, err
+			},
+		}
+	})
+	handler := writer.GetHttpRequestHandler()
+
+	insert := func(values []string) map[string]any {
+		body := `{"Insert": {"Cols": ["firstName", "age"], "Values": [` + strings.Join(values, ",") + `]}}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return resp
+	}
+
+	values := make([]string, 10)
+	for i := range values {
+		values[i] = fmt.Sprintf(`["s%d", %d]`, i, 20+i)
+	}
+	if resp := insert(values); resp["status"] != "success" {
+		t.Fatal("unexpected response", resp)
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 14 {
+		t.Fatal("expected 14 students, got", n)
+	}
+	if log.chunks != 4 {
+		t.Fatal("expected progress for 4 chunks, got", log.chunks)
+	}
+
+	// ошибка в последней части откатывает всю вставку
+	values[9] = `["bad", [1, 2]]`
+	if resp := insert(values); resp["status"] != "error" {
+		t.Fatal("expected error, got", resp)
+	}
+	if log.chunks != 7 {
+		t.Fatal("first 3 chunks should be inserted before error, got", log.chunks-4)
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 14 {
+		t.Fatal("failed insert should be rolled back, got", n)
+	}
+}
+
+func TestDataWriterChunkedInsertTx(t *testing.T) {
+	db := &preparingDB{DbMap: openStudents(t)}
+
+	writer := func(begin func() (w3req.Tx, error)) http.HandlerFunc {
+		w := NewDataWriter[Student](
+			&w3req.InsertConfig{
+				AllSQL:        w3sql.NewSQLString("insert into students"),
+				FieldMap:      compileMap,
+				SQLDialect:    "sqlite",
+				ChunkRows:     3,
+				StmtCacheSize: 10,
+			},
+			nil,
+			func() {},
+		)
+		w.InitOnce(func() WriterOptions[Student] {
+			return WriterOptions[Student]{
+				GetDB: func() w3req.DB { return db },
+				Begin: begin,
+			}
+		})
+		return w.GetHttpRequestHandler()
+	}
+	body := `{"Insert": {"Cols": ["firstName", "age"], "Values": [["a", 1], ["b", 2], ["c", 3], ["d", 4]]}}`
+	insert := func(handler http.HandlerFunc) map[string]any {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return resp
+	}
+
+	// без транзакции вставка частями не выполняется
+	if status := insert(writer(nil))["status"]; status != "error" {
+		t.Fatal("error expected without Begin, got", status)
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 4 {
+		t.Fatal("nothing should be inserted without Begin, got", n)
+	}
+
+	// в транзакции запросы не готовятся и не попадают в кеш
+	resp := insert(writer(func() (w3req.Tx, error) {
+		tx, err := db.DbMap.Begin()
+		return namedTx{tx}, err
+	}))
+	// без Returning total - число вставленных строк
+	if resp["status"] != "success" || resp["total"] != float64(4) {
+		t.Fatal("success with total 4 expected, got", resp)
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 8 {
+		t.Fatal("expected 8 students, got", n)
+	}
+	if len(db.prepared) != 0 {
+		t.Fatal("no prepared statements expected in transaction, got", db.prepared)
+	}
 }
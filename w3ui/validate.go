@@ -0,0 +1,97 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+type validationError struct {
+	ErrCode int    `json:"errcode"`
+	Message string `json:"message"`
+	*ErrorDetails
+}
+
+type validationSQL struct {
+	Code   string         `json:"code"`
+	Params map[string]any `json:"params"`
+}
+
+type validationW2UI struct {
+	Status string            `json:"status"`
+	Valid  bool              `json:"valid"`
+	Errors []validationError `json:"errors,omitempty"`
+	SQL    []validationSQL   `json:"sql,omitempty"`
+}
+
+// проверяет запрос и отвечает отчетом с ошибками и SQL, который был бы выполнен, база не используется
+func (d *DataRequester[T]) GetValidateRequestHandlerInner(
+	w http.ResponseWriter,
+	req any,
+	limit int,
+	appendQuery *Query,
+) {
+	defer d.onPanic()
+
+	out := newResponder(w, req)
+	q, ok := d.logger.readTypedQuery(req, d.maxBodySize, d.fieldTypes, appendQuery, out)
+	if !ok {
+		return
+	}
+
+	if q.Limit == nil || *q.Limit > limit || *q.Limit == 0 {
+		q.Limit = &limit
+	}
+
+	rr := validationW2UI{Status: "success"}
+
+	for _, err := range d.sel.Validate((*w3sql.Query)(q)) {
+		text, details, ok := QueryErrorDetails(err)
+		if !ok {
+			text = INVALID_PARAMETERS
+		}
+		if d.logger.outputOriginalError {
+			text += ": " + err.Error()
+		}
+		rr.Errors = append(rr.Errors, validationError{
+			ErrCode:      globalConfig.ErrorCodes.code(text),
+			Message:      text,
+			ErrorDetails: details,
+		})
+	}
+
+	if len(rr.Errors) == 0 {
+		sqls, err := d.sel.Compile((*w3sql.Query)(q))
+		if err != nil {
+			d.logger.logHandleError(err, out)
+			return
+		}
+		rr.Valid = true
+		rr.SQL = make([]validationSQL, len(sqls))
+		for i, s := range sqls {
+			rr.SQL[i] = validationSQL{
+				Code:   s.Code,
+				Params: s.Params,
+			}
+		}
+	}
+
+	buf, _ := json.Marshal(&rr)
+	out.successout(buf)
+}
+
+// fasthttp
+func (d *DataRequester[T]) GetValidateFasthttpRequestHandler(limit int, appendQuery *Query) fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		d.GetValidateRequestHandlerInner(nil, ctx, limit, appendQuery)
+	})
+}
+
+// net/http
+func (d *DataRequester[T]) GetValidateHttpRequestHandler(limit int, appendQuery *Query) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.GetValidateRequestHandlerInner(w, r, limit, appendQuery)
+	})
+}
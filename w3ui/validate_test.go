@@ -0,0 +1,93 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/algebrain/w3/w3sql"
+)
+
+func TestValidateHandler(t *testing.T) {
+	// база не нужна: InitOnce не вызывается
+	requester := NewDataRequester3[Student](allSQL, compileMap, toLowerCols, func() {})
+	handler := requester.GetValidateHttpRequestHandler(100, MustReadJSON(cond1))
+
+	var answer struct {
+		Status string `json:"status"`
+		Valid  bool   `json:"valid"`
+		Errors []struct {
+			ErrCode int    `json:"errcode"`
+			Message string `json:"message"`
+			Kind    string `json:"kind"`
+			Field   string `json:"field"`
+			Path    string `json:"path"`
+		} `json:"errors"`
+		SQL []struct {
+			Code   string         `json:"code"`
+			Params map[string]any `json:"params"`
+		} `json:"sql"`
+	}
+
+	bad := `{
+		"Search": {
+			"Op": "AND",
+			"Query": [
+				{"Col": "nickName", "Val": "vanya", "Op": "==", "Type": "text"},
+				{"Col": "age", "Val": 20, "Op": "~", "Type": "int"}
+			]
+		}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(bad))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	err := json.NewDecoder(w.Result().Body).Decode(&answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("ANSWER:", GetJSON(answer))
+
+	if answer.Valid || len(answer.Errors) != 2 || len(answer.SQL) != 0 {
+		t.Fatal("2 errors expected")
+	}
+	if e := answer.Errors[0]; e.Message != UNKNOWN_FIELD || e.Field != "nickName" || e.Path != "Search.Query[1].Query[0]" {
+		t.Fatal("unexpected error", GetJSON(e))
+	}
+	if e := answer.Errors[1]; e.ErrCode != globalConfig.ErrorCodes[UNSUPPORTED_OPERATOR] || e.Kind != "unsupported_operator" {
+		t.Fatal("unexpected error", GetJSON(e))
+	}
+
+	answer.Errors = nil
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(cond2))
+	w = httptest.NewRecorder()
+	handler(w, req)
+	err = json.NewDecoder(w.Result().Body).Decode(&answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("ANSWER:", GetJSON(answer))
+
+	if !answer.Valid || len(answer.Errors) != 0 || len(answer.SQL) != 1 {
+		t.Fatal("valid query with 1 sql expected")
+	}
+	good := `select * from students
+where ((age>:sqv0) OR (score=:sqv1))
+order by score ASC, age DESC
+limit 100`
+	if !w3sql.EqualSQLStrings(answer.SQL[0].Code, good) {
+		t.Fatal("unexpected sql", answer.SQL[0].Code)
+	}
+	if len(answer.SQL[0].Params) != 2 {
+		t.Fatal("2 parameters expected, got", answer.SQL[0].Params)
+	}
+}
+
+func TestSyntaxErrorDetails(t *testing.T) {
+	_, err := w3sql.ParseFilter(`age > 20 and`)
+	text, details, ok := QueryErrorDetails(err)
+	if !ok || text != SYNTAX_ERROR || details.Kind != "syntax_error" || details.Pos == nil || *details.Pos != 12 {
+		t.Fatal("unexpected details:", text, GetJSON(details))
+	}
+}
@@ -0,0 +1,369 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+// SavedView сохраненный фильтр таблицы: условия и сортировка запроса и, если заданы, колонки
+type SavedView struct {
+	Name    string       `json:"name"`
+	Query   *w3sql.Query `json:"query,omitempty"`
+	Columns []string     `json:"columns,omitempty"`
+}
+
+// Merge накладывает на сохраненный фильтр запрос q: условия объединяются через AND,
+// сортировка q, если задана, заменяет сохраненную, остальное берется из q
+func (v *SavedView) Merge(q *Query) *Query {
+	result := *q
+	if v.Query == nil {
+		return &result
+	}
+	switch {
+	case v.Query.Search != nil && q.Search != nil:
+		result.Search = w3sql.And(v.Query.Search, q.Search)
+	case q.Search == nil:
+		result.Search = v.Query.Search
+	}
+	if len(q.Sort) == 0 {
+		result.Sort = v.Query.Sort
+	}
+	return &result
+}
+
+var ErrViewNotFound = errors.New("w3ui: view not found")
+
+const maxViewNameLength = 100
+
+type ViewStoreConfig struct {
+	Table      string                        //таблица фильтров, по умолчанию w3_views, создается через CreateTable
+	SQLDialect string                        //sqlite или postgres
+	GetUser    func(req any) (string, error) //пользователь запроса, фильтры хранятся отдельно для каждого
+}
+
+type ViewStoreOptions struct {
+	GetDB    func() w3req.DB
+	ErrorLog ExtLogger
+}
+
+// ViewStore хранит именованные фильтры по пользователям и таблицам
+type ViewStore struct {
+	cfg         *ViewStoreConfig
+	opt         *ViewStoreOptions
+	conn        w3req.DB
+	logger      *Logger
+	onPanic     func()
+	mut         sync.Mutex
+	initOnce    sync.Once
+	maxBodySize int64
+}
+
+func NewViewStore(cfg *ViewStoreConfig, onPanic func()) *ViewStore {
+	if onPanic == nil {
+		panic("[w3ui.NewViewStore] ERROR: onPanic should not be nil")
+	}
+	if cfg.GetUser == nil {
+		panic("[w3ui.NewViewStore] ERROR: GetUser should not be nil")
+	}
+	if cfg.Table == "" {
+		cfg.Table = "w3_views"
+	}
+	return &ViewStore{
+		cfg:     cfg,
+		logger:  &Logger{},
+		onPanic: onPanic,
+	}
+}
+
+func (s *ViewStore) InitOnce(f func() ViewStoreOptions) *ViewStore {
+	s.initOnce.Do(func() {
+		opt := f()
+		if opt.GetDB == nil {
+			panic("[w3ui.ViewStore.InitOnce] GetDB is mandatory")
+		}
+		s.opt = &opt
+		s.logger.setErrorLogger(opt.ErrorLog)
+	})
+	return s
+}
+
+// если указан, то будет журналировать все запросы
+// вызывать внутри InitOnce
+func (s *ViewStore) SetDebugLog(log ExtLogger) *ViewStore {
+	s.logger.setDebugLogger(log)
+	return s
+}
+
+// ограничивает размер тела запроса, 0 - без ограничения
+func (s *ViewStore) SetMaxBodySize(size int64) *ViewStore {
+	s.maxBodySize = size
+	return s
+}
+
+// если включен, то вместо "Invalid Parameters" будет возвращать настоящую ошибку
+func (s *ViewStore) OutputOriginalErrorText() *ViewStore {
+	s.logger.outputOriginalError = true
+	return s
+}
+
+// ViewsSchema SQL создания таблицы фильтров
+func ViewsSchema(dialect string, table string) (string, error) {
+	switch dialect {
+	case "sqlite":
+		return fmt.Sprintf(`create table if not exists %s (
+	id integer primary key,
+	user_id text not null,
+	grid text not null,
+	name text not null,
+	query text not null,
+	updated_at timestamp not null default current_timestamp,
+	unique (user_id, grid, name)
+)`, table), nil
+	case "postgres":
+		return fmt.Sprintf(`create table if not exists %s (
+	id bigserial primary key,
+	user_id text not null,
+	grid text not null,
+	name text not null,
+	query text not null,
+	updated_at timestamptz not null default now(),
+	unique (user_id, grid, name)
+)`, table), nil
+	}
+	return "", errors.New("w3ui: views are not supported for '" + dialect + "'")
+}
+
+func (s *ViewStore) connect() w3req.DB {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.conn == nil {
+		s.conn = s.opt.GetDB()
+	}
+	if s.conn == nil {
+		panic("[w3ui.ViewStore.connect]: DB is nil")
+	}
+	return s.conn
+}
+
+func (s *ViewStore) exec(code string, params map[string]any) (int64, error) {
+	s.logger.LogSQL("Views SQL:", code, params)
+	res, err := s.connect().Exec(code, params)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CreateTable создает таблицу фильтров, если ее нет
+func (s *ViewStore) CreateTable() error {
+	code, err := ViewsSchema(s.cfg.SQLDialect, s.cfg.Table)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(code, map[string]any{})
+	return err
+}
+
+type viewRow struct {
+	Name  string `db:"name"`
+	Query string `db:"query"`
+}
+
+func (r *viewRow) view() (*SavedView, error) {
+	v := &SavedView{}
+	if err := json.Unmarshal([]byte(r.Query), v); err != nil {
+		return nil, err
+	}
+	v.Name = r.Name
+	return v, nil
+}
+
+// Save сохраняет фильтр, существующий с тем же именем заменяется; из запроса берутся только Search и Sort
+func (s *ViewStore) Save(user string, grid string, v *SavedView) error {
+	if v.Name == "" || len([]rune(v.Name)) > maxViewNameLength {
+		return fmt.Errorf("w3ui: view name should be 1 to %d characters", maxViewNameLength)
+	}
+	stored := SavedView{Columns: v.Columns}
+	if v.Query != nil {
+		stored.Query = &w3sql.Query{Search: v.Query.Search, Sort: v.Query.Sort}
+	}
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(fmt.Sprintf(`insert into %s (user_id, grid, name, query, updated_at)
+values (:user, :grid, :name, :query, current_timestamp)
+on conflict (user_id, grid, name) do update set query = excluded.query, updated_at = current_timestamp`, s.cfg.Table),
+		map[string]any{"user": user, "grid": grid, "name": v.Name, "query": string(data)},
+	)
+	return err
+}
+
+func (s *ViewStore) selectViews(user string, grid string, name string) ([]viewRow, error) {
+	code := fmt.Sprintf("select name, query from %s where user_id = :user and grid = :grid", s.cfg.Table)
+	params := map[string]any{"user": user, "grid": grid}
+	if name != "" {
+		code += " and name = :name"
+		params["name"] = name
+	}
+	code += " order by name"
+
+	s.logger.LogSQL("Views SQL:", code, params)
+	var rows []viewRow
+	if _, err := s.connect().Select(&rows, code, params); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// List возвращает все фильтры пользователя для таблицы по алфавиту
+func (s *ViewStore) List(user string, grid string) ([]*SavedView, error) {
+	rows, err := s.selectViews(user, grid, "")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*SavedView, 0, len(rows))
+	for i := range rows {
+		v, err := rows[i].view()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// Load возвращает фильтр по имени или ErrViewNotFound
+func (s *ViewStore) Load(user string, grid string, name string) (*SavedView, error) {
+	if name == "" {
+		return nil, ErrViewNotFound
+	}
+	rows, err := s.selectViews(user, grid, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrViewNotFound
+	}
+	return rows[0].view()
+}
+
+// Delete удаляет фильтр по имени или возвращает ErrViewNotFound
+func (s *ViewStore) Delete(user string, grid string, name string) error {
+	n, err := s.exec(
+		fmt.Sprintf("delete from %s where user_id = :user and grid = :grid and name = :name", s.cfg.Table),
+		map[string]any{"user": user, "grid": grid, "name": name},
+	)
+	if err == nil && n == 0 {
+		err = ErrViewNotFound
+	}
+	return err
+}
+
+// запрос к обработчику фильтров, cmd - save, list, load или delete
+type viewRequest struct {
+	Cmd string `json:"cmd"`
+	SavedView
+}
+
+type viewAnswer struct {
+	Status  string       `json:"status"`
+	Record  *SavedView   `json:"record,omitempty"`
+	Records []*SavedView `json:"records,omitempty"`
+}
+
+func (s *ViewStore) GetRequestHandlerInner(w http.ResponseWriter, req any, grid string) {
+	defer s.onPanic()
+
+	out := newResponder(w, req)
+	user, err := s.cfg.GetUser(req)
+	if err != nil {
+		s.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	body, err := readBody(req, s.maxBodySize)
+	if errors.Is(err, ErrBodyTooLarge) {
+		s.logger.LogError(BODY_TOO_LARGE, err, out.errout)
+		return
+	}
+	var vr viewRequest
+	if err == nil {
+		err = json.Unmarshal(body, &vr)
+	}
+	if err != nil {
+		s.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	answer := viewAnswer{Status: "success"}
+	switch vr.Cmd {
+	case "save":
+		err = s.Save(user, grid, &vr.SavedView)
+	case "list":
+		answer.Records, err = s.List(user, grid)
+	case "load":
+		answer.Record, err = s.Load(user, grid, vr.Name)
+	case "delete":
+		err = s.Delete(user, grid, vr.Name)
+	default:
+		err = fmt.Errorf("w3ui: unknown views command '%s'", vr.Cmd)
+		s.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+	if errors.Is(err, ErrViewNotFound) {
+		s.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	} else if err != nil {
+		s.logger.LogError(SYSTEM_ERROR, err, out.errout)
+		return
+	}
+
+	buf, _ := json.Marshal(&answer)
+	out.successout(buf)
+}
+
+// fasthttp
+func (s *ViewStore) GetFasthttpRequestHandler(grid string) fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		s.GetRequestHandlerInner(nil, ctx, grid)
+	})
+}
+
+// net/http
+func (s *ViewStore) GetHttpRequestHandler(grid string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.GetRequestHandlerInner(w, r, grid)
+	})
+}
+
+// viewQuery накладывает на запрос фильтр, имя которого передано параметром view строки запроса
+func (s *ViewStore) viewQuery(req any, grid string, q *Query) (*Query, error) {
+	var name string
+	switch t := req.(type) {
+	case *http.Request:
+		name = t.URL.Query().Get("view")
+	case *fasthttp.RequestCtx:
+		name = string(t.QueryArgs().Peek("view"))
+	}
+	if name == "" {
+		return q, nil
+	}
+	user, err := s.cfg.GetUser(req)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.Load(user, grid, name)
+	if err != nil {
+		return nil, err
+	}
+	return v.Merge(q), nil
+}
@@ -0,0 +1,141 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+)
+
+func newTestViewStore(t *testing.T, db w3req.DB) *ViewStore {
+	store := NewViewStore(&ViewStoreConfig{
+		SQLDialect: "sqlite",
+		GetUser: func(req any) (string, error) {
+			user := req.(*http.Request).Header.Get("X-User")
+			if user == "" {
+				return "", errors.New("no user")
+			}
+			return user, nil
+		},
+	}, func() {})
+	store.InitOnce(func() ViewStoreOptions {
+		return ViewStoreOptions{GetDB: func() w3req.DB { return db }}
+	}).OutputOriginalErrorText()
+	if err := store.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func postView(handler http.HandlerFunc, user string, body string) map[string]any {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	var resp map[string]any
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestViewStore(t *testing.T) {
+	store := newTestViewStore(t, &countingDB{DbMap: openStudents(t)})
+	handler := store.GetHttpRequestHandler("students")
+
+	save := `{"cmd": "save", "name": "adults", "columns": ["firstName", "age"], "query": {
+		"Search": {"Col": "age", "Val": 20, "Op": ">", "Type": "int"},
+		"Sort": [{"Col": "age", "Dir": "asc"}],
+		"Limit": 5
+	}}`
+	if resp := postView(handler, "ivan", save); resp["status"] != "success" {
+		t.Fatal("unexpected response:", resp)
+	}
+	// то же имя заменяет фильтр
+	if resp := postView(handler, "ivan", save); resp["status"] != "success" {
+		t.Fatal("unexpected response:", resp)
+	}
+	postView(handler, "ivan", `{"cmd": "save", "name": "all"}`)
+	postView(handler, "olga", `{"cmd": "save", "name": "mine"}`)
+
+	resp := postView(handler, "ivan", `{"cmd": "list"}`)
+	records, _ := resp["records"].([]any)
+	if len(records) != 2 || records[0].(map[string]any)["name"] != "adults" || records[1].(map[string]any)["name"] != "all" {
+		t.Fatal("unexpected list:", resp)
+	}
+
+	v, err := store.Load("ivan", "students", "adults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Query.Limit != nil || len(v.Query.Sort) != 1 || strings.Join(v.Columns, ",") != "firstName,age" {
+		t.Fatal("only search, sort and columns should be saved:", GetJSON(v))
+	}
+	if _, err := store.Load("olga", "students", "adults"); !errors.Is(err, ErrViewNotFound) {
+		t.Fatal("views should be separated by user, got", err)
+	}
+	if _, err := store.Load("ivan", "teachers", "adults"); !errors.Is(err, ErrViewNotFound) {
+		t.Fatal("views should be separated by grid, got", err)
+	}
+
+	if resp := postView(handler, "ivan", `{"cmd": "delete", "name": "all"}`); resp["status"] != "success" {
+		t.Fatal("unexpected response:", resp)
+	}
+	if resp := postView(handler, "ivan", `{"cmd": "delete", "name": "all"}`); resp["status"] != "error" {
+		t.Fatal("second delete should fail:", resp)
+	}
+	if resp := postView(handler, "", `{"cmd": "list"}`); resp["status"] != "error" {
+		t.Fatal("request without user should fail:", resp)
+	}
+}
+
+func TestViewMerge(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+	store := newTestViewStore(t, db)
+	err := store.Save("ivan", "students", &SavedView{
+		Name:  "young",
+		Query: (*w3sql.Query)(MustReadJSON(`{"Search": {"Col": "age", "Val": 21, "Op": "<", "Type": "int"}, "Sort": [{"Col": "age", "Dir": "desc"}]}`)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewDataRequester3[Student](allSQL, compileMap, toLowerCols, func() {})
+	r.InitOnce(func() RequesterOptions[Student] {
+		return RequesterOptions[Student]{GetDB: func() w3req.DB { return db }}
+	}).SetViews(store, "students")
+	handler := r.GetHttpRequestHandler(100, nil)
+
+	names := func(url string, body string) string {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.Header.Set("X-User", "ivan")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var resp struct {
+			Status  string
+			Records []Student
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Status != "success" {
+			t.Fatal("unexpected response:", w.Body.String())
+		}
+		var result []string
+		for _, s := range resp.Records {
+			result = append(result, s.FirstName)
+		}
+		return strings.Join(result, ",")
+	}
+
+	if s := names("/?view=young", `{}`); s != "lena,masha" {
+		t.Fatal("unexpected records:", s)
+	}
+	// условия запроса добавляются через AND, сортировка запроса заменяет сохраненную
+	if s := names("/?view=young", `{"Search": {"Col": "grade", "Val": 70, "Op": ">", "Type": "int"}, "Sort": [{"Col": "age", "Dir": "asc"}]}`); s != "lena" {
+		t.Fatal("unexpected records:", s)
+	}
+	if s := names("/?view=young", `{"Sort": [{"Col": "age", "Dir": "asc"}]}`); s != "masha,lena" {
+		t.Fatal("unexpected records:", s)
+	}
+}
@@ -0,0 +1,469 @@
+package w3ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+// Протокол стандартного грида w2ui: запрос приходит JSON в параметре request
+// (form в теле или строка URL для GET) или JSON в теле при dataType: 'JSON',
+// команды get, save и delete, ответы в том виде, который ожидает w2ui.
+
+type W2UIConfig struct {
+	IDField        string //поле фронта (ключ FieldMap) с ID записи, в него переносится recid изменений save
+	RecidKey       string //ключ записи в JSON ответа, значение которого копируется в recid, пусто - recid не добавляется
+	DateFormat     string //w2utils.settings.dateFormat в записи Go, по умолчанию "1/2/2006" (m/d/yyyy)
+	DateTimeFormat string //дата и время в записи Go, по умолчанию "1/2/2006 3:04 pm"
+}
+
+// W2UIGrid отвечает гриду w2ui через DataRequester, DataWriter (save) и w3req.DeleteRequester (delete),
+// writer и deleter могут быть nil, тогда соответствующая команда не принимается
+// save только обновляет существующие записи: записи, добавленные в гриде (recid пустой
+// или не положительный), отвергаются целиком, их нужно вставлять отдельным запросом InsertRequester
+type W2UIGrid[T any] struct {
+	cfg *W2UIConfig
+	sel *DataRequester[T]
+	wr  *DataWriter[T]
+	del w3req.DeleteRequester
+}
+
+func NewW2UIGrid[T any](
+	cfg *W2UIConfig,
+	sel *DataRequester[T],
+	wr *DataWriter[T],
+	del w3req.DeleteRequester,
+) *W2UIGrid[T] {
+	if sel == nil {
+		panic("[w3ui.NewW2UIGrid] ERROR: sel should not be nil")
+	}
+	if wr != nil && cfg.IDField == "" {
+		panic("[w3ui.NewW2UIGrid] ERROR: IDField is mandatory for save")
+	}
+	if cfg.DateFormat == "" {
+		cfg.DateFormat = "1/2/2006"
+	}
+	if cfg.DateTimeFormat == "" {
+		cfg.DateTimeFormat = "1/2/2006 3:04 pm"
+	}
+	return &W2UIGrid[T]{cfg: cfg, sel: sel, wr: wr, del: del}
+}
+
+type w2uiSearch struct {
+	Field    string `json:"field"`
+	Type     string `json:"type"`
+	Operator string `json:"operator"`
+	Value    any    `json:"value"`
+}
+
+type w2uiSort struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction"`
+}
+
+type w2uiRequest struct {
+	Cmd         string           `json:"cmd"`
+	Action      string           `json:"action"` //w2ui 2.0
+	Limit       *int             `json:"limit"`
+	Offset      *int             `json:"offset"`
+	SearchLogic string           `json:"searchLogic"`
+	Search      []w2uiSearch     `json:"search"`
+	Sort        []w2uiSort       `json:"sort"`
+	Changes     []map[string]any `json:"changes"`
+	Selected    []any            `json:"selected"`
+	Recid       []any            `json:"recid"` //w2ui 2.0
+}
+
+func (r *w2uiRequest) command() string {
+	cmd := r.Cmd
+	if cmd == "" {
+		cmd = r.Action
+	}
+	// w2ui 1.4
+	cmd = strings.TrimSuffix(cmd, "-records")
+	if cmd == "" {
+		cmd = "get"
+	}
+	return cmd
+}
+
+func readW2UIRequest(req any, maxBodySize int64) (*w2uiRequest, error) {
+	var data []byte
+	if raw, ok := getQueryString(req); ok {
+		v, err := url.ParseQuery(raw)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(v.Get("request"))
+	} else {
+		body, err := readBody(req, maxBodySize)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.TrimSpace(body)
+		if len(body) > 0 && body[0] == '{' {
+			data = body
+		} else {
+			v, err := url.ParseQuery(string(body))
+			if err != nil {
+				return nil, err
+			}
+			data = []byte(v.Get("request"))
+		}
+	}
+
+	r := &w2uiRequest{}
+	if len(data) == 0 {
+		return r, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// операторы w2ui, остальные передаются как есть и отвергаются компилятором
+var w2uiOps = map[string]string{
+	"is":       "==",
+	"begins":   "begins",
+	"contains": "contains",
+	"ends":     "ends",
+	"between":  "between",
+	"less":     "<=",
+	"more":     ">=",
+	"in":       "in",
+	"not in":   "not in",
+	"<":        "<",
+	"<=":       "<=",
+	">":        ">",
+	">=":       ">=",
+}
+
+// типы w2ui, для list и enum тип выводится из значения
+var w2uiTypes = map[string]string{
+	"text":         "text",
+	"alphanumeric": "text",
+	"hex":          "text",
+	"int":          "int",
+	"float":        "number",
+	"money":        "number",
+	"currency":     "number",
+	"percent":      "number",
+	"date":         "date",
+	"datetime":     "datetime",
+	"list":         "",
+	"enum":         "",
+}
+
+// w2uiItem значение элемента list и enum: объект {id, text} или само значение
+func w2uiItem(v any) any {
+	if m, ok := v.(map[string]any); ok {
+		return m["id"]
+	}
+	return v
+}
+
+func w2uiItemType(v any) string {
+	if n, ok := v.(json.Number); ok {
+		if strings.ContainsAny(n.String(), ".eE") {
+			return "number"
+		}
+		return "int"
+	}
+	return "text"
+}
+
+// w2uiTime переводит дату в формате w2ui в ISO, другие значения остаются как есть
+func w2uiTime(v any, layout string, iso string) any {
+	if list, ok := v.([]any); ok {
+		result := make([]any, len(list))
+		for i, e := range list {
+			result[i] = w2uiTime(e, layout, iso)
+		}
+		return result
+	}
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(iso)
+		}
+	}
+	return v
+}
+
+func (g *W2UIGrid[T]) condition(s *w2uiSearch) *w3sql.AtomaryCondition {
+	c := &w3sql.AtomaryCondition{Col: s.Field, Op: s.Operator, Type: s.Type, Val: s.Value}
+	if op, ok := w2uiOps[s.Operator]; ok {
+		c.Op = op
+	}
+	if tp, ok := w2uiTypes[s.Type]; ok {
+		c.Type = tp
+	}
+
+	switch c.Op {
+	case "in", "not in":
+		list, ok := c.Val.([]any)
+		if !ok {
+			list = []any{c.Val}
+		}
+		vals := make([]any, len(list))
+		for i, v := range list {
+			vals[i] = w2uiItem(v)
+		}
+		c.Val = vals
+		if c.Type == "" && len(vals) > 0 {
+			c.Type = w2uiItemType(vals[0])
+		}
+	default:
+		c.Val = w2uiItem(c.Val)
+		if c.Type == "" {
+			c.Type = w2uiItemType(c.Val)
+		}
+	}
+
+	switch c.Type {
+	case "date":
+		c.Val = w2uiTime(c.Val, g.cfg.DateFormat, "2006-01-02")
+	case "datetime":
+		c.Val = w2uiTime(c.Val, g.cfg.DateTimeFormat, "2006-01-02T15:04:05")
+	}
+	return c
+}
+
+// query запрос команды get
+func (g *W2UIGrid[T]) query(r *w2uiRequest) *Query {
+	q := &Query{Limit: r.Limit, Offset: r.Offset}
+	conds := make([]w3sql.RawCondition, len(r.Search))
+	for i := range r.Search {
+		conds[i] = g.condition(&r.Search[i])
+	}
+	switch len(conds) {
+	case 0:
+	case 1:
+		q.Search = conds[0]
+	default:
+		logic := strings.ToUpper(r.SearchLogic)
+		if logic != "OR" {
+			logic = "AND"
+		}
+		q.Search = &w3sql.CompoundCondition{Op: logic, Query: conds}
+	}
+	for _, s := range r.Sort {
+		q.Sort = append(q.Sort, w3sql.SortQuery{Col: s.Field, Dir: s.Direction})
+	}
+	return q
+}
+
+// records добавляет к записям recid
+func (g *W2UIGrid[T]) records(records []T) (any, error) {
+	if g.cfg.RecidKey == "" {
+		return records, nil
+	}
+	result := make([]map[string]json.RawMessage, len(records))
+	for i := range records {
+		b, err := json.Marshal(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		if id, ok := m[g.cfg.RecidKey]; ok {
+			m["recid"] = id
+		}
+		result[i] = m
+	}
+	return result, nil
+}
+
+func (g *W2UIGrid[T]) get(req any, r *w2uiRequest, limit int, appendQuery *Query, out *responder) {
+	q := g.query(r)
+	appendToQuery(q, appendQuery)
+	q, ok := g.sel.applyView(req, q, out)
+	if !ok {
+		return
+	}
+	if q.Limit == nil || *q.Limit > limit || *q.Limit == 0 {
+		q.Limit = &limit
+	}
+
+	records, total, err := g.sel.sel.Handle((*w3sql.Query)(q))
+	if err != nil {
+		g.sel.logger.logHandleError(err, out)
+		return
+	}
+	if g.sel.formatFields != nil && len(records) != 0 {
+		g.sel.formatFields(records)
+	}
+	if records == nil {
+		records = []T{}
+	}
+	result, err := g.records(records)
+	if err != nil {
+		g.sel.logger.LogError(SYSTEM_ERROR, err, out.errout)
+		return
+	}
+
+	buf, _ := json.Marshal(&allTableW2UI{Status: "success", Total: total, Records: result})
+	out.successout(buf)
+}
+
+// check проверяет, что команда принимается и в ней есть записи
+func (g *W2UIGrid[T]) check(cmd string, r *w2uiRequest) error {
+	switch cmd {
+	case "get":
+	case "save":
+		if g.wr == nil || g.wr.upd == nil {
+			return errors.New("w3ui: save is not allowed")
+		}
+		if len(r.Changes) == 0 {
+			return errors.New("w3ui: no changes to save")
+		}
+		for i, ch := range r.Changes {
+			if w2uiNewRecid(ch["recid"]) {
+				return fmt.Errorf("w3ui: save of new records is not supported, changes[%d] has recid %v", i, ch["recid"])
+			}
+		}
+	case "delete":
+		if g.del == nil {
+			return errors.New("w3ui: delete is not allowed")
+		}
+		if len(r.Recid) == 0 && len(r.Selected) == 0 {
+			return errors.New("w3ui: no records to delete")
+		}
+	default:
+		return fmt.Errorf("w3ui: unknown w2ui command '%s'", cmd)
+	}
+	return nil
+}
+
+// w2uiNewRecid true для записи, добавленной в гриде и еще не сохраненной:
+// recid не задан, пустой или не положительное число
+func w2uiNewRecid(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case json.Number:
+		f, err := t.Float64()
+		return err != nil || f <= 0
+	case string:
+		return t == ""
+	}
+	return false
+}
+
+// save только обновляет записи, новые записи отвергаются в check
+func (g *W2UIGrid[T]) save(req any, r *w2uiRequest) error {
+	rows := make([]map[string]any, len(r.Changes))
+	for i, ch := range r.Changes {
+		row := make(map[string]any, len(ch))
+		for k, v := range ch {
+			if k == "recid" {
+				k = g.cfg.IDField
+			}
+			row[k] = v
+		}
+		rows[i] = row
+	}
+
+	q := &Query{}
+	q.Update = &struct {
+		Cols   []string
+		Values [][]any
+		Rows   []map[string]any
+	}{Rows: rows}
+	g.wr.addRequestParams(req, q)
+	_, _, err := g.wr.handle((*w3sql.Query)(q))
+	return err
+}
+
+func (g *W2UIGrid[T]) delete(r *w2uiRequest) error {
+	ids := r.Recid
+	if len(ids) == 0 {
+		ids = r.Selected
+	}
+	return g.del.Handle(&w3sql.Query{Delete: ids})
+}
+
+type statusW2UI struct {
+	Status string `json:"status"`
+}
+
+// newW2UIResponder ошибки в конверте w2ui {"status": "error", "message": ...} на обоих транспортах
+func newW2UIResponder(w http.ResponseWriter, req any) *responder {
+	out := newResponder(w, req)
+	if t, ok := req.(*fasthttp.RequestCtx); ok {
+		out.errdetails = func(text string, details *ErrorDetails) {
+			buf, _ := json.Marshal(&W2UIError{
+				Status:  "error",
+				ErrCode: globalConfig.ErrorCodes.code(text),
+				Message: text,
+				Details: details,
+			})
+			t.Success("application/json", buf)
+		}
+	}
+	return out
+}
+
+func (g *W2UIGrid[T]) GetRequestHandlerInner(w http.ResponseWriter, req any, limit int, appendQuery *Query) {
+	defer g.sel.onPanic()
+
+	out := newW2UIResponder(w, req)
+	log := g.sel.logger
+	r, err := readW2UIRequest(req, g.sel.maxBodySize)
+	if errors.Is(err, ErrBodyTooLarge) {
+		log.LogError(BODY_TOO_LARGE, err, out.errout)
+		return
+	} else if err != nil {
+		log.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	cmd := r.command()
+	if err := g.check(cmd, r); err != nil {
+		log.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	switch cmd {
+	case "get":
+		g.get(req, r, limit, appendQuery, out)
+		return
+	case "save":
+		err = g.save(req, r)
+	case "delete":
+		err = g.delete(r)
+	}
+	if err != nil {
+		log.logHandleError(err, out)
+		return
+	}
+	buf, _ := json.Marshal(&statusW2UI{Status: "success"})
+	out.successout(buf)
+}
+
+// fasthttp
+func (g *W2UIGrid[T]) GetFasthttpRequestHandler(limit int, appendQuery *Query) fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		g.GetRequestHandlerInner(nil, ctx, limit, appendQuery)
+	})
+}
+
+// net/http
+func (g *W2UIGrid[T]) GetHttpRequestHandler(limit int, appendQuery *Query) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.GetRequestHandlerInner(w, r, limit, appendQuery)
+	})
+}
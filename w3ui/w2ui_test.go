@@ -0,0 +1,179 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+func newTestW2UIGrid(t *testing.T, db *countingDB) *W2UIGrid[Student] {
+	writer := NewDataWriter[Student](nil, &w3req.UpdateConfig{
+		AllSQL:      w3sql.NewSQLString("update students"),
+		IDFieldName: "studentID",
+		FieldMap:    compileMap,
+		SQLDialect:  "sqlite",
+	}, func() {})
+	writer.InitOnce(func() WriterOptions[Student] {
+		return WriterOptions[Student]{
+			GetDB: func() w3req.DB { return db },
+			Begin: func() (w3req.Tx, error) {
+				tx, err := db.DbMap.Begin()
+				return namedTx{tx}, err
+			},
+		}
+	})
+
+	deleter, err := w3req.NewDeleteRequester(&w3req.DeleteConfig{
+		Tables:     []*w3sql.DeletePair{{TableName: "students", IDName: "studentID"}},
+		SQLDialect: "sqlite",
+		OnPanic:    func() {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleter.InitOnce(func() *w3req.DeleteOptions {
+		return &w3req.DeleteOptions{DB: func() w3req.DB { return db }}
+	})
+
+	return NewW2UIGrid(
+		&W2UIConfig{IDField: "id", RecidKey: "StudentID"},
+		newExportRequester(t, db), writer, deleter,
+	)
+}
+
+type w2uiAnswer struct {
+	Status  string           `json:"status"`
+	Message string           `json:"message"`
+	Total   int              `json:"total"`
+	Records []map[string]any `json:"records"`
+}
+
+func w2uiCall(t *testing.T, handler http.HandlerFunc, req *http.Request) w2uiAnswer {
+	w := httptest.NewRecorder()
+	handler(w, req)
+	var answer w2uiAnswer
+	if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	return answer
+}
+
+func w2uiForm(request string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("request="+url.QueryEscape(request)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func w2uiNames(a w2uiAnswer) string {
+	names := make([]string, len(a.Records))
+	for i, r := range a.Records {
+		names[i] = r["FirstName"].(string)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestW2UIGet(t *testing.T) {
+	handler := newTestW2UIGrid(t, &countingDB{DbMap: openStudents(t)}).GetHttpRequestHandler(100, nil)
+
+	a := w2uiCall(t, handler, w2uiForm(`{"cmd": "get", "limit": 2, "offset": 0, "searchLogic": "OR",
+		"search": [
+			{"field": "age", "type": "int", "operator": "more", "value": 21},
+			{"field": "firstName", "type": "text", "operator": "begins", "value": "le"}
+		],
+		"sort": [{"field": "age", "direction": "desc"}]}`))
+	if a.Status != "success" || a.Total != 3 || w2uiNames(a) != "vanya,petya" {
+		t.Fatal("unexpected answer:", GetJSON(a))
+	}
+	if a.Records[0]["recid"] != float64(1) || a.Records[1]["recid"] != float64(2) {
+		t.Fatal("recid expected:", GetJSON(a.Records))
+	}
+
+	// w2ui 2.0: GET с параметром request, значения list - объекты {id, text}
+	request := `{"search": [{"field": "id", "type": "list", "operator": "in", "value": [{"id": 1, "text": "a"}, {"id": 3, "text": "c"}]}]}`
+	a = w2uiCall(t, handler, httptest.NewRequest(http.MethodGet, "/?request="+url.QueryEscape(request), nil))
+	if a.Status != "success" || w2uiNames(a) != "vanya,lena" {
+		t.Fatal("unexpected answer:", GetJSON(a))
+	}
+
+	a = w2uiCall(t, handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	if a.Status != "success" || a.Total != 4 || len(a.Records) != 4 {
+		t.Fatal("all records expected:", GetJSON(a))
+	}
+
+	a = w2uiCall(t, handler, w2uiForm(`{"cmd": "get", "search": [{"field": "nickName", "type": "text", "operator": "is", "value": "x"}]}`))
+	if a.Status != "error" || !strings.HasPrefix(a.Message, UNKNOWN_FIELD) {
+		t.Fatal("unknown field error expected:", GetJSON(a))
+	}
+}
+
+func TestW2UISaveDelete(t *testing.T) {
+	db := &countingDB{DbMap: openStudents(t)}
+	handler := newTestW2UIGrid(t, db).GetHttpRequestHandler(100, nil)
+
+	a := w2uiCall(t, handler, w2uiForm(`{"cmd": "save", "changes": [{"recid": 2, "age": 30}, {"recid": 3, "grade": 70}]}`))
+	if a.Status != "success" {
+		t.Fatal("unexpected answer:", GetJSON(a))
+	}
+	if n, _ := db.SelectInt("select count(*) from students where (studentID = 2 and age = 30) or (studentID = 3 and score = 70)"); n != 2 {
+		t.Fatal("records should be updated")
+	}
+
+	// dataType: 'JSON' - запрос в теле
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"action": "delete", "recid": [4]}`))
+	if a := w2uiCall(t, handler, req); a.Status != "success" {
+		t.Fatal("unexpected answer:", GetJSON(a))
+	}
+	if n, _ := db.SelectInt("select count(*) from students"); n != 3 {
+		t.Fatal("record should be deleted, left", n)
+	}
+
+	if a := w2uiCall(t, handler, w2uiForm(`{"cmd": "delete"}`)); a.Status != "error" {
+		t.Fatal("delete without records should fail:", GetJSON(a))
+	}
+	if a := w2uiCall(t, handler, w2uiForm(`{"cmd": "load"}`)); a.Status != "error" {
+		t.Fatal("unknown command should fail:", GetJSON(a))
+	}
+
+	// новые записи грида не сохраняются, ошибка вместо пустого успеха
+	a = w2uiCall(t, handler, w2uiForm(`{"cmd": "save", "changes": [{"recid": 1, "age": 40}, {"recid": -1, "firstName": "olya"}]}`))
+	if a.Status != "error" || !strings.Contains(a.Message, "new records") {
+		t.Fatal("save of new record should fail:", GetJSON(a))
+	}
+	if n, _ := db.SelectInt("select count(*) from students where age = 40"); n != 0 {
+		t.Fatal("nothing should be updated")
+	}
+}
+
+func TestW2UIFasthttpError(t *testing.T) {
+	handler := newTestW2UIGrid(t, &countingDB{DbMap: openStudents(t)}).GetFasthttpRequestHandler(100, nil)
+
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetBodyString(`{"cmd": "load"}`)
+	handler(&ctx)
+
+	// тот же конверт, что и на net/http, ключи в нижнем регистре
+	var a map[string]any
+	if err := json.Unmarshal(ctx.Response.Body(), &a); err != nil || a["status"] != "error" || a["message"] == nil {
+		t.Fatal("w2ui error expected:", string(ctx.Response.Body()), err)
+	}
+}
+
+func TestW2UIDates(t *testing.T) {
+	g := NewW2UIGrid(&W2UIConfig{}, &DataRequester[Student]{}, nil, nil)
+	c := g.condition(&w2uiSearch{Field: "born", Type: "date", Operator: "between", Value: []any{"2/3/2001", "12/31/2001"}})
+	if v, _ := json.Marshal(c.Val); string(v) != `["2001-02-03","2001-12-31"]` || c.Op != "between" {
+		t.Fatal("unexpected condition:", GetJSON(c))
+	}
+	c = g.condition(&w2uiSearch{Field: "at", Type: "datetime", Operator: "less", Value: "2/3/2001 1:05 pm"})
+	if c.Val != "2001-02-03T13:05:00" || c.Op != "<=" {
+		t.Fatal("unexpected condition:", GetJSON(c))
+	}
+}
@@ -0,0 +1,256 @@
+package w3ui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/algebrain/w3/w3req"
+	"github.com/algebrain/w3/w3sql"
+	"github.com/valyala/fasthttp"
+)
+
+// DataWriter выполняет Insert, Update и UpdateWhere запросы фронта
+// если в конфиге указан Returning, то отвечает записанными строками.
+// Части одного запроса выполняются по очереди, каждая в своей транзакции, и вместе не атомарны:
+// если Update завершился ошибкой, уже выполненный Insert не откатывается
+type DataWriter[T any] struct {
+	insCfg       *w3req.InsertConfig
+	updCfg       *w3req.UpdateConfig
+	ins          w3req.InsertRequester
+	upd          w3req.UpdateRequester
+	formatFields func([]T)
+	reqParams    func(req any) map[string]any
+	logger       *Logger
+	onPanic      func()
+	maxBodySize  int64
+}
+
+// любой из конфигов может быть nil, тогда соответствующая часть запроса не принимается
+func NewDataWriter[T any](
+	insCfg *w3req.InsertConfig,
+	updCfg *w3req.UpdateConfig,
+	onPanic func(),
+) *DataWriter[T] {
+	if onPanic == nil {
+		panic("[w3ui.NewDataWriter] ERROR: onPanic should not be nil")
+	}
+
+	d := &DataWriter[T]{
+		insCfg:  insCfg,
+		updCfg:  updCfg,
+		onPanic: onPanic,
+		logger:  &Logger{},
+	}
+
+	var err error
+	if insCfg != nil {
+		insCfg.OnPanic = onPanic
+		d.ins, err = w3req.NewInsertRequester(insCfg)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if updCfg != nil {
+		updCfg.OnPanic = onPanic
+		d.upd, err = w3req.NewUpdateRequester(updCfg)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return d
+}
+
+type WriterOptions[T any] struct {
+	GetDB           func() w3req.DB
+	ErrorLog        ExtLogger
+	InsertTransform w3sql.ValueTransform
+	UpdateTransform w3sql.ValueTransform
+	FormatFields    func([]T)                //для всех записей ответа обработка полей
+	Begin           func() (w3req.Tx, error) //транзакция, без нее не выполняются вставка частями и UpdateWhere с MaxRows
+
+	// параметры запроса для ServerColumns, например пользователь из сессии,
+	// без них Value серверных колонок получает пустые параметры, Params клиента не используются
+	RequestParams func(req any) map[string]any
+}
+
+func (d *DataWriter[T]) InitOnce(f func() WriterOptions[T]) *DataWriter[T] {
+	var opt *WriterOptions[T]
+	getOpt := func() *WriterOptions[T] {
+		if opt == nil {
+			o := f()
+			opt = &o
+			d.formatFields = opt.FormatFields
+			d.reqParams = opt.RequestParams
+			d.logger.setErrorLogger(opt.ErrorLog)
+		}
+		return opt
+	}
+
+	if d.ins != nil {
+		d.ins.InitOnce(func() *w3req.InsertOptions {
+			o := getOpt()
+			return &w3req.InsertOptions{
+				Logger:    d.logger,
+				DB:        o.GetDB,
+				Transform: o.InsertTransform,
+				Begin:     o.Begin,
+			}
+		})
+	}
+	if d.upd != nil {
+		d.upd.InitOnce(func() *w3req.UpdateOptions {
+			o := getOpt()
+			return &w3req.UpdateOptions{
+				Logger:    d.logger,
+				DB:        o.GetDB,
+				Transform: o.UpdateTransform,
+				Begin:     o.Begin,
+			}
+		})
+	}
+	return d
+}
+
+// если включен, то пишет SQL с параметрами
+// вызывать внутри InitOnce
+func (d *DataWriter[T]) DumpRequests() *DataWriter[T] {
+	if d.ins != nil {
+		d.ins.SetDumpRequests(true)
+	}
+	if d.upd != nil {
+		d.upd.SetDumpRequests(true)
+	}
+	return d
+}
+
+// если указан, то будет журналировать все запросы
+// вызывать внутри InitOnce
+func (d *DataWriter[T]) SetDebugLog(log ExtLogger) *DataWriter[T] {
+	d.logger.setDebugLogger(log)
+	return d
+}
+
+// ограничивает размер тела запроса, 0 - без ограничения
+func (d *DataWriter[T]) SetMaxBodySize(size int64) *DataWriter[T] {
+	d.maxBodySize = size
+	return d
+}
+
+// если включен, то вместо "Invalid Parameters" будет возвращать настоящую ошибку
+// вызывать внутри InitOnce
+func (d *DataWriter[T]) OutputOriginalErrorText() *DataWriter[T] {
+	d.logger.outputOriginalError = true
+	return d
+}
+
+func (d *DataWriter[T]) handle(q *w3sql.Query) ([]T, int64, error) {
+	records := []T{}
+	var total int64
+
+	if q.Insert != nil {
+		if len(d.insCfg.Returning) == 0 {
+			n, err := d.ins.HandleBatch(q)
+			if err != nil {
+				return nil, 0, err
+			}
+			total += n
+		} else {
+			var r []T
+			if err := d.ins.HandleReturning(q, &r); err != nil {
+				return nil, 0, err
+			}
+			records = append(records, r...)
+		}
+	}
+
+	if q.Update != nil {
+		if len(d.updCfg.Returning) == 0 {
+			if err := d.upd.Handle(q); err != nil {
+				return nil, 0, err
+			}
+		} else {
+			var r []T
+			if err := d.upd.HandleReturning(q, &r); err != nil {
+				return nil, 0, err
+			}
+			records = append(records, r...)
+		}
+	}
+
+	if q.UpdateWhere != nil {
+		n, err := d.upd.HandleWhere(q)
+		if err != nil {
+			return nil, 0, err
+		}
+		total += n
+	}
+
+	return records, total + int64(len(records)), nil
+}
+
+// параметры от сервера передаются отдельно от Params клиента, см. w3sql.Query.ServerParams
+func (d *DataWriter[T]) addRequestParams(req any, q *Query) {
+	if d.reqParams == nil {
+		return
+	}
+	q.ServerParams = d.reqParams(req)
+}
+
+type writeW2UI struct {
+	Status  string `json:"status"`
+	Total   int64  `json:"total"`
+	Records any    `json:"records"`
+}
+
+func (d *DataWriter[T]) GetRequestHandlerInner(w http.ResponseWriter, req any) {
+	defer d.onPanic()
+
+	out := newResponder(w, req)
+	q, ok := d.logger.readQuery(req, d.maxBodySize, nil, out)
+	if !ok {
+		return
+	}
+
+	var err error
+	switch {
+	case q.Insert == nil && q.Update == nil && q.UpdateWhere == nil:
+		err = errors.New("w3ui: no insert or update")
+	case q.Insert != nil && d.ins == nil:
+		err = errors.New("w3ui: insert is not allowed")
+	case (q.Update != nil || q.UpdateWhere != nil) && d.upd == nil:
+		err = errors.New("w3ui: update is not allowed")
+	}
+	if err != nil {
+		d.logger.LogError(INVALID_PARAMETERS, err, out.errout)
+		return
+	}
+
+	d.addRequestParams(req, q)
+	records, total, err := d.handle((*w3sql.Query)(q))
+	if err != nil {
+		d.logger.logHandleError(err, out)
+		return
+	}
+
+	if d.formatFields != nil && len(records) != 0 {
+		d.formatFields(records)
+	}
+
+	buf, _ := json.Marshal(&writeW2UI{Status: "success", Total: total, Records: records})
+	out.successout(buf)
+}
+
+// fasthttp
+func (d *DataWriter[T]) GetFasthttpRequestHandler() fasthttp.RequestHandler {
+	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
+		d.GetRequestHandlerInner(nil, ctx)
+	})
+}
+
+// net/http
+func (d *DataWriter[T]) GetHttpRequestHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.GetRequestHandlerInner(w, r)
+	})
+}
@@ -0,0 +1,254 @@
+package w3ui
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type xlsxSharedStrings struct {
+	Items []struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxRow struct {
+	Num   int `xml:"r,attr"`
+	Cells []struct {
+		Ref    string `xml:"r,attr"`
+		Type   string `xml:"t,attr"`
+		Value  string `xml:"v"`
+		Inline struct {
+			T string `xml:"t"`
+		} `xml:"is"`
+	} `xml:"c"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRels struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// размер распакованного файла внутри xlsx по умолчанию
+const xlsxMaxEntrySize = 64 << 20
+
+// наибольшее число колонок листа Excel, последняя колонка XFD
+const xlsxMaxColumns = 16384
+
+// xlsxLimitReader отдает ошибку, когда распаковано больше n байт, защищает от zip бомб
+type xlsxLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *xlsxLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errors.New("w3ui: xlsx part is too large")
+	}
+	return n, err
+}
+
+func openZipEntry(f *zip.File, maxSize int64) (io.ReadCloser, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&xlsxLimitReader{r: r, n: maxSize}, r}, nil
+}
+
+func readZipXML(f *zip.File, maxSize int64, v any) error {
+	r, err := openZipEntry(f, maxSize)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// номер колонки по ссылке на ячейку, например C12 -> 2; ссылка должна быть вида [A-Z]{1,3}[0-9]+
+func xlsxColumn(ref string) (int, error) {
+	col, i := 0, 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	if i == 0 || i > 3 || i == len(ref) || col > xlsxMaxColumns {
+		return 0, errors.New("w3ui: bad cell reference " + ref)
+	}
+	for _, r := range ref[i:] {
+		if r < '0' || r > '9' {
+			return 0, errors.New("w3ui: bad cell reference " + ref)
+		}
+	}
+	return col - 1, nil
+}
+
+// xlsxFirstSheet путь первого листа из xl/workbook.xml и его связей
+func xlsxFirstSheet(files map[string]*zip.File, maxSize int64) (string, error) {
+	wbf, relf := files["xl/workbook.xml"], files["xl/_rels/workbook.xml.rels"]
+	if wbf == nil || relf == nil {
+		return "", errors.New("w3ui: xlsx has no workbook")
+	}
+	var wb xlsxWorkbook
+	if err := readZipXML(wbf, maxSize, &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", errors.New("w3ui: xlsx has no worksheets")
+	}
+	var rels xlsxRels
+	if err := readZipXML(relf, maxSize, &rels); err != nil {
+		return "", err
+	}
+	for _, r := range rels.Items {
+		if r.ID != wb.Sheets[0].RID {
+			continue
+		}
+		// путь задается от xl/ или от корня архива
+		if strings.HasPrefix(r.Target, "/") {
+			return path.Clean(r.Target[1:]), nil
+		}
+		return path.Join("xl", r.Target), nil
+	}
+	return "", errors.New("w3ui: xlsx has no first worksheet")
+}
+
+// readXLSX читает первый лист xlsx без стилей: даты остаются числами Excel, формулы - сохраненным значением,
+// вместе со строками возвращает их номера на листе; limit - наибольшее число читаемых строк (0 - все),
+// maxSize - наибольший размер каждого распакованного файла архива (0 - 64 МБ)
+func readXLSX(data []byte, limit int, maxSize int64) ([][]string, []int, error) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if maxSize <= 0 {
+		maxSize = xlsxMaxEntrySize
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range z.File {
+		files[f.Name] = f
+	}
+
+	var shared []string
+	if f := files["xl/sharedStrings.xml"]; f != nil {
+		var sst xlsxSharedStrings
+		if err := readZipXML(f, maxSize, &sst); err != nil {
+			return nil, nil, err
+		}
+		shared = make([]string, len(sst.Items))
+		for i, si := range sst.Items {
+			s := si.T
+			for _, r := range si.Runs {
+				s += r.T
+			}
+			shared[i] = s
+		}
+	}
+
+	name, err := xlsxFirstSheet(files, maxSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	sheet := files[name]
+	if sheet == nil {
+		return nil, nil, errors.New("w3ui: xlsx has no first worksheet")
+	}
+	r, err := openZipEntry(sheet, maxSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	// строки разбираются по одной, чтобы не читать лист дальше limit
+	var (
+		result [][]string
+		lines  []int
+	)
+	dec := xml.NewDecoder(r)
+	for limit <= 0 || len(result) < limit {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := dec.DecodeElement(&row, &se); err != nil {
+			return nil, nil, err
+		}
+
+		var cells []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				if col, err = xlsxColumn(c.Ref); err != nil {
+					return nil, nil, err
+				}
+			} else if col >= xlsxMaxColumns {
+				return nil, nil, errors.New("w3ui: too many cells in xlsx row")
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, nil, errors.New("w3ui: bad shared string index in " + c.Ref)
+				}
+				cells[col] = shared[n]
+			case "inlineStr":
+				cells[col] = c.Inline.T
+			default:
+				cells[col] = c.Value
+			}
+		}
+		result = append(result, cells)
+		if row.Num > 0 {
+			lines = append(lines, row.Num)
+		} else {
+			lines = append(lines, len(result))
+		}
+	}
+	return result, lines, nil
+}
+
+// дата Excel - число дней от 30.12.1899
+func excelDate(s string, withTime bool) (string, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	t := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).Add(time.Duration(math.Round(f*86400)) * time.Second)
+	if withTime {
+		return t.Format("2006/1/2 15:04:05"), true
+	}
+	return t.Format("2006/1/2"), true
+}